@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"networkd-api/internal/service"
+	"os"
+)
+
+const cliUsage = `usage:
+  networkd-api-server                         run the API server
+  networkd-api-server manifest [flags]        print a host's configuration as a manifest
+  networkd-api-server apply -f FILE [flags]   bring a host in line with a manifest
+`
+
+// runCLI runs a one-shot subcommand and returns the exit code.
+func runCLI(args []string) int {
+	var err error
+	switch args[0] {
+	case "manifest":
+		err = manifestCommand(args[1:])
+	case "apply":
+		err = applyCommand(args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, cliUsage)
+		return 0
+	default:
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+// newCLIService builds the service like the server does. Its startup
+// messages go to stderr so they do not mix with the command's output.
+func newCLIService() *service.NetworkdService {
+	return service.NewNetworkdServiceWithLog(os.Getenv("NETWORKD_CONFIG_DIR"), os.Getenv("NETWORKD_DATA_DIR"), os.Stderr)
+}
+
+func manifestCommand(args []string) error {
+	fs := flag.NewFlagSet("manifest", flag.ExitOnError)
+	host := fs.String("host", "", "target host (default: local)")
+	format := fs.String("format", "yaml", "output format: yaml or json")
+	secrets := fs.Bool("secrets", false, "include key material instead of redacting it")
+	fs.Parse(args)
+
+	m, err := newCLIService().BuildManifest(*host, *secrets)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "yaml":
+		data, err := m.YAML()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+func applyCommand(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	host := fs.String("host", "", "target host (default: local)")
+	file := fs.String("f", "", "manifest file (- for stdin)")
+	dryRun := fs.Bool("dry-run", false, "only print the plan")
+	prune := fs.Bool("prune", false, "delete files that are not in the manifest")
+	reload := fs.Bool("reload", false, "reload networkd after applying")
+	fs.Parse(args)
+
+	var data []byte
+	var err error
+	switch *file {
+	case "":
+		return fmt.Errorf("-f is required")
+	case "-":
+		data, err = io.ReadAll(os.Stdin)
+	default:
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+	m, err := service.ParseManifest(data)
+	if err != nil {
+		return err
+	}
+	plan, err := newCLIService().ApplyManifest(*host, m, service.ManifestOptions{Prune: *prune, DryRun: *dryRun, Reload: *reload})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(plan)
+}
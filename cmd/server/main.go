@@ -9,6 +9,10 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	configDir := os.Getenv("NETWORKD_CONFIG_DIR")
 	dataDir := os.Getenv("NETWORKD_DATA_DIR")
 	staticDir := os.Getenv("STATIC_DIR")
@@ -16,7 +20,9 @@ func main() {
 	svc := service.NewNetworkdService(configDir, dataDir)
 	log.Printf("Using ConfigDir: %s", svc.ConfigDir)
 	log.Printf("Using DataDir: %s", svc.DataDir)
+	svc.Stats.Start()
 	h := api.NewHandler(svc)
+	h.RevealToken = os.Getenv("NETWORKD_REVEAL_TOKEN")
 	r := api.NewRouter(h, staticDir)
 
 	host := os.Getenv("NETWORKD_HOST")
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"networkd-api/internal/service"
+)
+
+// ApplyBatch handles POST /api/batch: create/update/delete operations across
+// config types on one host, validated together and written all-or-nothing.
+func (h *Handler) ApplyBatch(w http.ResponseWriter, r *http.Request) {
+	var req service.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for i := range req.Operations {
+		filename, err := sanitizeFilename(req.Operations[i].Filename)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Operations[i].Filename = filename
+	}
+
+	result, err := h.Service.ApplyBatch(getHost(r), req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidBatch) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"networkd-api/internal/service"
+	"strings"
+)
+
+// maxBundleSize bounds uploaded bundles; a host's configuration is a few
+// hundred kilobytes at most.
+const maxBundleSize = 32 << 20
+
+// ExportBundle handles GET /api/system/export: every config file, drop-in and
+// networkd.conf of the host plus metadata, as a tar.gz (default) or with
+// ?format=json as a JSON document. Secrets are redacted unless the request
+// carries the reveal token.
+func (h *Handler) ExportBundle(w http.ResponseWriter, r *http.Request) {
+	reveal, ok := h.revealSecrets(w, r)
+	if !ok {
+		return
+	}
+	host := getHost(r)
+	bundle, err := h.Service.ExportBundle(host, reveal)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	name := fmt.Sprintf("networkd-%s-%s", bundle.Metadata.Host, bundle.Metadata.ExportedAt.Format("20060102-150405"))
+	switch r.URL.Query().Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".json"))
+		json.NewEncoder(w).Encode(bundle)
+	case "", "tar", "tar.gz":
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".tar.gz"))
+		bundle.WriteTarGz(w)
+	default:
+		http.Error(w, "Unknown format, expected tar or json", http.StatusBadRequest)
+	}
+}
+
+// parseRenames reads ?rename=old:new (repeatable, or comma-separated).
+func parseRenames(r *http.Request) (map[string]string, error) {
+	renames := map[string]string{}
+	for _, param := range r.URL.Query()["rename"] {
+		for _, pair := range strings.Split(param, ",") {
+			old, repl, ok := strings.Cut(pair, ":")
+			if !ok || old == "" || repl == "" {
+				return nil, fmt.Errorf("invalid rename %q, expected old:new", pair)
+			}
+			renames[old] = repl
+		}
+	}
+	return renames, nil
+}
+
+// ImportBundle handles POST /api/system/import with a bundle from
+// ExportBundle (tar.gz or JSON) as the body. Without ?apply=true it only
+// validates and previews; ?rename=eth0:ens3 maps interface names,
+// ?overwrite=true allows replacing existing files and ?skip_global=true
+// leaves networkd.conf alone.
+func (h *Handler) ImportBundle(w http.ResponseWriter, r *http.Request) {
+	renames, err := parseRenames(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBundleSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxBundleSize {
+		http.Error(w, "Bundle too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	bundle, err := service.ReadBundle(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	result, err := h.Service.ImportBundle(getHost(r), bundle, service.BundleImportOptions{
+		Rename:     renames,
+		Apply:      q.Get("apply") == "true",
+		Overwrite:  q.Get("overwrite") == "true",
+		SkipGlobal: q.Get("skip_global") == "true",
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidImport) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if result.Applied {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(result)
+}
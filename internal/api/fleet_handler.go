@@ -0,0 +1,13 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// GetFleetConflicts handles GET /api/fleet/conflicts: duplicate addresses
+// and MACs across all managed hosts, most severe first.
+func (h *Handler) GetFleetConflicts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Service.ScanFleetConflicts())
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"networkd-api/internal/service"
@@ -35,6 +37,9 @@ func sanitizeFilename(name string) (string, error) {
 
 type Handler struct {
 	Service *service.NetworkdService
+	// RevealToken must be sent as X-Reveal-Secrets to receive key material
+	// in responses; empty refuses every such request.
+	RevealToken string
 }
 
 func NewHandler(s *service.NetworkdService) *Handler {
@@ -100,38 +105,106 @@ func (h *Handler) ListLinks(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(files)
 }
 
-func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
+// configFilename extracts the {filename} URL parameter, sanitizes it and checks
+// that its suffix matches the config type served by the route.
+func configFilename(r *http.Request, configType string) (string, error) {
 	filename, err := sanitizeFilename(chi.URLParam(r, "filename"))
+	if err != nil {
+		return "", err
+	}
+	suffix, ok := service.ConfigSuffix(configType)
+	if !ok {
+		return "", fmt.Errorf("unknown config type: %s", configType)
+	}
+	if t, ok := service.ConfigTypeForFilename(filename); !ok || t != configType {
+		return "", fmt.Errorf("invalid filename: %s files must end in %s", configType, suffix)
+	}
+	return filename, nil
+}
+
+// GetNetwork handles GET /api/networks/{filename}
+func (h *Handler) GetNetwork(w http.ResponseWriter, r *http.Request) {
+	h.handleGet(w, r, "network")
+}
+
+// GetLink handles GET /api/links/{filename}
+func (h *Handler) GetLink(w http.ResponseWriter, r *http.Request) {
+	h.handleGet(w, r, "link")
+}
+
+// GetNetDev handles GET /api/netdevs/{filename}
+func (h *Handler) GetNetDev(w http.ResponseWriter, r *http.Request) {
+	h.handleGet(w, r, "netdev")
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request, configType string) {
+	filename, err := configFilename(r, configType)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	reveal, ok := h.revealSecrets(w, r)
+	if !ok {
+		return
+	}
 	content, err := h.Service.ReadNetworkFile(getHost(r), filename)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	configType := "network"
-	if strings.HasSuffix(filename, ".netdev") {
-		configType = "netdev"
-	} else if strings.HasSuffix(filename, ".link") {
-		configType = "link"
-	}
-
 	// Dynamic parse
 	config, err := service.INIToMap(content, h.Service.Schema, configType)
 	if err != nil {
 		http.Error(w, "Failed to parse file: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !reveal {
+		service.RedactSecrets(config, h.Service.Schema, configType)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(config)
 }
 
+// revealSecrets reports whether the response may carry key material. The
+// caller asks for it with X-Reveal-Secrets set to the server's RevealToken;
+// a header is used rather than a query parameter so the token does not end
+// up in access logs. Without the header secrets are redacted. A wrong token,
+// or any token when none is configured, is answered with 403 and ok is false.
+func (h *Handler) revealSecrets(w http.ResponseWriter, r *http.Request) (reveal, ok bool) {
+	token := r.Header.Get("X-Reveal-Secrets")
+	if token == "" {
+		return false, true
+	}
+	if h.RevealToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.RevealToken)) != 1 {
+		http.Error(w, "Revealing secrets is not permitted", http.StatusForbidden)
+		return false, false
+	}
+	return true, true
+}
+
 type createRequest struct {
 	Filename string                 `json:"filename"`
 	Config   map[string]interface{} `json:"config"`
+	// SecretsToFiles moves inline keys to separate key files and references
+	// them via PrivateKeyFile= / PresharedKeyFile= / KeyFile=.
+	SecretsToFiles bool `json:"secrets_to_files,omitempty"`
+}
+
+// writeConfig validates and writes a config together with any key files
+// split off from it. Addresses requested from IPAM pools are returned.
+func (h *Handler) writeConfig(w http.ResponseWriter, r *http.Request, filename, configType string, config map[string]interface{}, secretsToFiles bool) ([]service.IPAMAllocation, bool) {
+	allocs, err := h.Service.WriteConfig(getHost(r), filename, configType, config, secretsToFiles)
+	if err != nil {
+		status := ipamStatus(err)
+		if errors.Is(err, service.ErrInvalidConfig) || status == http.StatusNotFound {
+			// A missing pool is a bad reference in the config here
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return nil, false
+	}
+	return allocs, true
 }
 
 // CreateNetwork handles POST /api/networks (Creates .network file only)
@@ -144,6 +217,27 @@ func (h *Handler) CreateLink(w http.ResponseWriter, r *http.Request) {
 	h.handleCreate(w, r, ".link", "link")
 }
 
+// PreviewLinkRename handles POST /api/links/preview: predicts interface names
+// if the given .link config were written, without writing it
+func (h *Handler) PreviewLinkRename(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Filename == "" || req.Config == nil {
+		http.Error(w, "filename and config are required", http.StatusBadRequest)
+		return
+	}
+	predictions, err := h.Service.PreviewLinkRename(getHost(r), filepath.Base(req.Filename), req.Config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(predictions)
+}
+
 // CreateNetDev handles POST /api/interfaces (Creates .netdev file only)
 func (h *Handler) CreateNetDev(w http.ResponseWriter, r *http.Request) {
 	h.handleCreate(w, r, ".netdev", "netdev")
@@ -160,7 +254,11 @@ func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request, suffix, c
 		http.Error(w, "Filename and config are required", http.StatusBadRequest)
 		return
 	}
-	// Enforce suffix
+	// Enforce suffix, refusing names that already carry another config type's suffix
+	if t, ok := service.ConfigTypeForFilename(req.Filename); ok && t != configType {
+		http.Error(w, fmt.Sprintf("invalid filename: %s files must end in %s", configType, suffix), http.StatusBadRequest)
+		return
+	}
 	if !strings.HasSuffix(req.Filename, suffix) {
 		req.Filename += suffix
 	}
@@ -172,27 +270,14 @@ func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request, suffix, c
 	}
 	req.Filename = cleanName
 
-	// Validate against Schema
-	if err := h.Service.Schema.Validate(configType, req.Config); err != nil {
-		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Convert Map -> INI
-	content, err := service.MapToINI(req.Config, h.Service.Schema, configType)
-	if err != nil {
-		http.Error(w, "Conversion failed: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	if err := h.Service.WriteNetworkFile(getHost(r), req.Filename, content); err != nil {
-		http.Error(w, "Failed to write file: "+err.Error(), http.StatusInternalServerError)
+	allocs, ok := h.writeConfig(w, r, req.Filename, configType, req.Config, req.SecretsToFiles)
+	if !ok {
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Configuration created"})
+	json.NewEncoder(w).Encode(writeResponse("Configuration created", allocs))
 }
 
 // UpdateNetwork handles PUT /api/networks/{filename}
@@ -211,20 +296,22 @@ func (h *Handler) UpdateNetDev(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handleUpdate(w http.ResponseWriter, r *http.Request, configType string) {
-	filename, err := sanitizeFilename(chi.URLParam(r, "filename"))
+	filename, err := configFilename(r, configType)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Verify file exists
-	if _, err := h.Service.ReadNetworkFile(getHost(r), filename); err != nil {
+	existing, err := h.Service.ReadNetworkFile(getHost(r), filename)
+	if err != nil {
 		http.Error(w, "File not found: "+filename, http.StatusNotFound)
 		return
 	}
 
 	var req struct {
-		Config map[string]interface{} `json:"config"`
+		Config         map[string]interface{} `json:"config"`
+		SecretsToFiles bool                   `json:"secrets_to_files,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -235,33 +322,51 @@ func (h *Handler) handleUpdate(w http.ResponseWriter, r *http.Request, configTyp
 		return
 	}
 
-	if err := h.Service.Schema.Validate(configType, req.Config); err != nil {
-		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
-		return
+	// Keep stored secrets the client omitted or sent back redacted
+	if stored, err := service.INIToMap(existing, h.Service.Schema, configType); err == nil {
+		service.PreserveSecrets(req.Config, stored, h.Service.Schema, configType)
 	}
 
-	content, err := service.MapToINI(req.Config, h.Service.Schema, configType)
-	if err != nil {
-		http.Error(w, "Conversion failed: "+err.Error(), http.StatusBadRequest)
+	allocs, ok := h.writeConfig(w, r, filename, configType, req.Config, req.SecretsToFiles)
+	if !ok {
 		return
 	}
 
-	if err := h.Service.WriteNetworkFile(getHost(r), filename, content); err != nil {
-		http.Error(w, "Failed to write file: "+err.Error(), http.StatusInternalServerError)
-		return
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(writeResponse("Configuration updated", allocs))
+}
+
+// writeResponse reports a successful write with the pool addresses it used.
+func writeResponse(message string, allocs []service.IPAMAllocation) map[string]interface{} {
+	resp := map[string]interface{}{"message": message}
+	if len(allocs) > 0 {
+		resp["allocations"] = allocs
 	}
+	return resp
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Configuration updated"})
+// DeleteNetwork handles DELETE /api/networks/{filename}
+func (h *Handler) DeleteNetwork(w http.ResponseWriter, r *http.Request) {
+	h.handleDelete(w, r, "network")
 }
 
-func (h *Handler) DeleteConfig(w http.ResponseWriter, r *http.Request) {
-	filename, err := sanitizeFilename(chi.URLParam(r, "filename"))
+// DeleteLink handles DELETE /api/links/{filename}
+func (h *Handler) DeleteLink(w http.ResponseWriter, r *http.Request) {
+	h.handleDelete(w, r, "link")
+}
+
+// DeleteNetDev handles DELETE /api/netdevs/{filename}
+func (h *Handler) DeleteNetDev(w http.ResponseWriter, r *http.Request) {
+	h.handleDelete(w, r, "netdev")
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, configType string) {
+	filename, err := configFilename(r, configType)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := h.Service.DeleteNetworkFile(getHost(r), filename); err != nil {
+	if err := h.Service.DeleteConfig(getHost(r), filename); err != nil {
 		http.Error(w, "Failed to delete file: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -315,24 +420,51 @@ func (h *Handler) AddHost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid body", http.StatusBadRequest)
 		return
 	}
-	if host.Name == "" || host.Host == "" {
-		http.Error(w, "Name and Host are required", http.StatusBadRequest)
+
+	if err := h.Service.HostManager.AddHost(host); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidHost) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, "Failed to add host: "+err.Error(), status)
 		return
 	}
-	// Default user/port
-	if host.User == "" {
-		host.User = "networkd-api"
+	host, _ = h.Service.HostManager.GetHost(host.Name)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(host)
+}
+
+// UpdateHost replaces the settings of a remote host. The cached connection
+// is dropped so the new settings apply to the next request.
+func (h *Handler) UpdateHost(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	var host service.HostConfig
+	if err := json.NewDecoder(r.Body).Decode(&host); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
 	}
-	if host.Port == 0 {
-		host.Port = 22
+	if host.Name != "" && host.Name != name {
+		http.Error(w, "Host name cannot be changed", http.StatusBadRequest)
+		return
 	}
+	host.Name = name
 
-	if err := h.Service.HostManager.AddHost(host); err != nil {
-		http.Error(w, "Failed to add host: "+err.Error(), http.StatusInternalServerError)
+	if err := h.Service.HostManager.UpdateHost(host); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrInvalidHost):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrHostNotFound):
+			status = http.StatusNotFound
+		}
+		http.Error(w, "Failed to update host: "+err.Error(), status)
 		return
 	}
+	h.Service.DropConnector(name)
+	host, _ = h.Service.HostManager.GetHost(name)
 
-	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(host)
 }
 
@@ -343,6 +475,7 @@ func (h *Handler) RemoveHost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to remove host: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.Service.DropConnector(name)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -379,6 +512,13 @@ func (h *Handler) GetPublicSSHKey(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(key))
 }
 
+// GetRemoteHelper returns the helper script that remote hosts run through
+// sudo for file access, for scripts/setup-remote-host.sh to install.
+func (h *Handler) GetRemoteHelper(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/x-shellscript")
+	w.Write([]byte(service.RemoteHelperScript))
+}
+
 // GetSchemas returns the loaded JSON schemas with original key ordering preserved
 func (h *Handler) GetSchemas(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
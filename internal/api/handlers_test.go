@@ -113,6 +113,17 @@ func TestCreateNetwork(t *testing.T) {
 		t.Fatalf("File not created: %v", err)
 	}
 
+	info, err := os.Stat(filepath.Join(tmpDir, "test.network"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %o", info.Mode().Perm())
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(tmpDir, ".test.network.tmp-*")); len(leftovers) > 0 {
+		t.Errorf("Temporary files left behind: %v", leftovers)
+	}
+
 	sContent := string(content)
 	// Check loosely because parsing/generating might reorder or format
 	// Actually MapToINI sorts keys so it's deterministic
@@ -167,3 +178,903 @@ func TestHostManagement(t *testing.T) {
 		t.Errorf("ListHosts failed, got %v", hosts)
 	}
 }
+
+func TestConfigRoutesEnforceSuffix(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+
+	os.WriteFile(filepath.Join(tmpDir, "10-eth0.network"), []byte("[Match]\nName=eth0\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "backup.conf"), []byte("[Match]\nName=eth0\n"), 0644)
+
+	router := NewRouter(NewHandler(svc), "")
+
+	cases := []struct {
+		method, path string
+		want         int
+	}{
+		{"DELETE", "/api/links/10-eth0.network", http.StatusBadRequest},
+		{"GET", "/api/netdevs/10-eth0.network", http.StatusBadRequest},
+		{"GET", "/api/networks/backup.conf", http.StatusBadRequest},
+		{"GET", "/api/networks/10-eth0.network", http.StatusOK},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.want {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.path, tc.want, w.Code)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "10-eth0.network")); err != nil {
+		t.Errorf("network file was removed through the links route: %v", err)
+	}
+}
+
+func TestSecretsRedactedAndPreserved(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	// Ownership changes need root; the test only checks the mode
+	svc.SecretWriteOptions = service.WriteOptions{Mode: 0640}
+	svc.Schema.Schemas["netdev"] = map[string]interface{}{}
+
+	content := "[NetDev]\nName=wg0\nKind=wireguard\n[WireGuard]\nPrivateKey=c2VjcmV0\nListenPort=51820\n"
+	os.WriteFile(filepath.Join(tmpDir, "50-wg0.netdev"), []byte(content), 0640)
+
+	handler := NewHandler(svc)
+	router := NewRouter(handler, "")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/netdevs/50-wg0.netdev", nil))
+	if contains(w.Body.String(), "c2VjcmV0") || !contains(w.Body.String(), service.RedactedValue) {
+		t.Errorf("Expected private key to be redacted, got %s", w.Body.String())
+	}
+
+	// Revealing needs the token configured on the server
+	reveal := func(path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Reveal-Secrets", token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	for _, path := range []string{"/api/netdevs/50-wg0.netdev", "/api/manifest", "/api/system/export?format=json"} {
+		if w := reveal(path, "true"); w.Code != http.StatusForbidden || contains(w.Body.String(), "c2VjcmV0") {
+			t.Errorf("%s: expected reveal without a configured token to be refused, got %d %s", path, w.Code, w.Body.String())
+		}
+	}
+	handler.RevealToken = "s3cret-token"
+	if w := reveal("/api/netdevs/50-wg0.netdev", "true"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected wrong token to be refused, got %d", w.Code)
+	}
+	req := httptest.NewRequest("POST", "/api/import/netplan", bytes.NewBufferString(`{"files": {}}`))
+	req.Header.Set("X-Reveal-Secrets", "wrong")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected wrong token to be refused on import, got %d", w.Code)
+	}
+	if w := reveal("/api/netdevs/50-wg0.netdev", "s3cret-token"); !contains(w.Body.String(), "c2VjcmV0") {
+		t.Errorf("Expected private key with the reveal token, got %s", w.Body.String())
+	}
+
+	// Update without the key keeps the stored one
+	body, _ := json.Marshal(map[string]interface{}{
+		"config": map[string]interface{}{
+			"NetDev":    map[string]interface{}{"Name": "wg0", "Kind": "wireguard"},
+			"WireGuard": map[string]interface{}{"ListenPort": "51821", "PrivateKey": service.RedactedValue},
+		},
+	})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/api/netdevs/50-wg0.netdev", bytes.NewBuffer(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Update failed: %d %s", w.Code, w.Body.String())
+	}
+
+	written, _ := os.ReadFile(filepath.Join(tmpDir, "50-wg0.netdev"))
+	if !contains(string(written), "c2VjcmV0") || !contains(string(written), "51821") {
+		t.Errorf("Expected preserved key and new port, got %s", written)
+	}
+	if info, _ := os.Stat(filepath.Join(tmpDir, "50-wg0.netdev")); info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640 for file with key material, got %o", info.Mode().Perm())
+	}
+}
+
+func TestWireGuardKeysAndPeers(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	svc.Schema.Schemas["netdev"] = map[string]interface{}{}
+	router := NewRouter(NewHandler(svc), "")
+
+	// RFC 7748 test vector
+	body, _ := json.Marshal(map[string]string{"private_key": "dwdtCnMYpX08FsFyUbJmRd9ML4frwJkqsXf7pR25LCo="})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/wireguard/pubkey", bytes.NewBuffer(body)))
+	var derived map[string]string
+	json.NewDecoder(w.Body).Decode(&derived)
+	if derived["public_key"] != "hSDwCYkwp1R0i33ctD73Wg2/Og0mOBr066SpjqqbTmo=" {
+		t.Errorf("Unexpected public key: %v", derived)
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, "50-wg0.netdev"), []byte("[NetDev]\nName=wg0\nKind=wireguard\n"), 0644)
+
+	_, peerKey, _ := service.GenerateWireGuardKeyPair()
+	body, _ = json.Marshal(map[string]interface{}{
+		"peer": map[string]interface{}{"PublicKey": peerKey, "AllowedIPs": "10.99.0.2/32"},
+	})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/netdevs/50-wg0.netdev/peers", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Add peer failed: %d %s", w.Code, w.Body.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "50-wg0.netdev"))
+	if !contains(string(content), "[WireGuardPeer]") || !contains(string(content), peerKey) {
+		t.Errorf("Peer not written: %s", content)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("DELETE", "/api/netdevs/50-wg0.netdev/peers", nil)
+	q := req.URL.Query()
+	q.Set("public_key", peerKey)
+	req.URL.RawQuery = q.Encode()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Remove peer failed: %d %s", w.Code, w.Body.String())
+	}
+	content, _ = os.ReadFile(filepath.Join(tmpDir, "50-wg0.netdev"))
+	if contains(string(content), "WireGuardPeer") {
+		t.Errorf("Peer not removed: %s", content)
+	}
+}
+
+func TestLinkActionValidation(t *testing.T) {
+	svc, _ := setupTestService(t)
+	router := NewRouter(NewHandler(svc), "")
+
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/api/system/links/eth0/explode", http.StatusBadRequest},
+		{"/api/system/links/averyverylongname0/up", http.StatusBadRequest},
+		{"/api/system/links/lo/down", http.StatusForbidden},
+		{"/api/system/links/lo/delete", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", tc.path, nil))
+		if w.Code != tc.want {
+			t.Errorf("POST %s: expected %d, got %d (%s)", tc.path, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestTopologyWizard(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	svc.Schema.Schemas["netdev"] = map[string]interface{}{}
+	router := NewRouter(NewHandler(svc), "")
+
+	os.WriteFile(filepath.Join(tmpDir, "10-eno1.network"), []byte("[Match]\nName=eno1\n\n[Network]\nDHCP=no\n"), 0644)
+
+	post := func(path string, spec interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(spec)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewBuffer(body)))
+		return w
+	}
+
+	w := post("/api/wizard/bond", map[string]interface{}{"name": "bond0", "members": []string{"eno1", "eno2"}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Bond wizard failed: %d %s", w.Code, w.Body.String())
+	}
+	for _, f := range []string{"20-bond0.netdev", "20-bond0.network", "20-eno2.network"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, f)); err != nil {
+			t.Errorf("%s not written: %v", f, err)
+		}
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "10-eno1.network"))
+	if !contains(string(content), "Bond = bond0") || !contains(string(content), "DHCP = no") {
+		t.Errorf("Existing member network not updated: %s", content)
+	}
+
+	spec := map[string]interface{}{
+		"parent": "bond0",
+		"vlans": []map[string]interface{}{
+			{"id": 10, "bridge": "br10", "network": map[string]interface{}{"dhcp": "yes"}},
+			{"id": 20, "bridge": "br20"},
+		},
+	}
+	w = post("/api/wizard/vlan?dry_run=true", spec)
+	if w.Code != http.StatusOK {
+		t.Fatalf("VLAN dry run failed: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "20-bond0.10.netdev")); err == nil {
+		t.Error("Dry run wrote files")
+	}
+
+	w = post("/api/wizard/vlan", spec)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("VLAN wizard failed: %d %s", w.Code, w.Body.String())
+	}
+	content, _ = os.ReadFile(filepath.Join(tmpDir, "20-bond0.network"))
+	if !contains(string(content), "VLAN = bond0.10") || !contains(string(content), "VLAN = bond0.20") {
+		t.Errorf("VLANs not attached to parent: %s", content)
+	}
+	content, _ = os.ReadFile(filepath.Join(tmpDir, "20-bond0.10.network"))
+	if !contains(string(content), "Bridge = br10") {
+		t.Errorf("VLAN not enslaved to bridge: %s", content)
+	}
+
+	// Generating an existing file again is rejected without partial writes
+	w = post("/api/wizard/macvlan", map[string]interface{}{"name": "br10", "parent": "eno3"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected conflict to be rejected, got %d", w.Code)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "20-eno3.network")); err == nil {
+		t.Error("Parent network written despite conflict")
+	}
+	if w := post("/api/wizard/vxlan", map[string]interface{}{"name": "vx0", "vni": 0}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid spec to be rejected, got %d", w.Code)
+	}
+
+	// A file that cannot be read is not treated as absent
+	os.Mkdir(filepath.Join(tmpDir, "20-mv0.netdev"), 0755)
+	if w := post("/api/wizard/macvlan", map[string]interface{}{"name": "mv0", "parent": "eno3"}); w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected read failure to be a server error, got %d %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "20-mv0.network")); err == nil {
+		t.Error("Files written despite read failure")
+	}
+}
+
+func TestBatchAllOrNothing(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	svc.Schema.Schemas["netdev"] = map[string]interface{}{}
+	router := NewRouter(NewHandler(svc), "")
+
+	os.WriteFile(filepath.Join(tmpDir, "10-eth0.network"), []byte("[Match]\nName=eth0\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "90-old.network"), []byte("[Match]\nName=old0\n"), 0644)
+
+	post := func(req interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/batch", bytes.NewBuffer(body)))
+		return w
+	}
+	network := func(name string) map[string]interface{} {
+		return map[string]interface{}{"Match": map[string]interface{}{"Name": name}, "Network": map[string]interface{}{"DHCP": "yes"}}
+	}
+
+	// Conflict in the last operation: nothing is written
+	w := post(map[string]interface{}{"operations": []map[string]interface{}{
+		{"op": "create", "filename": "20-br0.netdev", "config": map[string]interface{}{"NetDev": map[string]interface{}{"Name": "br0", "Kind": "bridge"}}},
+		{"op": "update", "filename": "10-eth0.network", "config": network("eth0")},
+		{"op": "create", "filename": "90-old.network", "config": network("old0")},
+	}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected invalid batch to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "20-br0.netdev")); err == nil {
+		t.Error("Rejected batch wrote files")
+	}
+
+	// Write failure halfway: earlier changes are rolled back. The key file
+	// split off the last operation cannot replace a directory.
+	keyDir := filepath.Join(tmpDir, "30-wg0.WireGuard0.PrivateKey.key")
+	os.MkdirAll(filepath.Join(keyDir, "x"), 0755)
+	w = post(map[string]interface{}{"operations": []map[string]interface{}{
+		{"op": "update", "filename": "10-eth0.network", "config": network("eth0")},
+		{"op": "delete", "filename": "90-old.network"},
+		{"op": "create", "filename": "30-wg0.netdev", "secrets_to_files": true, "config": map[string]interface{}{
+			"NetDev":    map[string]interface{}{"Name": "wg0", "Kind": "wireguard"},
+			"WireGuard": map[string]interface{}{"PrivateKey": "aGVsbG8gd29ybGQgaGVsbG8gd29ybGQgaGVsbG8gd28="},
+		}},
+	}})
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected write failure, got %d %s", w.Code, w.Body.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "10-eth0.network"))
+	if contains(string(content), "DHCP") {
+		t.Errorf("Update not rolled back: %s", content)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "90-old.network")); err != nil {
+		t.Errorf("Delete not rolled back: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "30-wg0.netdev")); err == nil {
+		t.Error("Netdev written despite key file failure")
+	}
+	os.RemoveAll(keyDir)
+
+	w = post(map[string]interface{}{"operations": []map[string]interface{}{
+		{"op": "create", "filename": "20-br0.netdev", "config": map[string]interface{}{"NetDev": map[string]interface{}{"Name": "br0", "Kind": "bridge"}}},
+		{"op": "update", "filename": "10-eth0.network", "config": map[string]interface{}{"Match": map[string]interface{}{"Name": "eth0"}, "Network": map[string]interface{}{"Bridge": "br0"}}},
+		{"op": "delete", "filename": "90-old.network"},
+	}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Batch failed: %d %s", w.Code, w.Body.String())
+	}
+	content, _ = os.ReadFile(filepath.Join(tmpDir, "10-eth0.network"))
+	if !contains(string(content), "Bridge = br0") {
+		t.Errorf("Update not applied: %s", content)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "90-old.network")); err == nil {
+		t.Error("Delete not applied")
+	}
+}
+
+func TestImportNetplan(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	svc.Schema.Schemas["netdev"] = map[string]interface{}{}
+	router := NewRouter(NewHandler(svc), "")
+
+	yaml := "network:\n  version: 2\n  ethernets:\n    eth0:\n      dhcp4: true\n  bridges:\n    br0:\n      interfaces: [eth0]\n      parameters:\n        stp: false\n"
+	post := func(path string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"files": map[string]string{"50-cloud-init.yaml": yaml}})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewBuffer(body)))
+		return w
+	}
+
+	w := post("/api/import/netplan")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Preview failed: %d %s", w.Code, w.Body.String())
+	}
+	var res service.ImportResult
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if len(res.Files) != 3 || res.Applied {
+		t.Fatalf("Unexpected preview: %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "10-br0.netdev")); err == nil {
+		t.Error("Preview wrote files")
+	}
+
+	w = post("/api/import/netplan?apply=true")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Apply failed: %d %s", w.Code, w.Body.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "10-eth0.network"))
+	if !contains(string(content), "Bridge = br0") {
+		t.Errorf("Member not attached to bridge: %s", content)
+	}
+
+	// Existing files are only replaced with overwrite
+	if w = post("/api/import/netplan?apply=true"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected conflict, got %d", w.Code)
+	}
+	if w = post("/api/import/netplan?apply=true&overwrite=true"); w.Code != http.StatusCreated {
+		t.Errorf("Overwrite failed: %d %s", w.Code, w.Body.String())
+	}
+
+	if w = post("/api/import/unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown format, got %d", w.Code)
+	}
+}
+
+func TestImportIfupdown(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	svc.Schema.Schemas["netdev"] = map[string]interface{}{}
+	router := NewRouter(NewHandler(svc), "")
+
+	body, _ := json.Marshal(map[string]interface{}{"files": map[string]string{
+		"/etc/network/interfaces": "auto eth0\niface eth0 inet static\n  address 10.0.0.2/24\n  gateway 10.0.0.1\n  wpa-ssid office\n",
+	}})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/import/ifupdown?apply=true&prefix=30", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Import failed: %d %s", w.Code, w.Body.String())
+	}
+	var res service.ImportResult
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if len(res.Warnings) != 1 || !contains(res.Warnings[0], "wireless") {
+		t.Errorf("Expected a wireless warning, got %v", res.Warnings)
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "30-eth0.network"))
+	if !contains(string(content), "Address = 10.0.0.2/24") || !contains(string(content), "Gateway = 10.0.0.1") {
+		t.Errorf("Unexpected file: %s", content)
+	}
+}
+
+func TestImportNetworkManagerRedactsSecrets(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	svc.Schema.Schemas["netdev"] = map[string]interface{}{}
+	svc.SecretWriteOptions = service.WriteOptions{Mode: 0640}
+	router := NewRouter(NewHandler(svc), "")
+
+	key := "cHJpdmF0ZWtleXByaXZhdGVrZXlwcml2YXRla2V5MDA="
+	body, _ := json.Marshal(map[string]interface{}{"files": map[string]string{
+		"/etc/NetworkManager/system-connections/wg0.nmconnection": "[connection]\nid=wg0\ntype=wireguard\ninterface-name=wg0\n\n[wireguard]\nprivate-key=" + key + "\n",
+	}})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/import/networkmanager?apply=true", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Import failed: %d %s", w.Code, w.Body.String())
+	}
+	if contains(w.Body.String(), key) {
+		t.Errorf("Private key echoed in response: %s", w.Body.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "10-wg0.netdev"))
+	if !contains(string(content), "PrivateKey = "+key) {
+		t.Errorf("Private key not written: %s", content)
+	}
+}
+
+func TestImportSecretsHandling(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	svc.Schema.Schemas["netdev"] = map[string]interface{}{}
+	handler := NewHandler(svc)
+	handler.RevealToken = "s3cret-token"
+	router := NewRouter(handler, "")
+
+	key := "cHJpdmF0ZWtleXByaXZhdGVrZXlwcml2YXRla2V5MDA="
+	body, _ := json.Marshal(map[string]interface{}{"files": map[string]string{
+		"/etc/NetworkManager/system-connections/wg0.nmconnection": "[connection]\nid=wg0\ntype=wireguard\ninterface-name=wg0\n\n[wireguard]\nprivate-key=" + key + "\n",
+	}})
+	importNM := func(query string, reveal bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/import/networkmanager"+query, bytes.NewBuffer(body))
+		if reveal {
+			req.Header.Set("X-Reveal-Secrets", "s3cret-token")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := importNM("", false)
+	if w.Code != http.StatusOK || contains(w.Body.String(), key) {
+		t.Errorf("Preview must redact the private key: %d %s", w.Code, w.Body.String())
+	}
+	w = importNM("", true)
+	if w.Code != http.StatusOK || !contains(w.Body.String(), key) {
+		t.Errorf("Preview with X-Reveal-Secrets must include the private key: %d %s", w.Code, w.Body.String())
+	}
+
+	w = importNM("?apply=true&secrets_to_files=true", false)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Import failed: %d %s", w.Code, w.Body.String())
+	}
+	if contains(w.Body.String(), key) {
+		t.Errorf("Private key echoed in response: %s", w.Body.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "10-wg0.netdev"))
+	if contains(string(content), key) || !contains(string(content), "PrivateKeyFile = "+tmpDir) {
+		t.Errorf("Private key not moved to a key file: %s", content)
+	}
+	keys, _ := filepath.Glob(filepath.Join(tmpDir, "*.key"))
+	if len(keys) != 1 {
+		t.Fatalf("Expected one key file, got %v", keys)
+	}
+	if data, _ := os.ReadFile(keys[0]); string(data) != key+"\n" {
+		t.Errorf("Unexpected key file content: %q", data)
+	}
+}
+
+func TestExportImportBundle(t *testing.T) {
+	src, srcDir := setupTestService(t)
+	src.Schema.Schemas["netdev"] = map[string]interface{}{}
+	os.WriteFile(filepath.Join(srcDir, "10-eth0.network"), []byte("[Match]\nName=eth0\n\n[Network]\nDHCP=yes\n"), 0644)
+	os.MkdirAll(filepath.Join(srcDir, "10-eth0.network.d"), 0755)
+	os.WriteFile(filepath.Join(srcDir, "10-eth0.network.d", "mtu.conf"), []byte("[Link]\nMTUBytes=9000\n"), 0644)
+	os.WriteFile(filepath.Join(srcDir, "50-wg0.netdev"), []byte("[NetDev]\nName=wg0\nKind=wireguard\n\n[WireGuard]\nPrivateKey=c2VjcmV0\n"), 0644)
+	keyPath := filepath.Join(srcDir, "60-wg1.WireGuard0.PrivateKey.key")
+	os.WriteFile(filepath.Join(srcDir, "60-wg1.netdev"), []byte("[NetDev]\nName=wg1\nKind=wireguard\n\n[WireGuard]\nPrivateKeyFile="+keyPath+"\n"), 0644)
+	os.WriteFile(keyPath, []byte("a2V5ZmlsZQ==\n"), 0600)
+
+	w := httptest.NewRecorder()
+	NewRouter(NewHandler(src), "").ServeHTTP(w, httptest.NewRequest("GET", "/api/system/export", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("Export failed: %d %s", w.Code, w.Body.String())
+	}
+	archive := w.Body.Bytes()
+	bundle, err := service.ReadBundle(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Files) != 4 || !bundle.Metadata.SecretsRedacted {
+		t.Fatalf("Unexpected bundle: %+v", bundle)
+	}
+	if len(bundle.Metadata.MissingKeyFiles) != 1 || bundle.Metadata.MissingKeyFiles[0] != keyPath {
+		t.Errorf("Key file not reported as missing: %v", bundle.Metadata.MissingKeyFiles)
+	}
+	for _, f := range bundle.Files {
+		if contains(f.Content, "c2VjcmV0") || contains(f.Content, "a2V5ZmlsZQ") {
+			t.Errorf("Secret exported without X-Reveal-Secrets: %s", f.Content)
+		}
+	}
+
+	dst, dstDir := setupTestService(t)
+	dst.Schema.Schemas["netdev"] = map[string]interface{}{}
+	dst.Schema.Schemas["networkd-conf"] = map[string]interface{}{}
+	// Ownership changes need root; the test only checks the content
+	dst.SecretWriteOptions = service.WriteOptions{Mode: 0640}
+	dst.KeyFileWriteOptions = service.WriteOptions{Mode: 0600}
+	router := NewRouter(NewHandler(dst), "")
+	post := func(path string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewBuffer(body)))
+		return w
+	}
+
+	// Redacted secrets cannot be written to a host that does not have them
+	if w = post("/api/system/import?apply=true&skip_global=true", archive); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected redacted bundle to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/api/system/export?format=json", nil)
+	req.Header.Set("X-Reveal-Secrets", "s3cret-token")
+	w = httptest.NewRecorder()
+	srcHandler := NewHandler(src)
+	srcHandler.RevealToken = "s3cret-token"
+	NewRouter(srcHandler, "").ServeHTTP(w, req)
+	full := w.Body.Bytes()
+
+	if w = post("/api/system/import?rename=eth0:ens3", full); w.Code != http.StatusOK {
+		t.Fatalf("Preview failed: %d %s", w.Code, w.Body.String())
+	}
+
+	// networkd.conf is written last; when that fails, the files are
+	// rolled back
+	globalDir := filepath.Join(t.TempDir(), "networkd.conf")
+	os.Mkdir(globalDir, 0755)
+	os.WriteFile(filepath.Join(globalDir, "keep"), nil, 0644)
+	dst.LocalConnector.GlobalConfigPath = globalDir
+	if w = post("/api/system/import?apply=true&rename=eth0:ens3", full); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected networkd.conf write failure, got %d %s", w.Code, w.Body.String())
+	}
+	for _, pattern := range []string{"*.network", "*.netdev", "*.key", "*.d/*"} {
+		if left, _ := filepath.Glob(filepath.Join(dstDir, pattern)); len(left) > 0 {
+			t.Errorf("Files not rolled back: %v", left)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "10-ens3.network")); err == nil {
+		t.Error("Preview wrote files")
+	}
+	if w = post("/api/system/import?apply=true&skip_global=true&rename=eth0:ens3", full); w.Code != http.StatusCreated {
+		t.Fatalf("Import failed: %d %s", w.Code, w.Body.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(dstDir, "10-ens3.network"))
+	if !contains(string(content), "Name = ens3") {
+		t.Errorf("Interface not renamed: %s", content)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "10-ens3.network.d", "mtu.conf")); err != nil {
+		t.Errorf("Drop-in not imported: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dstDir, filepath.Base(keyPath))); string(content) != "a2V5ZmlsZQ==\n" {
+		t.Errorf("Key file not imported: %q", content)
+	}
+	content, _ = os.ReadFile(filepath.Join(dstDir, "50-wg0.netdev"))
+	if !contains(string(content), "c2VjcmV0") {
+		t.Errorf("Secret not imported: %s", content)
+	}
+	if contains(w.Body.String(), "c2VjcmV0") {
+		t.Error("Secret echoed in import response")
+	}
+}
+
+func TestManifestApply(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	svc.Schema.Schemas["netdev"] = map[string]interface{}{}
+	svc.SecretWriteOptions = service.WriteOptions{Mode: 0640}
+	os.WriteFile(filepath.Join(tmpDir, "10-eth0.network"), []byte("[Match]\nName=eth0\n\n[Network]\nDHCP=yes\n"), 0644)
+	os.MkdirAll(filepath.Join(tmpDir, "10-eth0.network.d"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "10-eth0.network.d", "mtu.conf"), []byte("[Link]\nMTUBytes=9000\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "20-eth1.network"), []byte("[Match]\nName=eth1\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "50-wg0.netdev"), []byte("[NetDev]\nName=wg0\nKind=wireguard\n\n[WireGuard]\nPrivateKey=c2VjcmV0\n"), 0644)
+	router := NewRouter(NewHandler(svc), "")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/manifest", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/yaml" {
+		t.Fatalf("GetManifest failed: %d %s", w.Code, w.Body.String())
+	}
+	if contains(w.Body.String(), "c2VjcmV0") || !contains(w.Body.String(), "10-eth0.network") || !contains(w.Body.String(), "10-eth0.network.d/mtu.conf") {
+		t.Fatalf("Unexpected manifest: %s", w.Body.String())
+	}
+
+	// Edit eth0, add eth2, drop eth1 and keep the redacted WireGuard key
+	manifest := `apiVersion: networkd-api/v1
+kind: NetworkdManifest
+host: local
+files:
+  10-eth0.network:
+    Match: {Name: eth0}
+    Network: {DHCP: "no"}
+  10-eth0.network.d/mtu.conf:
+    Link: {MTUBytes: "1400"}
+  30-eth2.network:
+    Match: {Name: eth2}
+  50-wg0.netdev:
+    NetDev: {Name: wg0, Kind: wireguard}
+    WireGuard: {PrivateKey: "` + service.RedactedValue + `"}
+`
+	apply := func(query string) (*httptest.ResponseRecorder, service.ManifestPlan) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/manifest/apply"+query, bytes.NewBufferString(manifest)))
+		var plan service.ManifestPlan
+		json.NewDecoder(w.Body).Decode(&plan)
+		return w, plan
+	}
+
+	w, plan := apply("?dry_run=true")
+	if w.Code != http.StatusOK || plan.Applied || len(plan.Changes) != 3 {
+		t.Fatalf("Unexpected dry run: %d %+v", w.Code, plan)
+	}
+	if len(plan.Unchanged) != 1 || plan.Unchanged[0] != "50-wg0.netdev" {
+		t.Errorf("Expected redacted secret to count as unchanged: %+v", plan.Unchanged)
+	}
+	if len(plan.Unmanaged) != 1 || plan.Unmanaged[0] != "20-eth1.network" {
+		t.Errorf("Expected eth1 to be unmanaged: %+v", plan.Unmanaged)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "30-eth2.network")); err == nil {
+		t.Error("Dry run wrote files")
+	}
+
+	w, plan = apply("?prune=true")
+	if w.Code != http.StatusOK || !plan.Applied || len(plan.Changes) != 4 {
+		t.Fatalf("Unexpected apply: %d %+v", w.Code, plan)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "20-eth1.network")); !os.IsNotExist(err) {
+		t.Error("Pruned file still exists")
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "10-eth0.network"))
+	if !contains(string(content), "DHCP = no") {
+		t.Errorf("eth0 not updated: %s", content)
+	}
+	content, _ = os.ReadFile(filepath.Join(tmpDir, "10-eth0.network.d", "mtu.conf"))
+	if !contains(string(content), "MTUBytes = 1400") {
+		t.Errorf("Drop-in not updated: %s", content)
+	}
+	content, _ = os.ReadFile(filepath.Join(tmpDir, "50-wg0.netdev"))
+	if !contains(string(content), "c2VjcmV0") {
+		t.Errorf("Stored secret lost: %s", content)
+	}
+
+	// Applying the same manifest again is a no-op
+	if _, plan = apply("?prune=true"); len(plan.Changes) != 0 || plan.Applied {
+		t.Errorf("Expected no changes on re-apply: %+v", plan)
+	}
+
+	other := httptest.NewRecorder()
+	router.ServeHTTP(other, httptest.NewRequest("POST", "/api/manifest/apply?host=remote", bytes.NewBufferString(manifest)))
+	if other.Code != http.StatusBadRequest {
+		t.Errorf("Expected host mismatch to be rejected, got %d", other.Code)
+	}
+}
+
+func TestIntentCompileAndSummarize(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	svc.Schema.Schemas["netdev"] = map[string]interface{}{}
+	os.WriteFile(filepath.Join(tmpDir, "90-wg0.netdev"), []byte("[NetDev]\nName=wg0\nKind=wireguard\n"), 0644)
+	router := NewRouter(NewHandler(svc), "")
+
+	intent := `
+interfaces:
+  - {name: eth0, role: uplink, dhcp: ipv4}
+  - {name: bond0, type: bond, members: [eth1, eth2]}
+  - {type: vlan, parent: bond0, vlan_id: 10, addresses: [10.0.10.5/24], mtu: 1400}
+routes:
+  - {via: uplink, metric: 100}
+`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/intent/compile", bytes.NewBufferString(intent)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Compile failed: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "10-eth0.network")); err == nil {
+		t.Error("Compile without apply wrote files")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/intent/compile?apply=true", bytes.NewBufferString(intent)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Apply failed: %d %s", w.Code, w.Body.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "10-eth1.network"))
+	if !contains(string(content), "Bond = bond0") {
+		t.Errorf("eth1 not enslaved: %s", content)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/intent?format=json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Summarize failed: %d %s", w.Code, w.Body.String())
+	}
+	var summary service.IntentSummary
+	json.NewDecoder(w.Body).Decode(&summary)
+	byName := map[string]service.InterfaceIntent{}
+	for _, ifc := range summary.Intent.Interfaces {
+		byName[ifc.Name] = ifc
+	}
+	// Bond members are folded into the bond
+	if len(byName) != 3 {
+		t.Fatalf("Unexpected interfaces: %+v", summary.Intent.Interfaces)
+	}
+	if bond := byName["bond0"]; bond.Type != "bond" || len(bond.Members) != 2 {
+		t.Errorf("Unexpected bond: %+v", bond)
+	}
+	if vlan := byName["bond0.10"]; vlan.Parent != "bond0" || vlan.VLANID != 10 || vlan.MTU != 1400 {
+		t.Errorf("Unexpected VLAN: %+v", vlan)
+	}
+	if len(summary.Intent.Routes) != 1 || summary.Intent.Routes[0].Metric != 100 {
+		t.Errorf("Unexpected routes: %+v", summary.Intent.Routes)
+	}
+	if len(summary.Warnings) != 1 || !contains(summary.Warnings[0], "wireguard") {
+		t.Errorf("Expected a warning for the WireGuard netdev: %v", summary.Warnings)
+	}
+}
+
+func TestIPAMPoolAddresses(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	// Listing local links may fail in a sandbox
+	svc.IPAMIgnoreScanErrors = true
+	os.WriteFile(filepath.Join(tmpDir, "10-eth5.network"), []byte("[Match]\nName=eth5\n\n[Network]\nAddress=10.0.10.2/24\n"), 0644)
+	router := NewRouter(NewHandler(svc), "")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		return w
+	}
+
+	if w := do("POST", "/api/ipam/pools", `{"name": "mgmt", "prefix": "10.0.10.0/24", "gateway": "10.0.10.1"}`); w.Code != http.StatusCreated {
+		t.Fatalf("CreatePool failed: %d %s", w.Code, w.Body.String())
+	}
+
+	// .1 is the gateway and .2 is configured on eth5
+	w := do("POST", "/api/networks", `{"filename": "20-eth6", "config": {"Match": {"Name": "eth6"}, "Network": {"Address": "ipam:mgmt", "Gateway": "ipam:mgmt"}}}`)
+	if w.Code != http.StatusCreated || !contains(w.Body.String(), "10.0.10.3/24") {
+		t.Fatalf("Create with pool address failed: %d %s", w.Code, w.Body.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "20-eth6.network"))
+	if !contains(string(content), "Address = 10.0.10.3/24") || !contains(string(content), "Gateway = 10.0.10.1") {
+		t.Errorf("Pool address not written: %s", content)
+	}
+
+	// A failed request gives the addresses it took back
+	if w := do("POST", "/api/networks", `{"filename": "20-eth7", "config": {"Match": {"Name": "eth7"}, "Network": {"Address": ["ipam:mgmt", "ipam:nope"]}}}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected unknown pool to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = do("GET", "/api/ipam/pools/mgmt", "")
+	var status service.PoolStatus
+	json.NewDecoder(w.Body).Decode(&status)
+	if len(status.Allocations) != 1 || status.Allocations[0].Interface != "eth6" {
+		t.Fatalf("Unexpected allocations: %+v", status.Allocations)
+	}
+	if len(status.Conflicts) != 1 || status.Conflicts[0].Kind != "unallocated" || status.Conflicts[0].Address != "10.0.10.2" {
+		t.Errorf("Expected eth5's address to be reported: %+v", status.Conflicts)
+	}
+
+	if w := do("POST", "/api/ipam/pools/mgmt/allocations", `{"host": "local", "interface": "eth9", "address": "10.0.10.2"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected used address to be refused, got %d", w.Code)
+	}
+	if w := do("DELETE", "/api/ipam/pools/mgmt/allocations/10.0.10.3", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Release failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("DELETE", "/api/ipam/pools/mgmt", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DeletePool failed: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFleetConflicts(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	os.WriteFile(filepath.Join(tmpDir, "10-eth1.network"), []byte("[Match]\nName=eth1\n\n[Network]\nAddress=10.9.0.1/24\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "10-eth2.network"), []byte("[Match]\nName=eth2\n\n[Address]\nAddress=10.9.0.1/24\n"), 0644)
+
+	w := httptest.NewRecorder()
+	NewRouter(NewHandler(svc), "").ServeHTTP(w, httptest.NewRequest("GET", "/api/fleet/conflicts", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetFleetConflicts failed: %d %s", w.Code, w.Body.String())
+	}
+	var scan service.FleetScan
+	json.NewDecoder(w.Body).Decode(&scan)
+	if len(scan.Hosts) != 1 || scan.Hosts[0] != "local" {
+		t.Errorf("Unexpected hosts %v", scan.Hosts)
+	}
+	var found *service.FleetConflict
+	for i, c := range scan.Conflicts {
+		if c.Kind == "address" && c.Value == "10.9.0.1" {
+			found = &scan.Conflicts[i]
+		}
+	}
+	if found == nil || found.Severity != service.SeverityWarning || found.Segment != "10.9.0.0/24" || len(found.Uses) != 2 {
+		t.Fatalf("Expected duplicate address to be reported: %+v", scan.Conflicts)
+	}
+	if found.Uses[0].Filename != "10-eth1.network" || found.Uses[1].Filename != "10-eth2.network" {
+		t.Errorf("Files not reported: %+v", found.Uses)
+	}
+}
+
+func TestGlobalConfigDropins(t *testing.T) {
+	svc, tmpDir := setupTestService(t)
+	globalPath := filepath.Join(tmpDir, "networkd.conf")
+	svc.LocalConnector.GlobalConfigPath = globalPath
+	os.WriteFile(globalPath, []byte("[Network]\nManageForeignRoutes=no\nSpeedMeter=yes\n"), 0644)
+	svc.Schema.Schemas["networkd-conf"] = map[string]interface{}{}
+	router := NewRouter(NewHandler(svc), "")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		return w
+	}
+
+	if w := do("PUT", "/api/system/config/dropins/50-routes.conf", `{"config": {"Network": {"ManageForeignRoutes": "yes"}}}`); w.Code != http.StatusOK {
+		t.Fatalf("SaveGlobalDropin failed: %d %s", w.Code, w.Body.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "networkd.conf.d", "50-routes.conf"))
+	if !contains(string(content), "ManageForeignRoutes = yes") {
+		t.Errorf("Drop-in not written: %s", content)
+	}
+	if w := do("PUT", "/api/system/config/dropins/routes.txt", `{"config": {}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid name to be rejected, got %d", w.Code)
+	}
+
+	w := do("GET", "/api/system/config/dropins", "")
+	var dropins []service.GlobalDropin
+	json.NewDecoder(w.Body).Decode(&dropins)
+	if len(dropins) != 1 || dropins[0].Name != "50-routes.conf" {
+		t.Fatalf("Unexpected drop-ins: %+v", dropins)
+	}
+
+	w = do("GET", "/api/system/config/merged", "")
+	var merged service.MergedGlobalConfig
+	json.NewDecoder(w.Body).Decode(&merged)
+	network, _ := merged.Config["Network"].(map[string]interface{})
+	if network["ManageForeignRoutes"] != "yes" || network["SpeedMeter"] != "yes" {
+		t.Errorf("Unexpected merged config: %v", merged.Config)
+	}
+	if merged.Sources["Network.ManageForeignRoutes"] != dropins[0].Path || merged.Sources["Network.SpeedMeter"] != globalPath {
+		t.Errorf("Unexpected sources: %v", merged.Sources)
+	}
+
+	if w := do("DELETE", "/api/system/config/dropins/50-routes.conf", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DeleteGlobalDropin failed: %d", w.Code)
+	}
+	if w := do("GET", "/api/system/config/dropins/50-routes.conf", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected deleted drop-in to be gone, got %d", w.Code)
+	}
+}
+
+func TestUpdateHost(t *testing.T) {
+	svc, _ := setupTestService(t)
+	router := NewRouter(NewHandler(svc), "")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		return w
+	}
+
+	if w := do("POST", "/api/system/hosts", `{"name": "edge", "host": "192.0.2.10"}`); w.Code != http.StatusCreated {
+		t.Fatalf("AddHost failed: %d %s", w.Code, w.Body.String())
+	}
+	conn, err := svc.GetConnector("edge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.GetConfigDir() != "/etc/systemd/network" {
+		t.Errorf("Unexpected default config dir %s", conn.GetConfigDir())
+	}
+
+	w := do("PUT", "/api/system/hosts/edge", `{"host": "192.0.2.10", "config_dir": "/run/systemd/network", "privilege": "doas", "command_timeout": 30}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("UpdateHost failed: %d %s", w.Code, w.Body.String())
+	}
+	host, _ := svc.HostManager.GetHost("edge")
+	if host.User != "networkd-api" || host.Port != 22 || host.Privilege != service.PrivilegeDoas || host.CommandTimeout != 30 {
+		t.Errorf("Unexpected stored host: %+v", host)
+	}
+	conn, _ = svc.GetConnector("edge")
+	if conn.GetConfigDir() != "/run/systemd/network" {
+		t.Errorf("Connector not rebuilt with new settings: %s", conn.GetConfigDir())
+	}
+
+	if w := do("PUT", "/api/system/hosts/edge", `{"host": "192.0.2.10", "privilege": "su"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid privilege to be rejected, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/system/hosts/edge", `{"host": "192.0.2.10", "key_file": "/etc/ssh/ssh_host_ed25519_key"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected key file outside the key dir to be rejected, got %d", w.Code)
+	}
+	if w := do("POST", "/api/system/hosts", `{"name": "core"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected missing host to be rejected, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/system/hosts/edge", `{"name": "core", "host": "192.0.2.10"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected rename to be rejected, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/system/hosts/missing", `{"host": "192.0.2.99"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown host to be 404, got %d", w.Code)
+	}
+}
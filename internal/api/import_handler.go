@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"networkd-api/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type importRequest struct {
+	// Files maps source paths to their contents; when empty, the files are
+	// read from the target host
+	Files map[string]string `json:"files"`
+}
+
+// ImportConfig handles POST /api/import/{format}: converts another system's
+// network configuration into networkd files. Without ?apply=true it only
+// previews; ?overwrite=true allows replacing existing files, ?prefix= sets
+// the filename prefix (default 10) and ?secrets_to_files=true writes key
+// material to key files. Secrets are redacted unless the request carries the
+// reveal token.
+func (h *Handler) ImportConfig(w http.ResponseWriter, r *http.Request) {
+	reveal, ok := h.revealSecrets(w, r)
+	if !ok {
+		return
+	}
+	var req importRequest
+	// An empty body imports from the host itself
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	host := getHost(r)
+	opts := importOptions(r)
+
+	var result *service.ImportResult
+	var err error
+	switch chi.URLParam(r, "format") {
+	case "netplan":
+		result, err = h.Service.ImportNetplan(host, req.Files, opts)
+	case "ifupdown":
+		result, err = h.Service.ImportIfupdown(host, req.Files, opts)
+	case "networkmanager":
+		result, err = h.Service.ImportNetworkManager(host, req.Files, opts)
+	default:
+		http.Error(w, "Unknown import format", http.StatusNotFound)
+		return
+	}
+	h.writeImportResult(w, result, err, reveal)
+}
+
+func importOptions(r *http.Request) service.ImportOptions {
+	q := r.URL.Query()
+	return service.ImportOptions{
+		Prefix:         q.Get("prefix"),
+		Apply:          q.Get("apply") == "true",
+		Overwrite:      q.Get("overwrite") == "true",
+		SecretsToFiles: q.Get("secrets_to_files") == "true",
+	}
+}
+
+// generateStatus maps errors of imports and wizards: invalid input and
+// unknown pools are the caller's fault, everything else (connector, write
+// and allocation failures) keeps its IPAM or server error status.
+func generateStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidImport) || errors.Is(err, service.ErrInvalidConfig) || errors.Is(err, service.ErrPoolNotFound) {
+		return http.StatusBadRequest
+	}
+	return ipamStatus(err)
+}
+
+// writeImportResult sends the generated files, with their key material
+// redacted unless the caller asked for it.
+func (h *Handler) writeImportResult(w http.ResponseWriter, result *service.ImportResult, err error, reveal bool) {
+	if err != nil {
+		http.Error(w, err.Error(), generateStatus(err))
+		return
+	}
+	if !reveal {
+		for _, f := range result.Files {
+			service.RedactSecrets(f.Config, h.Service.Schema, f.Type)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if result.Applied {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"networkd-api/internal/service"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxIntentSize bounds intent documents.
+const maxIntentSize = 1 << 20
+
+// GetIntent handles GET /api/intent: the host's configuration summarised in
+// the intent model, with warnings for what it cannot express. YAML by
+// default, ?format=json for JSON.
+func (h *Handler) GetIntent(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.Service.SummarizeIntent(getHost(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch r.URL.Query().Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	case "", "yaml":
+		data, err := yaml.Marshal(summary)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(data)
+	default:
+		http.Error(w, "Unknown format, expected yaml or json", http.StatusBadRequest)
+	}
+}
+
+// CompileIntent handles POST /api/intent/compile with a YAML or JSON intent
+// as the body. Without ?apply=true the generated files are only returned;
+// the other query parameters and secret handling match ImportConfig.
+func (h *Handler) CompileIntent(w http.ResponseWriter, r *http.Request) {
+	reveal, ok := h.revealSecrets(w, r)
+	if !ok {
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxIntentSize+1))
+	if err != nil || len(data) > maxIntentSize {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	intent, err := service.ParseIntent(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Service.CompileIntent(getHost(r), intent, importOptions(r))
+	h.writeImportResult(w, result, err, reveal)
+}
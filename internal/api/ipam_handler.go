@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"networkd-api/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func ipamStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidPool):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrPoolNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAddressInUse):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrScanIncomplete):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// ListPools handles GET /api/ipam/pools.
+func (h *Handler) ListPools(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Service.IPAM.ListPools())
+}
+
+// CreatePool handles POST /api/ipam/pools.
+func (h *Handler) CreatePool(w http.ResponseWriter, r *http.Request) {
+	var pool service.IPAMPool
+	if err := json.NewDecoder(r.Body).Decode(&pool); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	created, err := h.Service.IPAM.AddPool(pool)
+	if err != nil {
+		http.Error(w, err.Error(), ipamStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetPool handles GET /api/ipam/pools/{name}: the pool with the addresses
+// in use across all managed hosts and the conflicts found.
+func (h *Handler) GetPool(w http.ResponseWriter, r *http.Request) {
+	status, err := h.Service.GetPoolStatus(chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, err.Error(), ipamStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// DeletePool handles DELETE /api/ipam/pools/{name}; pools with allocations
+// need ?force=true.
+func (h *Handler) DeletePool(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.IPAM.RemovePool(chi.URLParam(r, "name"), r.URL.Query().Get("force") == "true"); err != nil {
+		http.Error(w, err.Error(), ipamStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AllocateAddress handles POST /api/ipam/pools/{name}/allocations. The body
+// names the host and interface; with "address" set that address is claimed,
+// otherwise the next free one is.
+func (h *Handler) AllocateAddress(w http.ResponseWriter, r *http.Request) {
+	var req service.IPAMAllocation
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	alloc, err := h.Service.AllocateAddress(chi.URLParam(r, "name"), req)
+	if err != nil {
+		http.Error(w, err.Error(), ipamStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(alloc)
+}
+
+// ReleaseAddress handles DELETE /api/ipam/pools/{name}/allocations/{address}.
+func (h *Handler) ReleaseAddress(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.IPAM.Release(chi.URLParam(r, "name"), chi.URLParam(r, "address")); err != nil {
+		http.Error(w, err.Error(), ipamStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAddressUsage handles GET /api/ipam/usage: every configured and runtime
+// address on all managed hosts.
+func (h *Handler) GetAddressUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Service.ScanAddressUsage())
+}
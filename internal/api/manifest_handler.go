@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"networkd-api/internal/service"
+)
+
+// GetManifest handles GET /api/manifest: all of the host's config files as
+// one document keyed by filename, in YAML (default) or with ?format=json.
+// Secrets are redacted unless the request carries the reveal token.
+func (h *Handler) GetManifest(w http.ResponseWriter, r *http.Request) {
+	reveal, ok := h.revealSecrets(w, r)
+	if !ok {
+		return
+	}
+	m, err := h.Service.BuildManifest(getHost(r), reveal)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch r.URL.Query().Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m)
+	case "", "yaml":
+		data, err := m.YAML()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(data)
+	default:
+		http.Error(w, "Unknown format, expected yaml or json", http.StatusBadRequest)
+	}
+}
+
+// ApplyManifest handles POST /api/manifest/apply with a YAML or JSON
+// manifest as the body. The host is brought in line with it in one batch;
+// ?dry_run=true only returns the plan, ?prune=true deletes files missing
+// from the manifest and ?reload=true reloads networkd afterwards.
+func (h *Handler) ApplyManifest(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBundleSize+1))
+	if err != nil || len(data) > maxBundleSize {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	m, err := service.ParseManifest(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	plan, err := h.Service.ApplyManifest(getHost(r), m, service.ManifestOptions{
+		Prune:  q.Get("prune") == "true",
+		DryRun: q.Get("dry_run") == "true",
+		Reload: q.Get("reload") == "true",
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidBatch) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
@@ -20,7 +20,7 @@ func NewRouter(h *Handler, staticDir string) http.Handler {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5173", "http://localhost:3000"}, // Vite default and others
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Target-Host"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Target-Host", "X-Reveal-Secrets"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -32,37 +32,93 @@ func NewRouter(h *Handler, staticDir string) http.Handler {
 		// NetDevs (.netdev)
 		r.Get("/netdevs", h.ListNetDevs)
 		r.Post("/netdevs", h.CreateNetDev)
-		r.Get("/netdevs/{filename}", h.GetConfig)
+		r.Get("/netdevs/{filename}", h.GetNetDev)
 		r.Put("/netdevs/{filename}", h.UpdateNetDev)
-		r.Delete("/netdevs/{filename}", h.DeleteConfig)
+		r.Delete("/netdevs/{filename}", h.DeleteNetDev)
+		r.Post("/netdevs/{filename}/peers", h.AddWireGuardPeer)
+		r.Delete("/netdevs/{filename}/peers", h.RemoveWireGuardPeer)
 
 		// Networks (.network)
 		r.Get("/networks", h.ListNetworks)
 		r.Post("/networks", h.CreateNetwork)
-		r.Get("/networks/{filename}", h.GetConfig)
+		r.Get("/networks/{filename}", h.GetNetwork)
 		r.Put("/networks/{filename}", h.UpdateNetwork)
-		r.Delete("/networks/{filename}", h.DeleteConfig)
+		r.Delete("/networks/{filename}", h.DeleteNetwork)
 
 		// Links (.link)
 		r.Get("/links", h.ListLinks)
 		r.Post("/links", h.CreateLink)
-		r.Get("/links/{filename}", h.GetConfig)
+		r.Post("/links/preview", h.PreviewLinkRename)
+		r.Get("/links/{filename}", h.GetLink)
 		r.Put("/links/{filename}", h.UpdateLink)
-		r.Delete("/links/{filename}", h.DeleteConfig)
+		r.Delete("/links/{filename}", h.DeleteLink)
+
+		// WireGuard
+		r.Post("/wireguard/keys", h.GenerateWireGuardKeys)
+		r.Post("/wireguard/pubkey", h.DeriveWireGuardPublicKey)
+		r.Post("/wireguard/link", h.LinkWireGuardHosts)
+
+		// Multi-file changes, applied all-or-nothing
+		r.Post("/batch", h.ApplyBatch)
+
+		// Declarative manifests (GitOps)
+		r.Get("/manifest", h.GetManifest)
+		r.Post("/manifest/apply", h.ApplyManifest)
+
+		// IP address management pools
+		r.Get("/ipam/pools", h.ListPools)
+		r.Post("/ipam/pools", h.CreatePool)
+		r.Get("/ipam/pools/{name}", h.GetPool)
+		r.Delete("/ipam/pools/{name}", h.DeletePool)
+		r.Post("/ipam/pools/{name}/allocations", h.AllocateAddress)
+		r.Delete("/ipam/pools/{name}/allocations/{address}", h.ReleaseAddress)
+		r.Get("/ipam/usage", h.GetAddressUsage)
+
+		// Fleet-wide duplicate address and MAC detection
+		r.Get("/fleet/conflicts", h.GetFleetConflicts)
+
+		// High-level intents, compiled to and summarised from config files
+		r.Get("/intent", h.GetIntent)
+		r.Post("/intent/compile", h.CompileIntent)
+
+		// Topology wizards (bond, bridge, vlan, vrf, macvlan, vxlan)
+		r.Post("/wizard/{kind}", h.RunWizard)
+
+		// Import from other network configuration systems (preview or apply)
+		r.Post("/import/{format}", h.ImportConfig)
 
 		// System Management
 		r.Get("/system/status", h.GetSystemStatus)
+		r.Get("/system/links/{name}", h.GetLinkDetails)
+		r.Get("/system/links/{name}/udev", h.GetUdevInfo)
+		r.Post("/system/links/{name}/{action}", h.RunLinkAction)
 		r.Get("/system/config", h.GetGlobalConfig)
 		r.Put("/system/config", h.SaveGlobalConfig)
+		r.Get("/system/config/merged", h.GetMergedGlobalConfig)
+		r.Get("/system/config/dropins", h.ListGlobalDropins)
+		r.Get("/system/config/dropins/{name}", h.GetGlobalDropin)
+		r.Put("/system/config/dropins/{name}", h.SaveGlobalDropin)
+		r.Delete("/system/config/dropins/{name}", h.DeleteGlobalDropin)
+		r.Get("/system/export", h.ExportBundle)
+		r.Post("/system/import", h.ImportBundle)
 		r.Post("/system/reload", h.ReloadNetworkd)
 		r.Get("/system/reconfigure", h.ReconfigureSystem)
 		r.Post("/system/reconfigure", h.ReconfigureSystem)
 		r.Get("/system/ssh-key", h.GetPublicSSHKey)
+		r.Get("/system/remote-helper", h.GetRemoteHelper)
 		r.Get("/system/routes", h.GetRoutes)
+		r.Get("/system/tunnels", h.GetTunnels)
+		r.Get("/system/lldp", h.GetLLDPNeighbors)
+		r.Get("/system/resolved", h.GetResolvedStatus)
+		r.Get("/topology", h.GetTopology)
+		r.Get("/system/stats", h.GetInterfaceStats)
+		r.Get("/system/dhcp-leases", h.GetDHCPServerLeases)
+		r.Post("/system/dhcp-leases/static", h.MakeDHCPServerLeaseStatic)
 		r.Get("/system/logs", h.GetLogs)
 
 		r.Get("/system/hosts", h.ListHosts)
 		r.Post("/system/hosts", h.AddHost)
+		r.Put("/system/hosts/{name}", h.UpdateHost)
 		r.Delete("/system/hosts/{name}", h.RemoveHost)
 	})
 
@@ -2,8 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"networkd-api/internal/service"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func (h *Handler) GetGlobalConfig(w http.ResponseWriter, r *http.Request) {
@@ -26,36 +33,116 @@ func (h *Handler) GetGlobalConfig(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) SaveGlobalConfig(w http.ResponseWriter, r *http.Request) {
+	content, ok := h.renderGlobalConfig(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Service.SaveGlobalConfig(getHost(r), content); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Configuration saved"})
+}
+
+// renderGlobalConfig decodes a {"config": ...} body, validates it against
+// the networkd-conf schema and renders it as INI.
+func (h *Handler) renderGlobalConfig(w http.ResponseWriter, r *http.Request) (string, bool) {
 	var req struct {
 		Config map[string]interface{} `json:"config"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid body", http.StatusBadRequest)
-		return
+		return "", false
 	}
 	if req.Config == nil {
 		http.Error(w, "Config is required", http.StatusBadRequest)
-		return
+		return "", false
 	}
 
 	if err := h.Service.Schema.Validate("networkd-conf", req.Config); err != nil {
 		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
-		return
+		return "", false
 	}
 
 	content, err := service.MapToINI(req.Config, h.Service.Schema, "networkd-conf")
 	if err != nil {
 		http.Error(w, "Conversion failed: "+err.Error(), http.StatusBadRequest)
-		return
+		return "", false
 	}
+	return content, true
+}
 
-	if err := h.Service.SaveGlobalConfig(getHost(r), content); err != nil {
+// dropinStatus is 400 for invalid drop-in names and status otherwise.
+func dropinStatus(err error, status int) int {
+	if errors.Is(err, service.ErrInvalidDropinName) {
+		return http.StatusBadRequest
+	}
+	return status
+}
+
+// ListGlobalDropins handles GET /api/system/config/dropins.
+func (h *Handler) ListGlobalDropins(w http.ResponseWriter, r *http.Request) {
+	dropins, err := h.Service.ListGlobalDropins(getHost(r))
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dropins)
+}
 
+// GetGlobalDropin handles GET /api/system/config/dropins/{name}.
+func (h *Handler) GetGlobalDropin(w http.ResponseWriter, r *http.Request) {
+	content, err := h.Service.ReadGlobalDropin(getHost(r), chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, err.Error(), dropinStatus(err, http.StatusNotFound))
+		return
+	}
+	config, err := service.INIToMap(content, h.Service.Schema, "networkd-conf")
+	if err != nil {
+		http.Error(w, "Failed to parse config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Configuration saved"})
+	json.NewEncoder(w).Encode(config)
+}
+
+// SaveGlobalDropin handles PUT /api/system/config/dropins/{name}, creating
+// or replacing the drop-in.
+func (h *Handler) SaveGlobalDropin(w http.ResponseWriter, r *http.Request) {
+	content, ok := h.renderGlobalConfig(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Service.WriteGlobalDropin(getHost(r), chi.URLParam(r, "name"), content); err != nil {
+		http.Error(w, err.Error(), dropinStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Drop-in saved"})
+}
+
+// DeleteGlobalDropin handles DELETE /api/system/config/dropins/{name}.
+func (h *Handler) DeleteGlobalDropin(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.DeleteGlobalDropin(getHost(r), chi.URLParam(r, "name")); err != nil {
+		http.Error(w, err.Error(), dropinStatus(err, http.StatusNotFound))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMergedGlobalConfig handles GET /api/system/config/merged: networkd.conf
+// with all drop-ins applied, and which file set each value.
+func (h *Handler) GetMergedGlobalConfig(w http.ResponseWriter, r *http.Request) {
+	merged, err := h.Service.GetMergedGlobalConfig(getHost(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(merged)
 }
 
 func (h *Handler) ReloadNetworkd(w http.ResponseWriter, r *http.Request) {
@@ -88,11 +175,279 @@ func (h *Handler) GetRoutes(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// logQuery reads journal filters from the query string
+func logQuery(r *http.Request) (service.LogQuery, error) {
+	q := r.URL.Query()
+	lq := service.LogQuery{
+		Since:     q.Get("since"),
+		Until:     q.Get("until"),
+		Priority:  q.Get("priority"),
+		Interface: q.Get("interface"),
+		Cursor:    q.Get("cursor"),
+		After:     q.Get("after"),
+	}
+	if v := q.Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return lq, fmt.Errorf("invalid lines: %s", v)
+		}
+		lq.Lines = n
+	}
+	// EventSource sends the id of the last received event on reconnect
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		lq.After = id
+	}
+	return lq, service.ValidateLogQuery(lq)
+}
+
+// GetLogs returns structured systemd-networkd journal entries, newest first.
+// With ?follow=true new entries are streamed as server-sent events.
 func (h *Handler) GetLogs(w http.ResponseWriter, r *http.Request) {
-	logs, err := h.Service.GetLogs(getHost(r))
+	lq, err := logQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("follow") == "true" {
+		h.followLogs(w, r, lq)
+		return
+	}
+
+	page, err := h.Service.GetLogs(getHost(r), lq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		*service.LogPage
+		Logs string `json:"logs"` // Plain-text rendering for older clients
+	}{page, page.Text()})
+}
+
+func (h *Handler) followLogs(w http.ResponseWriter, r *http.Request, lq service.LogQuery) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	err := h.Service.FollowLogs(r.Context(), getHost(r), lq, func(e service.LogEntry) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: entry\ndata: %s\n\n", e.Cursor, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if err != nil && r.Context().Err() == nil {
+		data, _ := json.Marshal(map[string]string{"message": err.Error()})
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+}
+
+// GetTunnels returns tunnel interfaces with endpoints and WireGuard peer status
+func (h *Handler) GetTunnels(w http.ResponseWriter, r *http.Request) {
+	tunnels, err := h.Service.GetTunnels(getHost(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]string{"logs": logs})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tunnels)
+}
+
+// RunLinkAction handles POST /api/system/links/{name}/{action}. Down and
+// delete refuse loopback and the link serving the management connection
+// unless ?force=true is given.
+func (h *Handler) RunLinkAction(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	action := chi.URLParam(r, "action")
+	if err := service.ValidateInterfaceName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := service.ValidateLinkAction(action); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := service.LinkActionOptions{Force: r.URL.Query().Get("force") == "true"}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		// The address this request arrived on is how clients reach the local host
+		target := getHost(r)
+		if host, _, err := net.SplitHostPort(addr.String()); err == nil && (target == "" || target == "local") {
+			opts.ManagementAddrs = append(opts.ManagementAddrs, host)
+		}
+	}
+
+	result, err := h.Service.RunLinkAction(getHost(r), name, action, opts)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrProtectedLink) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Success {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetResolvedStatus returns per-link DNS configuration from systemd-resolved
+func (h *Handler) GetResolvedStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.Service.GetResolvedStatus(getHost(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// GetUdevInfo returns the udev properties of one interface
+func (h *Handler) GetUdevInfo(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if err := service.ValidateInterfaceName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	info, err := h.Service.GetUdevInfo(getHost(r), name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// GetLLDPNeighbors returns neighbours seen via LLDP on all links of a host
+func (h *Handler) GetLLDPNeighbors(w http.ResponseWriter, r *http.Request) {
+	neighbors, err := h.Service.GetLLDPNeighbors(getHost(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(neighbors)
+}
+
+// GetTopology returns the fleet-wide LLDP graph as JSON or, with
+// ?format=dot, as a Graphviz document
+func (h *Handler) GetTopology(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "dot" {
+		http.Error(w, "format must be json or dot", http.StatusBadRequest)
+		return
+	}
+	topo, err := h.Service.GetTopology()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if format == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.Write([]byte(topo.DOT()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(topo)
+}
+
+// GetLinkDetails returns the full runtime state of one link (networkctl status)
+func (h *Handler) GetLinkDetails(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if err := service.ValidateInterfaceName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	details, err := h.Service.GetLinkDetails(getHost(r), name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(details)
+}
+
+// GetInterfaceStats returns counters and rates per interface over the last
+// ?minutes= (default 5), from the background sampler.
+func (h *Handler) GetInterfaceStats(w http.ResponseWriter, r *http.Request) {
+	minutes := 5
+	if v := r.URL.Query().Get("minutes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "minutes must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		minutes = n
+	}
+
+	stats, err := h.Service.Stats.Rates(getHost(r), time.Duration(minutes)*time.Minute)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetDHCPServerLeases lists leases handed out by [DHCPServer], optionally
+// for a single ?interface=
+func (h *Handler) GetDHCPServerLeases(w http.ResponseWriter, r *http.Request) {
+	iface := r.URL.Query().Get("interface")
+	if iface != "" {
+		if err := service.ValidateInterfaceName(iface); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	leases, err := h.Service.GetDHCPServerLeases(getHost(r), iface)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(leases)
+}
+
+// MakeDHCPServerLeaseStatic turns a dynamic lease into a [DHCPServerStaticLease]
+func (h *Handler) MakeDHCPServerLeaseStatic(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Interface  string `json:"interface"`
+		MACAddress string `json:"mac_address"`
+		Address    string `json:"address"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if err := service.ValidateInterfaceName(req.Interface); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.MACAddress == "" {
+		http.Error(w, "mac_address is required", http.StatusBadRequest)
+		return
+	}
+
+	filename, err := h.Service.MakeDHCPServerLeaseStatic(getHost(r), req.Interface, req.MACAddress, req.Address)
+	if err != nil {
+		http.Error(w, "Failed to add static lease: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Static lease added", "filename": filename})
 }
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"networkd-api/internal/service"
+)
+
+// GenerateWireGuardKeys handles POST /api/wireguard/keys. With a key_file the
+// key pair is generated on the target host and only the public key is
+// returned; without one it is generated by the backend and both are returned.
+func (h *Handler) GenerateWireGuardKeys(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		KeyFile string `json:"key_file"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.KeyFile == "" {
+		privateKey, publicKey, err := service.GenerateWireGuardKeyPair()
+		if err != nil {
+			http.Error(w, "Key generation failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"private_key": privateKey,
+			"public_key":  publicKey,
+		})
+		return
+	}
+
+	keyFile, err := sanitizeFilename(req.KeyFile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	publicKey, keyPath, err := h.Service.GenerateWireGuardKeyOnHost(getHost(r), keyFile)
+	if err != nil {
+		http.Error(w, "Key generation failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"public_key":       publicKey,
+		"private_key_file": keyPath,
+	})
+}
+
+// DeriveWireGuardPublicKey handles POST /api/wireguard/pubkey
+func (h *Handler) DeriveWireGuardPublicKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PrivateKey string `json:"private_key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	publicKey, err := service.WireGuardPublicKey(req.PrivateKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"public_key": publicKey})
+}
+
+// AddWireGuardPeer handles POST /api/netdevs/{filename}/peers
+func (h *Handler) AddWireGuardPeer(w http.ResponseWriter, r *http.Request) {
+	filename, err := configFilename(r, "netdev")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Peer map[string]interface{} `json:"peer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Peer == nil {
+		http.Error(w, "Peer is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.AddWireGuardPeer(getHost(r), filename, req.Peer); err != nil {
+		http.Error(w, "Failed to add peer: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Peer added"})
+}
+
+// RemoveWireGuardPeer handles DELETE /api/netdevs/{filename}/peers?public_key=...
+// The key is passed as a query parameter because base64 may contain '/'.
+func (h *Handler) RemoveWireGuardPeer(w http.ResponseWriter, r *http.Request) {
+	filename, err := configFilename(r, "netdev")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	publicKey := r.URL.Query().Get("public_key")
+	if publicKey == "" {
+		http.Error(w, "public_key is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.RemoveWireGuardPeer(getHost(r), filename, publicKey); err != nil {
+		http.Error(w, "Failed to remove peer: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LinkWireGuardHosts handles POST /api/wireguard/link
+func (h *Handler) LinkWireGuardHosts(w http.ResponseWriter, r *http.Request) {
+	var req service.WireGuardLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Service.LinkWireGuardHosts(req)
+	if err != nil {
+		http.Error(w, "Failed to link hosts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
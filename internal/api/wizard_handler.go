@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"networkd-api/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RunWizard handles POST /api/wizard/{kind} for kind bond, bridge, vlan, vrf,
+// macvlan or vxlan. The body is the kind's spec. ?prefix= sets the filename
+// prefix (default 20) and ?dry_run=true returns the files without writing.
+func (h *Handler) RunWizard(w http.ResponseWriter, r *http.Request) {
+	host := getHost(r)
+	prefix := r.URL.Query().Get("prefix")
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	decode := func(spec interface{}) bool {
+		if err := json.NewDecoder(r.Body).Decode(spec); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return false
+		}
+		return true
+	}
+
+	var files []service.GeneratedFile
+	var err error
+	switch chi.URLParam(r, "kind") {
+	case "bond":
+		var spec service.BondSpec
+		if !decode(&spec) {
+			return
+		}
+		files, err = h.Service.CreateBond(host, prefix, spec, dryRun)
+	case "bridge":
+		var spec service.BridgeSpec
+		if !decode(&spec) {
+			return
+		}
+		files, err = h.Service.CreateBridge(host, prefix, spec, dryRun)
+	case "vlan":
+		var spec service.VLANSpec
+		if !decode(&spec) {
+			return
+		}
+		files, err = h.Service.CreateVLANs(host, prefix, spec, dryRun)
+	case "vrf":
+		var spec service.VRFSpec
+		if !decode(&spec) {
+			return
+		}
+		files, err = h.Service.CreateVRF(host, prefix, spec, dryRun)
+	case "macvlan":
+		var spec service.MACVLANSpec
+		if !decode(&spec) {
+			return
+		}
+		files, err = h.Service.CreateMACVLAN(host, prefix, spec, dryRun)
+	case "vxlan":
+		var spec service.VXLANSpec
+		if !decode(&spec) {
+			return
+		}
+		files, err = h.Service.CreateVXLAN(host, prefix, spec, dryRun)
+	default:
+		http.Error(w, "Unknown wizard kind", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), generateStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !dryRun {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dry_run": dryRun,
+		"files":   files,
+	})
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Batch operation kinds.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+var (
+	// ErrInvalidBatch marks batches rejected before anything was written.
+	ErrInvalidBatch = errors.New("invalid batch")
+	// ErrInvalidConfig marks configs that fail validation or rendering.
+	ErrInvalidConfig = errors.New("invalid config")
+)
+
+// BatchOperation is one file change within a batch.
+type BatchOperation struct {
+	Op       string                 `json:"op"`
+	Filename string                 `json:"filename"`
+	Config   map[string]interface{} `json:"config,omitempty"`
+	// SecretsToFiles moves inline keys to key files, as on single writes
+	SecretsToFiles bool `json:"secrets_to_files,omitempty"`
+}
+
+// BatchRequest is a set of changes applied to one host as a unit.
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+	// Reload runs networkctl reload after a successful write
+	Reload bool `json:"reload,omitempty"`
+	// Reconfigure lists interfaces to reconfigure afterwards; ["*"] means all
+	Reconfigure []string `json:"reconfigure,omitempty"`
+}
+
+type BatchOperationResult struct {
+	Op       string `json:"op"`
+	Filename string `json:"filename"`
+	Type     string `json:"type"`
+}
+
+type BatchResult struct {
+	Applied      []BatchOperationResult `json:"applied"`
+	Allocations  []IPAMAllocation       `json:"allocations,omitempty"`
+	ReloadOutput string                 `json:"reload_output,omitempty"`
+	// PostError reports a failed reload/reconfigure; the files stay written
+	PostError string `json:"post_error,omitempty"`
+}
+
+// fileChange is a fully rendered change: content to write, or a deletion.
+// filename may also be a drop-in path.
+type fileChange struct {
+	filename string
+	content  string
+	delete   bool
+}
+
+// prepareConfig runs the steps every create and update goes through before
+// anything is written: inline secrets are split into key files when
+// secretsToFiles is set, "ipam:" values are allocated, and the config is
+// validated and rendered. The changes list the key files before the config.
+// On error, the allocations made so far are released again.
+func (s *NetworkdService) prepareConfig(host, filename, configType string, cfg map[string]interface{}, secretsToFiles bool) ([]fileChange, []IPAMAllocation, error) {
+	// A placeholder PreserveSecrets could not fill in from the stored file
+	// would otherwise be written as the key itself
+	if containsRedacted(cfg) {
+		return nil, nil, fmt.Errorf("%w: %s contains redacted secrets with no stored value to keep", ErrInvalidConfig, filename)
+	}
+	var keyFiles []KeyFile
+	if secretsToFiles {
+		configDir, err := s.GetConfigDir(host)
+		if err != nil {
+			return nil, nil, err
+		}
+		keyFiles = ExtractSecretsToFiles(cfg, s.Schema, configType, filename, configDir)
+	}
+	allocs, err := s.ResolvePoolAddresses(host, filename, configType, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.Schema.Validate(configType, cfg); err != nil {
+		s.ReleaseAllocations(allocs)
+		return nil, nil, fmt.Errorf("%w: validation failed: %v", ErrInvalidConfig, err)
+	}
+	content, err := MapToINI(cfg, s.Schema, configType)
+	if err != nil {
+		s.ReleaseAllocations(allocs)
+		return nil, nil, fmt.Errorf("%w: conversion failed: %v", ErrInvalidConfig, err)
+	}
+	changes := make([]fileChange, 0, len(keyFiles)+1)
+	for _, kf := range keyFiles {
+		changes = append(changes, fileChange{filename: kf.Filename, content: kf.Content})
+	}
+	changes = append(changes, fileChange{filename: filename, content: content})
+	return changes, allocs, nil
+}
+
+// WriteConfig validates and writes one config file together with the key
+// files split off from it, all-or-nothing. It returns the pool addresses
+// filled in for "ipam:" values.
+func (s *NetworkdService) WriteConfig(host, filename, configType string, cfg map[string]interface{}, secretsToFiles bool) ([]IPAMAllocation, error) {
+	changes, allocs, err := s.prepareConfig(host, filename, configType, cfg, secretsToFiles)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyChanges(host, changes); err != nil {
+		s.ReleaseAllocations(allocs)
+		return nil, err
+	}
+	return allocs, nil
+}
+
+// prepareBatch checks every operation against the host's current files and
+// the schemas, and renders the new contents. Nothing is written, but pool
+// addresses are allocated; the caller releases them if the batch fails.
+func (s *NetworkdService) prepareBatch(host string, ops []BatchOperation) ([]fileChange, []BatchOperationResult, []IPAMAllocation, error) {
+	if len(ops) == 0 {
+		return nil, nil, nil, fmt.Errorf("no operations given")
+	}
+	seen := make(map[string]bool)
+	changes := make([]fileChange, 0, len(ops))
+	results := make([]BatchOperationResult, 0, len(ops))
+	var allocs []IPAMAllocation
+
+	for i, op := range ops {
+		fail := func(format string, args ...interface{}) ([]fileChange, []BatchOperationResult, []IPAMAllocation, error) {
+			s.ReleaseAllocations(allocs)
+			return nil, nil, nil, fmt.Errorf("operation %d (%s %s): %s", i, op.Op, op.Filename, fmt.Sprintf(format, args...))
+		}
+		dropin := strings.Contains(op.Filename, "/")
+		var configType string
+		if dropin {
+			ct, err := validateDropinPath(op.Filename)
+			if err != nil {
+				return fail("%v", err)
+			}
+			configType = ct
+		} else {
+			if err := validateFilename(op.Filename); err != nil {
+				return fail("%v", err)
+			}
+			configType, _ = ConfigTypeForFilename(op.Filename)
+		}
+		if seen[op.Filename] {
+			return fail("file appears more than once in the batch")
+		}
+		seen[op.Filename] = true
+
+		existing, readErr := s.readConfigPath(host, op.Filename)
+		if readErr != nil && !errors.Is(readErr, fs.ErrNotExist) {
+			return fail("cannot read current file: %v", readErr)
+		}
+		exists := readErr == nil
+
+		switch op.Op {
+		case BatchCreate, BatchUpdate:
+			if op.Op == BatchCreate && exists {
+				return fail("file already exists")
+			}
+			if op.Op == BatchUpdate && !exists {
+				return fail("file not found")
+			}
+			if op.Config == nil {
+				return fail("config is required")
+			}
+			if exists {
+				if stored, err := INIToMap(existing, s.Schema, configType); err == nil {
+					PreserveSecrets(op.Config, stored, s.Schema, configType)
+				}
+			}
+			if dropin {
+				// Drop-ins only carry part of a config, so they are
+				// rendered but not validated against the schema
+				if op.SecretsToFiles {
+					return fail("secrets_to_files is not supported for drop-ins")
+				}
+				if containsRedacted(op.Config) {
+					return fail("contains redacted secrets with no stored value to keep")
+				}
+				content, err := MapToINI(op.Config, s.Schema, configType)
+				if err != nil {
+					return fail("conversion failed: %v", err)
+				}
+				changes = append(changes, fileChange{filename: op.Filename, content: content})
+				break
+			}
+			opChanges, opAllocs, err := s.prepareConfig(host, op.Filename, configType, op.Config, op.SecretsToFiles)
+			if err != nil {
+				return fail("%v", err)
+			}
+			allocs = append(allocs, opAllocs...)
+			for _, ch := range opChanges {
+				if ch.filename != op.Filename && seen[ch.filename] {
+					return fail("key file %s is written twice", ch.filename)
+				}
+				seen[ch.filename] = true
+			}
+			changes = append(changes, opChanges...)
+		case BatchDelete:
+			if !exists {
+				return fail("file not found")
+			}
+			changes = append(changes, fileChange{filename: op.Filename, delete: true})
+		default:
+			return fail("unknown op, expected create, update or delete")
+		}
+		results = append(results, BatchOperationResult{Op: op.Op, Filename: op.Filename, Type: configType})
+	}
+	return changes, results, allocs, nil
+}
+
+// applyChanges performs all changes or none: when one fails, the changes done
+// so far are undone by restoring the previous content or removing new files.
+// Errors while undoing are returned along with the one that caused it.
+// Pool addresses the written files no longer use are released.
+func (s *NetworkdService) applyChanges(host string, changes []fileChange) error {
+	if _, err := s.writeChanges(host, changes); err != nil {
+		return err
+	}
+	s.releaseStaleAllocations(host, changes)
+	return nil
+}
+
+// writeChanges is applyChanges for callers with a further step: it returns
+// the snapshots of the applied changes, for rollbackChanges if that fails.
+func (s *NetworkdService) writeChanges(host string, changes []fileChange) ([]configSnapshot, error) {
+	var done []configSnapshot
+	for _, ch := range changes {
+		snap, err := s.snapshotConfigPath(host, ch.filename)
+		if err == nil {
+			if ch.delete {
+				err = s.deleteConfigPath(host, ch.filename)
+			} else {
+				err = s.writeConfigPath(host, ch.filename, ch.content)
+			}
+		}
+		if err != nil {
+			return nil, s.rollbackChanges(host, done, fmt.Errorf("failed to apply %s: %w", ch.filename, err))
+		}
+		done = append(done, snap)
+	}
+	return done, nil
+}
+
+// rollbackChanges restores the snapshots in reverse order and returns cause
+// joined with any errors while doing so.
+func (s *NetworkdService) rollbackChanges(host string, done []configSnapshot, cause error) error {
+	errs := []error{cause}
+	for i := len(done) - 1; i >= 0; i-- {
+		if err := s.restoreConfigPath(host, done[i]); err != nil {
+			errs = append(errs, fmt.Errorf("rollback of %s failed: %w", done[i].path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyBatch validates all operations, then writes them all-or-nothing and
+// optionally reloads/reconfigures networkd.
+func (s *NetworkdService) ApplyBatch(host string, req BatchRequest) (*BatchResult, error) {
+	for _, iface := range req.Reconfigure {
+		if iface == "*" {
+			continue
+		}
+		if err := ValidateInterfaceName(iface); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+		}
+	}
+	changes, results, allocs, err := s.prepareBatch(host, req.Operations)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+	}
+	return s.applyBatch(host, req, changes, results, allocs)
+}
+
+// applyBatch writes a prepared batch and runs the requested reload and
+// reconfigure.
+func (s *NetworkdService) applyBatch(host string, req BatchRequest, changes []fileChange, results []BatchOperationResult, allocs []IPAMAllocation) (*BatchResult, error) {
+	if err := s.applyChanges(host, changes); err != nil {
+		s.ReleaseAllocations(allocs)
+		return nil, err
+	}
+
+	result := &BatchResult{Applied: results, Allocations: allocs}
+	if req.Reload {
+		out, err := s.ReloadNetworkd(host)
+		result.ReloadOutput = out
+		if err != nil {
+			result.PostError = "reload failed: " + err.Error()
+			return result, nil
+		}
+	}
+	if len(req.Reconfigure) > 0 {
+		devices := req.Reconfigure
+		if len(devices) == 1 && devices[0] == "*" {
+			devices = nil
+		}
+		if err := s.Reconfigure(host, devices); err != nil {
+			result.PostError = "reconfigure failed: " + err.Error()
+		}
+	}
+	return result, nil
+}
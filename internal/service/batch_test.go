@@ -0,0 +1,85 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchAbortsOnReadErrors(t *testing.T) {
+	svc, _ := newTestService(t)
+	svc.HostManager.AddHost(HostConfig{Name: "edge", Host: "192.0.2.10", User: "networkd-api", Port: 22})
+	edge, fake := newFakeSSHConnector(t, map[string]string{})
+	fake.fail = map[string]string{"read /etc/systemd/network/10-eth0.network": "Permission denied"}
+	svc.RemoteConnectors["edge"] = edge
+
+	// An unreadable file must not be mistaken for a missing one
+	_, err := svc.ApplyBatch("edge", BatchRequest{Operations: []BatchOperation{
+		{Op: BatchCreate, Filename: "10-eth0.network", Config: map[string]interface{}{"Match": map[string]interface{}{"Name": "eth0"}}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "Permission denied") {
+		t.Fatalf("Expected the read error, got %v", err)
+	}
+	if len(fake.files) != 0 {
+		t.Errorf("Files written despite read error: %v", fake.files)
+	}
+}
+
+func TestBatchReportsRollbackErrors(t *testing.T) {
+	svc, _ := newTestService(t)
+	svc.HostManager.AddHost(HostConfig{Name: "edge", Host: "192.0.2.10", User: "networkd-api", Port: 22})
+	edge, fake := newFakeSSHConnector(t, map[string]string{"/etc/systemd/network/10-old.network": "[Match]\nName=old0\n"})
+	fake.fail = map[string]string{
+		"write /etc/systemd/network/10-old.network": "Read-only file system",
+		"write /etc/systemd/network/20-new.network": "No space left on device",
+	}
+	svc.RemoteConnectors["edge"] = edge
+
+	_, err := svc.ApplyBatch("edge", BatchRequest{Operations: []BatchOperation{
+		{Op: BatchDelete, Filename: "10-old.network"},
+		{Op: BatchCreate, Filename: "20-new.network", Config: map[string]interface{}{"Match": map[string]interface{}{"Name": "new0"}}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "failed to apply 20-new.network") || !strings.Contains(err.Error(), "rollback of 10-old.network failed") {
+		t.Errorf("Expected apply and rollback errors, got %v", err)
+	}
+}
+
+func TestBatchUsesWritePipeline(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	svc.IPAM, _ = NewIPAMManager(tmpDir)
+	// Listing local links may fail in a sandbox
+	svc.IPAMIgnoreScanErrors = true
+	if _, err := svc.IPAM.AddPool(IPAMPool{Name: "mgmt", Prefix: "10.0.10.0/24", Gateway: "10.0.10.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := svc.ApplyBatch("local", BatchRequest{Operations: []BatchOperation{
+		{Op: BatchCreate, Filename: "30-wg0.netdev", SecretsToFiles: true, Config: map[string]interface{}{
+			"NetDev":    map[string]interface{}{"Name": "wg0", "Kind": "wireguard"},
+			"WireGuard": map[string]interface{}{"PrivateKey": "aGVsbG8gd29ybGQgaGVsbG8gd29ybGQgaGVsbG8gd28="},
+		}},
+		{Op: BatchCreate, Filename: "30-wg0.network", Config: map[string]interface{}{
+			"Match":   map[string]interface{}{"Name": "wg0"},
+			"Network": map[string]interface{}{"Address": "ipam:mgmt", "Gateway": "ipam:mgmt"},
+		}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Allocations) != 1 || result.Allocations[0].Address != "10.0.10.2/24" {
+		t.Errorf("Unexpected allocations: %+v", result.Allocations)
+	}
+	network, _ := os.ReadFile(filepath.Join(tmpDir, "30-wg0.network"))
+	if !strings.Contains(string(network), "10.0.10.2/24") || !strings.Contains(string(network), "Gateway = 10.0.10.1") {
+		t.Errorf("Pool address not filled in:\n%s", network)
+	}
+	netdev, _ := os.ReadFile(filepath.Join(tmpDir, "30-wg0.netdev"))
+	if strings.Contains(string(netdev), "aGVsbG8") || !strings.Contains(string(netdev), "PrivateKeyFile") {
+		t.Errorf("Secret not moved to a key file:\n%s", netdev)
+	}
+	keys, _ := filepath.Glob(filepath.Join(tmpDir, "*.key"))
+	if len(keys) != 1 {
+		t.Errorf("Expected one key file, got %v", keys)
+	}
+}
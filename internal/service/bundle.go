@@ -0,0 +1,507 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BundleVersion is the format version written into exported bundles.
+const BundleVersion = 1
+
+// Paths inside a bundle archive.
+const (
+	bundleMetadataPath = "metadata.json"
+	bundleGlobalPath   = "networkd.conf"
+	bundleConfigDir    = "network/"
+)
+
+// BundleMetadata describes where and when a bundle was exported.
+type BundleMetadata struct {
+	Version        int       `json:"version"`
+	Host           string    `json:"host"`
+	Hostname       string    `json:"hostname,omitempty"`
+	SystemdVersion string    `json:"systemd_version,omitempty"`
+	SchemaVersion  string    `json:"schema_version,omitempty"`
+	ExportedAt     time.Time `json:"exported_at"`
+	// SecretsRedacted is set when key material was replaced by RedactedValue
+	// or key files were left out
+	SecretsRedacted bool `json:"secrets_redacted"`
+	// MissingKeyFiles lists key files referenced by the configuration that
+	// are not in the bundle: left out with the secrets, outside the config
+	// directory or unreadable
+	MissingKeyFiles []string `json:"missing_key_files,omitempty"`
+	// Links is the runtime link state at export time, for reference
+	Links []Link `json:"links"`
+}
+
+// BundleFile is a config file, drop-in or key file, with its path relative
+// to the config directory ("10-eth0.network", "10-eth0.network.d/mtu.conf").
+type BundleFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// Bundle is a host's complete networkd configuration.
+type Bundle struct {
+	Metadata     BundleMetadata `json:"metadata"`
+	Files        []BundleFile   `json:"files"`
+	GlobalConfig string         `json:"global_config,omitempty"`
+}
+
+// ExportBundle collects every config file, drop-in and networkd.conf of host.
+// Secrets are redacted unless includeSecrets is set; only then are the key
+// files the configuration references (PrivateKeyFile=, ...) included.
+func (s *NetworkdService) ExportBundle(host string, includeSecrets bool) (*Bundle, error) {
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := s.listConfigPaths(host)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{Files: []BundleFile{}}
+	keyFiles := map[string]bool{}
+	for _, p := range paths {
+		content, err := s.readConfigPath(host, p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		for _, ref := range s.referencedKeyFiles(p, content) {
+			keyFiles[ref] = true
+		}
+		if !includeSecrets {
+			var redacted bool
+			if content, redacted = s.redactContent(p, content); redacted {
+				bundle.Metadata.SecretsRedacted = true
+			}
+		}
+		bundle.Files = append(bundle.Files, BundleFile{Path: p, Content: content})
+	}
+	for _, ref := range sortedKeys(keyFiles) {
+		name := filepath.Base(ref)
+		if !includeSecrets || filepath.Dir(ref) != filepath.Clean(c.GetConfigDir()) || validateKeyFilename(name) != nil {
+			bundle.Metadata.MissingKeyFiles = append(bundle.Metadata.MissingKeyFiles, ref)
+			continue
+		}
+		content, err := s.readConfigPath(host, name)
+		if err != nil {
+			bundle.Metadata.MissingKeyFiles = append(bundle.Metadata.MissingKeyFiles, ref)
+			continue
+		}
+		bundle.Files = append(bundle.Files, BundleFile{Path: name, Content: content})
+	}
+	if !includeSecrets && len(keyFiles) > 0 {
+		bundle.Metadata.SecretsRedacted = true
+	}
+	if global, err := c.GetGlobalConfig(); err == nil {
+		bundle.GlobalConfig = global
+	}
+
+	hostname, _ := c.GetHostname()
+	systemdVersion, _ := s.GetSystemdVersion(host)
+	links, err := s.ListLinks(host)
+	if err != nil {
+		links = []Link{}
+	}
+	bundle.Metadata.Version = BundleVersion
+	bundle.Metadata.Host = normalizeHost(host)
+	bundle.Metadata.Hostname = hostname
+	bundle.Metadata.SystemdVersion = systemdVersion
+	bundle.Metadata.SchemaVersion = s.Schema.LoadedVersion
+	bundle.Metadata.ExportedAt = time.Now().UTC()
+	bundle.Metadata.Links = links
+	return bundle, nil
+}
+
+// redactContent re-renders a file with its secrets redacted. Files without
+// secrets are returned unchanged, keeping their comments and layout.
+func (s *NetworkdService) redactContent(p, content string) (string, bool) {
+	configType, ok := configTypeForPath(p)
+	if !ok {
+		return content, false
+	}
+	cfg, err := INIToMap(content, s.Schema, configType)
+	if err != nil || !HasSecrets(cfg, s.Schema, configType) {
+		return content, false
+	}
+	RedactSecrets(cfg, s.Schema, configType)
+	redacted, err := MapToINI(cfg, s.Schema, configType)
+	if err != nil {
+		return content, false
+	}
+	return redacted, true
+}
+
+// referencedKeyFiles returns the paths in PrivateKeyFile=, PresharedKeyFile=
+// and KeyFile= of a config file or drop-in.
+func (s *NetworkdService) referencedKeyFiles(p, content string) []string {
+	configType, ok := configTypeForPath(p)
+	if !ok {
+		return nil
+	}
+	cfg, err := INIToMap(content, s.Schema, configType)
+	if err != nil {
+		return nil
+	}
+	var refs []string
+	for _, val := range cfg {
+		for _, item := range sectionItems(val) {
+			for _, fileKey := range secretFileKeys {
+				if ref, ok := item[fileKey].(string); ok && ref != "" {
+					refs = append(refs, ref)
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// configTypeForPath returns the config type of a file or drop-in path.
+func configTypeForPath(p string) (string, bool) {
+	if strings.Contains(p, "/") {
+		configType, err := validateDropinPath(p)
+		return configType, err == nil
+	}
+	return ConfigTypeForFilename(p)
+}
+
+// WriteTarGz writes the bundle as a gzipped tar archive: metadata.json,
+// networkd.conf and the config files under network/.
+func (b *Bundle) WriteTarGz(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	modTime := b.Metadata.ExportedAt
+
+	add := func(name string, data []byte) error {
+		mode := int64(0644)
+		if isKeyFilename(name) {
+			mode = 0600
+		}
+		hdr := &tar.Header{Name: name, Mode: mode, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	meta, err := json.MarshalIndent(b.Metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := add(bundleMetadataPath, meta); err != nil {
+		return err
+	}
+	if b.GlobalConfig != "" {
+		if err := add(bundleGlobalPath, []byte(b.GlobalConfig)); err != nil {
+			return err
+		}
+	}
+	for _, f := range b.Files {
+		if err := add(bundleConfigDir+f.Path, []byte(f.Content)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ReadTarGzBundle parses an archive written by WriteTarGz. Entries outside
+// the known layout are rejected.
+func ReadTarGzBundle(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a gzip archive: %v", ErrInvalidImport, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	bundle := &Bundle{Files: []BundleFile{}}
+	haveMetadata := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidImport, hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		switch {
+		case name == bundleMetadataPath:
+			if err := json.Unmarshal(data, &bundle.Metadata); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidImport, name, err)
+			}
+			haveMetadata = true
+		case name == bundleGlobalPath:
+			bundle.GlobalConfig = string(data)
+		case strings.HasPrefix(name, bundleConfigDir):
+			bundle.Files = append(bundle.Files, BundleFile{Path: strings.TrimPrefix(name, bundleConfigDir), Content: string(data)})
+		default:
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidImport, hdr.Name)
+		}
+	}
+	if !haveMetadata {
+		return nil, fmt.Errorf("%w: %s is missing", ErrInvalidImport, bundleMetadataPath)
+	}
+	return bundle, nil
+}
+
+// renameInterfaces applies old -> new interface names to a file path and
+// its content and records the names it replaced in used. In file names the
+// "-"-separated parts before the suffix are compared ("10-eth0.network"); in
+// content only whole whitespace-separated values are replaced, so "eth0"
+// does not touch "eth0.10" or "veth0".
+func renameInterfaces(p, content string, names map[string]string, used map[string]bool) (string, string) {
+	if len(names) == 0 {
+		return p, content
+	}
+
+	file, tail := p, ""
+	if i := strings.Index(p, ".d/"); i >= 0 {
+		file, tail = p[:i], p[i:]
+	}
+	ext := filepath.Ext(file)
+	parts := strings.Split(strings.TrimSuffix(file, ext), "-")
+	// The name after the ordering prefix may itself contain dashes. Each
+	// part is renamed at most once, so swaps (eth0 <-> eth1) do not map a
+	// replacement back.
+	if repl, ok := names[strings.Join(parts[1:], "-")]; ok && len(parts) > 1 {
+		used[strings.Join(parts[1:], "-")] = true
+		parts = []string{parts[0], repl}
+	} else {
+		for i, part := range parts {
+			if repl, ok := names[part]; ok {
+				used[part] = true
+				parts[i] = repl
+			}
+		}
+	}
+	p = strings.Join(parts, "-") + ext + tail
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "[") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		changed := false
+		for j, f := range fields {
+			if repl, ok := names[f]; ok {
+				used[f] = true
+				fields[j] = repl
+				changed = true
+			}
+		}
+		if changed {
+			lines[i] = strings.TrimRight(key, " \t") + " = " + strings.Join(fields, " ")
+		}
+	}
+	return p, strings.Join(lines, "\n")
+}
+
+// BundleImportOptions control how a bundle is applied to a host.
+type BundleImportOptions struct {
+	// Rename maps interface names of the source host to the target's
+	Rename    map[string]string
+	Apply     bool
+	Overwrite bool
+	// SkipGlobal leaves the target's networkd.conf untouched
+	SkipGlobal bool
+}
+
+// ImportBundle validates a bundle against the schemas, applies interface
+// renames and, with Apply, writes it to host all-or-nothing: key files first,
+// then the config files and last networkd.conf, rolling the files back if
+// that fails. Files present only on the target are left alone.
+func (s *NetworkdService) ImportBundle(host string, bundle *Bundle, opts BundleImportOptions) (*ImportResult, error) {
+	if bundle.Metadata.Version > BundleVersion {
+		return nil, fmt.Errorf("%w: bundle version %d is newer than supported (%d)", ErrInvalidImport, bundle.Metadata.Version, BundleVersion)
+	}
+	for old, repl := range opts.Rename {
+		if err := ValidateInterfaceName(old); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		if err := ValidateInterfaceName(repl); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+	}
+
+	res := &ImportResult{Files: []GeneratedFile{}, Warnings: []string{}}
+	var keyChanges, changes []fileChange
+	var conflicts []string
+	seen := map[string]bool{}
+	used := map[string]bool{}
+	for _, f := range bundle.Files {
+		if isKeyFilename(f.Path) {
+			// Key files are referenced by absolute path, so they keep
+			// their names
+			if err := validateKeyFilename(f.Path); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+			}
+			if seen[f.Path] {
+				return nil, fmt.Errorf("%w: %s appears more than once", ErrInvalidImport, f.Path)
+			}
+			seen[f.Path] = true
+			action := "create"
+			if _, err := s.readConfigPath(host, f.Path); err == nil {
+				action = "update"
+				conflicts = append(conflicts, f.Path)
+			}
+			res.Files = append(res.Files, GeneratedFile{Filename: f.Path, Type: "key", Action: action})
+			keyChanges = append(keyChanges, fileChange{filename: f.Path, content: f.Content})
+			continue
+		}
+		p, content := renameInterfaces(f.Path, f.Content, opts.Rename, used)
+		configType, ok := configTypeForPath(p)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not a config file or drop-in", ErrInvalidImport, f.Path)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("%w: %s appears more than once (after renaming)", ErrInvalidImport, p)
+		}
+		seen[p] = true
+
+		cfg, err := INIToMap(content, s.Schema, configType)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidImport, p, err)
+		}
+		action := "create"
+		if existing, err := s.readConfigPath(host, p); err == nil {
+			action = "update"
+			conflicts = append(conflicts, p)
+			if stored, err := INIToMap(existing, s.Schema, configType); err == nil {
+				PreserveSecrets(cfg, stored, s.Schema, configType)
+			}
+		}
+		if strings.Contains(content, RedactedValue) {
+			// Restore secrets kept on the target, or refuse to write placeholders
+			if containsRedacted(cfg) {
+				return nil, fmt.Errorf("%w: %s contains redacted secrets; export with the reveal token", ErrInvalidImport, p)
+			}
+			if content, err = MapToINI(cfg, s.Schema, configType); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidImport, p, err)
+			}
+		}
+		// Drop-ins only carry part of a config, so only whole files are
+		// validated against the schema
+		if !strings.Contains(p, "/") {
+			if err := s.Schema.Validate(configType, cfg); err != nil {
+				return nil, fmt.Errorf("%w: validation of %s failed: %v", ErrInvalidImport, p, err)
+			}
+		}
+		RedactSecrets(cfg, s.Schema, configType)
+		res.Files = append(res.Files, GeneratedFile{Filename: p, Type: configType, Action: action, Config: cfg})
+		changes = append(changes, fileChange{filename: p, content: content})
+	}
+
+	for _, old := range sortedKeys(opts.Rename) {
+		if !used[old] {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("rename %s: interface not referenced in the bundle", old))
+		}
+	}
+	global := bundle.GlobalConfig != "" && !opts.SkipGlobal
+	if global {
+		cfg, err := INIToMap(bundle.GlobalConfig, s.Schema, "networkd-conf")
+		if err == nil {
+			err = s.Schema.Validate("networkd-conf", cfg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: validation of networkd.conf failed: %v", ErrInvalidImport, err)
+		}
+	}
+	if bundle.Metadata.SecretsRedacted {
+		res.Warnings = append(res.Warnings, "bundle was exported with secrets redacted")
+	}
+	for _, ref := range bundle.Metadata.MissingKeyFiles {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("key file %s is not in the bundle", ref))
+	}
+
+	if !opts.Apply {
+		return res, nil
+	}
+	if len(conflicts) > 0 && !opts.Overwrite {
+		return nil, fmt.Errorf("%w: files already exist: %s (set overwrite to replace them)", ErrInvalidImport, strings.Join(conflicts, ", "))
+	}
+
+	done, err := s.writeChanges(host, append(keyChanges, changes...))
+	if err != nil {
+		return nil, err
+	}
+	if global {
+		if err := s.SaveGlobalConfig(host, bundle.GlobalConfig); err != nil {
+			return nil, s.rollbackChanges(host, done, fmt.Errorf("failed to write networkd.conf: %w", err))
+		}
+	}
+	s.releaseStaleAllocations(host, append(keyChanges, changes...))
+	res.Applied = true
+	return res, nil
+}
+
+// containsRedacted reports whether any value in cfg is RedactedValue.
+func containsRedacted(cfg map[string]interface{}) bool {
+	for _, val := range cfg {
+		for _, item := range sectionItems(val) {
+			for _, v := range item {
+				switch v := v.(type) {
+				case string:
+					if v == RedactedValue {
+						return true
+					}
+				case []interface{}:
+					for _, e := range v {
+						if e == RedactedValue {
+							return true
+						}
+					}
+				case []string:
+					for _, e := range v {
+						if e == RedactedValue {
+							return true
+						}
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ReadBundle decodes a bundle in either format, detected from the content.
+func ReadBundle(data []byte) (*Bundle, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return ReadTarGzBundle(bytes.NewReader(data))
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	return &bundle, nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRenameInterfaces(t *testing.T) {
+	names := map[string]string{"eth0": "ens3", "br-lan": "br0"}
+	used := map[string]bool{}
+
+	p, content := renameInterfaces("10-eth0.network", "[Match]\nName=eth0\n\n[Network]\nVLAN=eth0.10\nBridge = br-lan\n# eth0 uplink\n", names, used)
+	if p != "10-ens3.network" {
+		t.Errorf("Unexpected path %q", p)
+	}
+	want := "[Match]\nName = ens3\n\n[Network]\nVLAN=eth0.10\nBridge = br0\n# eth0 uplink\n"
+	if content != want {
+		t.Errorf("Unexpected content:\n%s", content)
+	}
+	if p, _ := renameInterfaces("20-br-lan.netdev.d/mtu.conf", "", names, used); p != "20-br0.netdev.d/mtu.conf" {
+		t.Errorf("Unexpected drop-in path %q", p)
+	}
+	if p, _ := renameInterfaces("10-veth0.network", "", names, used); p != "10-veth0.network" {
+		t.Errorf("Partial names must not be renamed, got %q", p)
+	}
+	if !used["eth0"] || !used["br-lan"] {
+		t.Errorf("Expected both renames to be recorded, got %v", used)
+	}
+
+	// Swapped names are each renamed once
+	swap := map[string]string{"eth0": "eth1", "eth1": "eth0"}
+	for _, tc := range []struct{ path, want string }{
+		{"10-eth0.network", "10-eth1.network"},
+		{"10-eth1.network", "10-eth0.network"},
+		{"20-eth0-eth1.network", "20-eth1-eth0.network"},
+	} {
+		p, content := renameInterfaces(tc.path, "[Match]\nName=eth0 eth1\n", swap, map[string]bool{})
+		if p != tc.want || content != "[Match]\nName = eth1 eth0\n" {
+			t.Errorf("%s: got %q with\n%s", tc.path, p, content)
+		}
+	}
+}
+
+func TestBundleTarGzRoundTrip(t *testing.T) {
+	bundle := &Bundle{
+		Metadata:     BundleMetadata{Version: BundleVersion, Host: "local", ExportedAt: time.Now().UTC()},
+		Files:        []BundleFile{{Path: "10-eth0.network", Content: "[Match]\nName=eth0\n"}, {Path: "10-eth0.network.d/mtu.conf", Content: "[Link]\nMTUBytes=9000\n"}},
+		GlobalConfig: "[Network]\nSpeedMeter=yes\n",
+	}
+	var buf bytes.Buffer
+	if err := bundle.WriteTarGz(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBundle(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Files) != 2 || got.Files[1].Path != "10-eth0.network.d/mtu.conf" || got.GlobalConfig != bundle.GlobalConfig {
+		t.Errorf("Unexpected bundle: %+v", got)
+	}
+	if got.Metadata.Host != "local" {
+		t.Errorf("Metadata not restored: %+v", got.Metadata)
+	}
+}
+
+func TestValidateDropinPath(t *testing.T) {
+	for _, p := range []string{"10-eth0.network.d/mtu.conf", "20-wg0.netdev.d/peers.conf"} {
+		if _, err := validateDropinPath(p); err != nil {
+			t.Errorf("%s: %v", p, err)
+		}
+	}
+	for _, p := range []string{"10-eth0.network/mtu.conf", "10-eth0.network.d/mtu", "10-eth0.network.d/../x.conf", "../etc.network.d/a.conf", "x.d/a.conf"} {
+		if _, err := validateDropinPath(p); err == nil {
+			t.Errorf("%s: expected an error", p)
+		}
+	}
+}
@@ -1,6 +1,45 @@
 package service
 
-import "os"
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// WriteOptions controls the permissions and ownership of a written file.
+// A zero Mode means 0644; empty Owner/Group leave ownership unchanged.
+type WriteOptions struct {
+	Mode  os.FileMode `json:"mode,omitempty"`
+	Owner string      `json:"owner,omitempty"`
+	Group string      `json:"group,omitempty"`
+}
+
+func (o WriteOptions) mode() os.FileMode {
+	if o.Mode == 0 {
+		return 0644
+	}
+	return o.Mode
+}
+
+// chownSpec renders the ownership as "owner:group" for chown(1).
+func (o WriteOptions) chownSpec() string {
+	if o.Group == "" {
+		return o.Owner
+	}
+	return o.Owner + ":" + o.Group
+}
+
+// withOwnerFromEnv overrides the ownership of o with the "owner[:group]" in
+// the environment variable name. A set but empty variable leaves ownership
+// unchanged, for hosts without a systemd-network user.
+func (o WriteOptions) withOwnerFromEnv(name string) WriteOptions {
+	spec, ok := os.LookupEnv(name)
+	if !ok {
+		return o
+	}
+	o.Owner, o.Group, _ = strings.Cut(spec, ":")
+	return o
+}
 
 // FileInfo is shared, defined in networkd.go currently.
 // Link is defined in networkd.go
@@ -12,22 +51,67 @@ type Connector interface {
 	// NetworkdService handles "ConfigDir" logic, but for remote, ConfigDir is remote.
 	// So Connector should know its ConfigDir.
 
+	GetConfigDir() string
 	ListConfigDir(suffix string) ([]os.DirEntry, error)
 	ReadConfigFile(filename string) ([]byte, error)
-	WriteConfigFile(filename string, content []byte) error
+	// WriteConfigFile replaces a file atomically: content is written to a
+	// temporary file in the same directory, synced, given the requested
+	// mode/ownership and then renamed over the target.
+	WriteConfigFile(filename string, content []byte, opts WriteOptions) error
 	DeleteConfigFile(filename string) error
 
-	// System Operations
+	// GenerateWireGuardKey creates a WireGuard private key on the host, stores
+	// it in keyFile (relative to the config directory) and returns the
+	// public key. The private key is never returned.
+	GenerateWireGuardKey(keyFile string, opts WriteOptions) (string, error)
+
 	// System Operations
 	Reconfigure(devices []string) error
+	// LinkAction runs up, down, renew, forcerenew, reconfigure or delete on
+	// one link and returns the method used and its output.
+	LinkAction(name, action string) (method, output string, err error)
 	GetLinks() ([]Link, error)
+	// GetLinkDetails returns the full networkd view of a single link.
+	GetLinkDetails(name string) (*LinkDetails, error)
+	// GetTunnels reports tunnel interfaces with their endpoints and, for
+	// WireGuard, per-peer handshake and transfer counters.
+	GetTunnels() ([]TunnelStatus, error)
+	// GetInterfaceStats returns packet/byte/error/drop counters per interface.
+	GetInterfaceStats() ([]InterfaceStats, error)
 	GetSystemdVersion() string
+	GetHostname() (string, error)
+	// GetSessionAddrs returns the host's own addresses used by the API's
+	// connection to it. Local connectors have none; the request's address
+	// serves that purpose there.
+	GetSessionAddrs() ([]string, error)
+	// GetLLDPNeighbors returns neighbours received via LLDP on all links.
+	GetLLDPNeighbors() ([]LLDPNeighbor, error)
+	GetResolvedStatus() (*ResolvedStatus, error)
+	GetUdevInfo(iface string) (*UdevInfo, error)
+
+	// ReadHostFile reads an absolute path on the host, outside the config
+	// directory (used to import other systems' configuration).
+	ReadHostFile(path string) ([]byte, error)
+	// GlobHostFiles lists the files whose base name matches the glob in
+	// pattern; the directory part is taken literally. A missing directory
+	// yields no matches.
+	GlobHostFiles(pattern string) ([]string, error)
 
-	// Global Config & Status
+	// Global Config & Status. networkd.conf lives at GetGlobalConfigPath()
+	// and its drop-ins are the *.conf files in "<path>.d", named by base name.
+	GetGlobalConfigPath() string
 	GetGlobalConfig() (string, error)
 	SaveGlobalConfig(content string) error
+	ListGlobalDropins() ([]string, error)
+	ReadGlobalDropin(name string) (string, error)
+	WriteGlobalDropin(name, content string) error
+	DeleteGlobalDropin(name string) error
 	ReloadNetworkd() (string, error)
 	GetRoutes() (string, error)
 	GetRules() (string, error)
-	GetLogs() (string, error)
+	// ReadJournal runs journalctl with args and returns its output.
+	ReadJournal(args []string) ([]byte, error)
+	// FollowJournal runs journalctl with args and passes each output line to
+	// onLine until ctx is cancelled or onLine returns an error.
+	FollowJournal(ctx context.Context, args []string, onLine func([]byte) error) error
 }
@@ -10,9 +10,13 @@ import (
 	"gopkg.in/ini.v1"
 )
 
+// iniOptions keep repeated keys (DNS=, Address=) and repeated sections
+// ([Route], [Address]) apart instead of letting the last one win.
+var iniOptions = ini.LoadOptions{AllowShadows: true, AllowNonUniqueSections: true}
+
 // INIToMap converts INI content to a JSON-compatible map based on schema types
 func INIToMap(content string, schemaService *SchemaService, configType string) (map[string]interface{}, error) {
-	cfg, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, []byte(content))
+	cfg, err := ini.LoadSources(iniOptions, []byte(content))
 	if err != nil {
 		return nil, err
 	}
@@ -126,6 +130,9 @@ func INIToMap(content string, schemaService *SchemaService, configType string) (
 			// If it's already an array, append.
 			if list, ok := existing.([]interface{}); ok {
 				result[sectionName] = append(list, sectionMap)
+			} else if m, ok := existing.(map[string]interface{}); ok && !schemaService.IsRepeatableSection(configType, sectionName) {
+				// systemd merges repeated plain sections like [Network]
+				mergeSection(m, sectionMap)
 			} else {
 				// Convert to list
 				result[sectionName] = []interface{}{existing, sectionMap}
@@ -143,6 +150,19 @@ func INIToMap(content string, schemaService *SchemaService, configType string) (
 	return result, nil
 }
 
+// mergeSection adds the keys of src to dst; lists are concatenated, later
+// scalars win.
+func mergeSection(dst, src map[string]interface{}) {
+	for k, v := range src {
+		cur, ok := dst[k].([]string)
+		if add, isList := v.([]string); ok && isList {
+			dst[k] = append(cur, add...)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
 func parseBool(v string) bool {
 	v = strings.ToLower(v)
 	return v == "1" || v == "yes" || v == "true" || v == "on"
@@ -159,7 +179,7 @@ func MapToINI(data map[string]interface{}, schemaService *SchemaService, configT
 	// Using ini.File is safer for escaping but we need control over order/duplicates.
 	// Let's use ini.File.
 
-	f := ini.Empty()
+	f := ini.Empty(iniOptions)
 
 	// Sort sections to be deterministic?
 	var sections []string
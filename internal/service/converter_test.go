@@ -0,0 +1,72 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMapToINIRoundTripsRepeatedKeys(t *testing.T) {
+	schema := &SchemaService{
+		TypeCache: map[string]map[string]map[string]TypeInfo{
+			"network": {"Network": {"VLAN": {IsArray: true}, "Address": {IsArray: true}}},
+		},
+		RepeatableSections: map[string]map[string]bool{"network": {"Route": true}},
+	}
+	cfg := map[string]interface{}{
+		"Match": map[string]interface{}{"Name": "eth0"},
+		"Network": map[string]interface{}{
+			"VLAN":    []interface{}{"eth0.10", "eth0.20"},
+			"Address": []string{"192.0.2.1/24", "2001:db8::1/64"},
+			"DHCP":    "no",
+		},
+		"Route": []interface{}{
+			map[string]interface{}{"Gateway": "192.0.2.254"},
+			map[string]interface{}{"Gateway": "2001:db8::ffff"},
+		},
+	}
+
+	content, err := MapToINI(cfg, schema, "network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every value of a multi-valued key is its own line
+	if strings.Count(content, "VLAN") != 2 || strings.Count(content, "[Route]") != 2 {
+		t.Errorf("Repeated keys or sections lost:\n%s", content)
+	}
+
+	parsed, err := INIToMap(content, schema, "network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	network := parsed["Network"].(map[string]interface{})
+	if !reflect.DeepEqual(network["VLAN"], []string{"eth0.10", "eth0.20"}) {
+		t.Errorf("Unexpected VLAN after round trip: %v", network["VLAN"])
+	}
+	if !reflect.DeepEqual(network["Address"], []string{"192.0.2.1/24", "2001:db8::1/64"}) {
+		t.Errorf("Unexpected Address after round trip: %v", network["Address"])
+	}
+	if routes, _ := parsed["Route"].([]interface{}); len(routes) != 2 {
+		t.Errorf("Unexpected routes after round trip: %v", parsed["Route"])
+	}
+}
+
+func TestINIToMapMergesRepeatedPlainSections(t *testing.T) {
+	schema := &SchemaService{
+		TypeCache: map[string]map[string]map[string]TypeInfo{
+			"network": {"Network": {"DNS": {IsArray: true}}},
+		},
+		RepeatableSections: map[string]map[string]bool{},
+	}
+	parsed, err := INIToMap("[Network]\nDNS=192.0.2.53\nDHCP=no\n\n[Network]\nDNS=2001:db8::53\nDHCP=yes\n", schema, "network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	network, ok := parsed["Network"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected a single [Network] section, got %v", parsed["Network"])
+	}
+	if !reflect.DeepEqual(network["DNS"], []string{"192.0.2.53", "2001:db8::53"}) || network["DHCP"] != "yes" {
+		t.Errorf("Unexpected merged section: %v", network)
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DHCPServerLease is an address handed out by networkd's [DHCPServer].
+type DHCPServerLease struct {
+	Interface  string     `json:"interface"`
+	Address    string     `json:"address"`
+	MACAddress string     `json:"mac_address,omitempty"`
+	ClientID   string     `json:"client_id,omitempty"`
+	Hostname   string     `json:"hostname,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Static     bool       `json:"static"`
+}
+
+// jsonBytes decodes the byte arrays networkd emits as JSON number arrays.
+type jsonBytes []byte
+
+func (b *jsonBytes) UnmarshalJSON(data []byte) error {
+	var nums []int
+	if err := json.Unmarshal(data, &nums); err != nil {
+		return nil // Unknown shape, leave empty
+	}
+	out := make([]byte, len(nums))
+	for i, n := range nums {
+		out[i] = byte(n)
+	}
+	*b = out
+	return nil
+}
+
+type dhcpServerLeaseJSON struct {
+	ClientID               jsonBytes   `json:"ClientId"`
+	Address                jsonAddress `json:"Address"`
+	Hostname               string      `json:"Hostname"`
+	HardwareAddress        jsonBytes   `json:"HardwareAddress"`
+	ExpirationUSec         uint64      `json:"ExpirationUSec"`
+	ExpirationRealtimeUSec uint64      `json:"ExpirationRealtimeUSec"`
+}
+
+// toLease converts a lease from networkd's JSON. ExpirationUSec is on
+// CLOCK_BOOTTIME, so the realtime expiration of newer versions is preferred
+// and the boot-time one is only used together with the host's boot time.
+func (l dhcpServerLeaseJSON) toLease(iface string, static bool, boot time.Time) DHCPServerLease {
+	lease := DHCPServerLease{
+		Interface:  iface,
+		Address:    string(l.Address),
+		ClientID:   formatHex(l.ClientID),
+		Hostname:   l.Hostname,
+		MACAddress: macFromClientID(l.HardwareAddress, l.ClientID),
+		Static:     static,
+	}
+	if !static {
+		lease.ExpiresAt = usecToTime(l.ExpirationRealtimeUSec)
+		if lease.ExpiresAt == nil {
+			lease.ExpiresAt = boottimeToTime(l.ExpirationUSec, boot)
+		}
+	}
+	return lease
+}
+
+func formatHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("%02x", v)
+	}
+	return strings.Join(parts, ":")
+}
+
+// macFromClientID prefers the reported hardware address, otherwise decodes a
+// type-1 (Ethernet) client identifier.
+func macFromClientID(hw, clientID []byte) string {
+	if len(hw) == 6 {
+		return net.HardwareAddr(hw).String()
+	}
+	if len(clientID) == 7 && clientID[0] == 1 {
+		return net.HardwareAddr(clientID[1:]).String()
+	}
+	return ""
+}
+
+// GetDHCPServerLeases collects [DHCPServer] leases from all links of a host,
+// or only from iface when it is set.
+func (s *NetworkdService) GetDHCPServerLeases(host, iface string) ([]DHCPServerLease, error) {
+	names := []string{iface}
+	if iface == "" {
+		links, err := s.ListLinks(host)
+		if err != nil {
+			return nil, err
+		}
+		names = names[:0]
+		for _, l := range links {
+			names = append(names, l.Name)
+		}
+	}
+
+	leases := []DHCPServerLease{}
+	for _, name := range names {
+		details, err := s.GetLinkDetails(host, name)
+		if err != nil {
+			if iface != "" {
+				return nil, err
+			}
+			continue
+		}
+		leases = append(leases, details.DHCPServerLeases...)
+	}
+	return leases, nil
+}
+
+// MakeDHCPServerLeaseStatic pins a dynamic lease by adding a
+// [DHCPServerStaticLease] to the .network file applied to the interface.
+// address defaults to the currently leased address. An existing static lease
+// for the same MAC address is updated instead of duplicated.
+// Returns the name of the modified file.
+func (s *NetworkdService) MakeDHCPServerLeaseStatic(host, iface, mac, address string) (string, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return "", fmt.Errorf("invalid MAC address: %w", err)
+	}
+	mac = hw.String()
+
+	details, err := s.GetLinkDetails(host, iface)
+	if err != nil {
+		return "", err
+	}
+	if address == "" {
+		for _, l := range details.DHCPServerLeases {
+			if strings.EqualFold(l.MACAddress, mac) {
+				address = l.Address
+				break
+			}
+		}
+		if address == "" {
+			return "", fmt.Errorf("no lease for %s on %s", mac, iface)
+		}
+	}
+	if net.ParseIP(address) == nil {
+		return "", fmt.Errorf("invalid address: %s", address)
+	}
+
+	configDir, err := s.GetConfigDir(host)
+	if err != nil {
+		return "", err
+	}
+	if details.NetworkFile == "" {
+		return "", fmt.Errorf("no .network file is applied to %s", iface)
+	}
+	if filepath.Dir(details.NetworkFile) != filepath.Clean(configDir) {
+		return "", fmt.Errorf("%s is not in the managed config directory %s", details.NetworkFile, configDir)
+	}
+	filename := filepath.Base(details.NetworkFile)
+
+	content, err := s.ReadNetworkFile(host, filename)
+	if err != nil {
+		return "", err
+	}
+	cfg, err := INIToMap(content, s.Schema, "network")
+	if err != nil {
+		return "", err
+	}
+
+	leases := []interface{}{}
+	for _, l := range sectionItems(cfg["DHCPServerStaticLease"]) {
+		if existing, ok := l["MACAddress"].(string); ok && strings.EqualFold(existing, mac) {
+			continue
+		}
+		leases = append(leases, l)
+	}
+	cfg["DHCPServerStaticLease"] = append(leases, map[string]interface{}{
+		"MACAddress": mac,
+		"Address":    address,
+	})
+
+	if err := s.Schema.Validate("network", cfg); err != nil {
+		return "", fmt.Errorf("validation failed: %w", err)
+	}
+	out, err := MapToINI(cfg, s.Schema, "network")
+	if err != nil {
+		return "", err
+	}
+	if err := s.WriteNetworkFile(host, filename, out); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ResolvedScope is the systemd-resolved configuration of the global scope or
+// of one link, as shown by `resolvectl status`.
+type ResolvedScope struct {
+	Interface        string   `json:"interface,omitempty"` // Empty for the global scope
+	Index            int      `json:"index,omitempty"`
+	CurrentScopes    []string `json:"current_scopes,omitempty"`
+	Protocols        []string `json:"protocols,omitempty"`
+	DefaultRoute     *bool    `json:"default_route,omitempty"`
+	DNSSEC           string   `json:"dnssec,omitempty"`
+	DNSOverTLS       string   `json:"dns_over_tls,omitempty"`
+	ResolvConfMode   string   `json:"resolv_conf_mode,omitempty"`
+	CurrentDNSServer string   `json:"current_dns_server,omitempty"`
+	DNSServers       []string `json:"dns_servers"`
+	FallbackServers  []string `json:"fallback_dns_servers,omitempty"`
+	Domains          []string `json:"domains"`
+}
+
+type ResolvedStatus struct {
+	Global ResolvedScope   `json:"global"`
+	Links  []ResolvedScope `json:"links"`
+	// JSON is the raw `resolvectl --json=short status` output on systemd
+	// versions that support it
+	JSON json.RawMessage `json:"json,omitempty"`
+}
+
+var (
+	resolvedLinkHeader = regexp.MustCompile(`^Link (\d+) \((.+)\)$`)
+	resolvedKey        = regexp.MustCompile(`^[A-Za-z][A-Za-z. ]*$`)
+)
+
+// parseResolvectlStatus parses the human-readable `resolvectl status` output,
+// which every systemd-resolved version provides.
+func parseResolvectlStatus(out string) *ResolvedStatus {
+	status := &ResolvedStatus{Links: []ResolvedScope{}}
+	status.Global.DNSServers = []string{}
+	status.Global.Domains = []string{}
+	scope := &status.Global
+	var lastKey string
+	valueCol := -1 // Column where values of the last key start
+
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if trimmed == "Global" {
+			scope = &status.Global
+			lastKey, valueCol = "", -1
+			continue
+		}
+		if m := resolvedLinkHeader.FindStringSubmatch(trimmed); m != nil {
+			idx, _ := strconv.Atoi(m[1])
+			status.Links = append(status.Links, ResolvedScope{Interface: m[2], Index: idx, DNSServers: []string{}, Domains: []string{}})
+			scope = &status.Links[len(status.Links)-1]
+			lastKey, valueCol = "", -1
+			continue
+		}
+
+		// Keys are right-aligned on the colon and continuation lines of
+		// multi-valued fields (servers, domains) are indented to the value
+		// column. Values may contain colons themselves (IPv6, "1.1.1.1:853").
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		key, value, ok := strings.Cut(trimmed, ":")
+		if valueCol >= 0 && indent >= valueCol || !ok || !resolvedKey.MatchString(key) {
+			key, value = lastKey, trimmed
+		} else {
+			valueCol = indent + len(key) + 2
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		lastKey = key
+
+		switch key {
+		case "Current Scopes":
+			if value != "none" {
+				scope.CurrentScopes = strings.Fields(value)
+			}
+		case "Protocols":
+			for _, p := range strings.Fields(value) {
+				if k, v, ok := strings.Cut(p, "="); ok {
+					switch k {
+					case "DNSSEC":
+						scope.DNSSEC = v
+					case "DNSOverTLS":
+						scope.DNSOverTLS = v
+					}
+					continue
+				}
+				switch p {
+				case "+DefaultRoute", "-DefaultRoute":
+					b := p[0] == '+'
+					scope.DefaultRoute = &b
+				case "+DNSOverTLS":
+					scope.DNSOverTLS = "yes"
+				case "-DNSOverTLS":
+					scope.DNSOverTLS = "no"
+				}
+				scope.Protocols = append(scope.Protocols, p)
+			}
+		case "DNSSEC setting":
+			scope.DNSSEC = value
+		case "DNSOverTLS setting":
+			scope.DNSOverTLS = value
+		case "resolv.conf mode":
+			scope.ResolvConfMode = value
+		case "Current DNS Server":
+			scope.CurrentDNSServer = value
+		case "DNS Servers":
+			scope.DNSServers = append(scope.DNSServers, strings.Fields(value)...)
+		case "Fallback DNS Servers":
+			scope.FallbackServers = append(scope.FallbackServers, strings.Fields(value)...)
+		case "DNS Domain":
+			scope.Domains = append(scope.Domains, strings.Fields(value)...)
+		}
+	}
+	return status
+}
+
+// newResolvedStatus combines the text output with the optional JSON output,
+// which older resolvectl versions reject.
+func newResolvedStatus(text, jsonOut []byte) *ResolvedStatus {
+	status := parseResolvectlStatus(string(text))
+	if trimmed := bytes.TrimSpace(jsonOut); len(trimmed) > 0 && json.Valid(trimmed) {
+		status.JSON = json.RawMessage(trimmed)
+	}
+	return status
+}
+
+func (s *NetworkdService) GetResolvedStatus(host string) (*ResolvedStatus, error) {
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetResolvedStatus()
+}
+
+// UdevInfo holds the udev properties of a network interface.
+type UdevInfo struct {
+	Interface string `json:"interface"`
+	Driver    string `json:"driver,omitempty"`
+	Path      string `json:"path,omitempty"`      // ID_PATH
+	LinkFile  string `json:"link_file,omitempty"` // .link file applied by net_setup_link
+	// Names are the predictable names per scheme (onboard, slot, path, mac)
+	Names      map[string]string `json:"names"`
+	Properties map[string]string `json:"properties"`
+}
+
+// parseUdevProperties parses `udevadm info --query=property` KEY=VALUE output.
+func parseUdevProperties(iface, out string) *UdevInfo {
+	info := &UdevInfo{Interface: iface, Names: map[string]string{}, Properties: map[string]string{}}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || key == "" {
+			continue
+		}
+		info.Properties[key] = value
+		switch {
+		case key == "ID_NET_DRIVER":
+			info.Driver = value
+		case key == "ID_PATH":
+			info.Path = value
+		case key == "ID_NET_LINK_FILE":
+			info.LinkFile = value
+		case strings.HasPrefix(key, "ID_NET_NAME_"):
+			info.Names[strings.ToLower(strings.TrimPrefix(key, "ID_NET_NAME_"))] = value
+		}
+	}
+	if info.Driver == "" {
+		info.Driver = info.Properties["DRIVER"]
+	}
+	return info
+}
+
+func (s *NetworkdService) GetUdevInfo(host, iface string) (*UdevInfo, error) {
+	if err := ValidateInterfaceName(iface); err != nil {
+		return nil, err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetUdevInfo(iface)
+}
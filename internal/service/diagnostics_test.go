@@ -0,0 +1,88 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseResolvectlStatus(t *testing.T) {
+	out := `Global
+         Protocols: +LLMNR +mDNS -DNSOverTLS DNSSEC=no/unsupported
+  resolv.conf mode: stub
+       DNS Servers: 1.1.1.1
+                    2606:4700:4700::1111
+
+Link 2 (eth0)
+    Current Scopes: DNS
+         Protocols: +DefaultRoute +LLMNR -mDNS -DNSOverTLS DNSSEC=allow-downgrade/supported
+Current DNS Server: 192.168.1.1
+       DNS Servers: 192.168.1.1 fe80::1
+        DNS Domain: lan example.net
+
+Link 3 (wg0)
+    Current Scopes: none
+         Protocols: -DefaultRoute +LLMNR -mDNS -DNSOverTLS DNSSEC=no/unsupported
+`
+	status := parseResolvectlStatus(out)
+	if status.Global.ResolvConfMode != "stub" || len(status.Global.DNSServers) != 2 || status.Global.DNSServers[1] != "2606:4700:4700::1111" {
+		t.Errorf("Unexpected global scope: %+v", status.Global)
+	}
+	if len(status.Links) != 2 {
+		t.Fatalf("Expected 2 links, got %+v", status.Links)
+	}
+	eth := status.Links[0]
+	if eth.Interface != "eth0" || eth.Index != 2 || eth.CurrentDNSServer != "192.168.1.1" || len(eth.DNSServers) != 2 ||
+		len(eth.Domains) != 2 || eth.DNSSEC != "allow-downgrade/supported" || eth.DNSOverTLS != "no" ||
+		eth.DefaultRoute == nil || !*eth.DefaultRoute {
+		t.Errorf("Unexpected eth0 scope: %+v", eth)
+	}
+	if wg := status.Links[1]; wg.CurrentScopes != nil || wg.DefaultRoute == nil || *wg.DefaultRoute {
+		t.Errorf("Unexpected wg0 scope: %+v", wg)
+	}
+}
+
+func TestParseResolvectlStatusServerPorts(t *testing.T) {
+	out := `Global
+           Protocols: +LLMNR +mDNS +DNSOverTLS DNSSEC=no/unsupported
+    resolv.conf mode: stub
+  Current DNS Server: 1.1.1.1:853#cloudflare-dns.com
+         DNS Servers: 1.1.1.1#cloudflare-dns.com
+                      1.1.1.1:853
+                      1.0.0.1:853#cloudflare-dns.com
+                      [2606:4700:4700::1111]:853
+                      2606:4700:4700::1001#cloudflare-dns.com
+Fallback DNS Servers: 9.9.9.9#dns.quad9.net
+                      8.8.8.8:53
+`
+	status := parseResolvectlStatus(out)
+	want := []string{"1.1.1.1#cloudflare-dns.com", "1.1.1.1:853", "1.0.0.1:853#cloudflare-dns.com",
+		"[2606:4700:4700::1111]:853", "2606:4700:4700::1001#cloudflare-dns.com"}
+	if strings.Join(status.Global.DNSServers, " ") != strings.Join(want, " ") {
+		t.Errorf("Unexpected DNS servers: %q", status.Global.DNSServers)
+	}
+	if status.Global.CurrentDNSServer != "1.1.1.1:853#cloudflare-dns.com" || status.Global.ResolvConfMode != "stub" {
+		t.Errorf("Unexpected global scope: %+v", status.Global)
+	}
+	if len(status.Global.FallbackServers) != 2 || status.Global.FallbackServers[1] != "8.8.8.8:53" {
+		t.Errorf("Unexpected fallback servers: %q", status.Global.FallbackServers)
+	}
+}
+
+func TestParseUdevProperties(t *testing.T) {
+	out := `DEVPATH=/devices/pci0000:00/0000:00:1f.6/net/eno1
+INTERFACE=eno1
+ID_NET_NAME_ONBOARD=eno1
+ID_NET_NAME_PATH=enp0s31f6
+ID_NET_NAME_MAC=enx525400123456
+ID_NET_DRIVER=e1000e
+ID_PATH=pci-0000:00:1f.6
+ID_NET_LINK_FILE=/usr/lib/systemd/network/99-default.link
+`
+	info := parseUdevProperties("eno1", out)
+	if info.Driver != "e1000e" || info.Path != "pci-0000:00:1f.6" || info.LinkFile != "/usr/lib/systemd/network/99-default.link" {
+		t.Errorf("Unexpected udev info: %+v", info)
+	}
+	if info.Names["onboard"] != "eno1" || info.Names["path"] != "enp0s31f6" || info.Names["mac"] != "enx525400123456" {
+		t.Errorf("Unexpected names: %v", info.Names)
+	}
+}
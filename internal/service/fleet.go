@@ -0,0 +1,360 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MACUse is a MAC address found on a managed host: a MACAddress= override
+// in a config file or the hardware address of a running link.
+type MACUse struct {
+	MAC       string `json:"mac"`
+	Host      string `json:"host"`
+	Interface string `json:"interface,omitempty"`
+	Filename  string `json:"filename,omitempty"` // Empty for runtime addresses
+	Source    string `json:"source"`             // "config" or "runtime"
+}
+
+// hostInventory reads the addresses and MAC addresses of one host from its
+// config files and running links. What was read before an error is returned
+// with it.
+func (s *NetworkdService) hostInventory(host string) ([]AddressUse, []MACUse, error) {
+	host = normalizeHost(host)
+	var addrs []AddressUse
+	var macs []MACUse
+	files, err := s.listConfigFilenames(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, filename := range files {
+		configType, _ := ConfigTypeForFilename(filename)
+		content, err := s.ReadNetworkFile(host, filename)
+		if err != nil {
+			return addrs, macs, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		cfg, err := INIToMap(content, s.Schema, configType)
+		if err != nil {
+			continue
+		}
+
+		var iface, mac string
+		switch configType {
+		case "network":
+			match, _ := cfg["Match"].(map[string]interface{})
+			iface = strings.Join(configValues(match["Name"]), " ")
+			link, _ := cfg["Link"].(map[string]interface{})
+			mac = configValue(link["MACAddress"])
+
+			network, _ := cfg["Network"].(map[string]interface{})
+			values := configValues(network["Address"])
+			for _, item := range sectionItems(cfg["Address"]) {
+				values = append(values, configValues(item["Address"])...)
+			}
+			for _, a := range values {
+				if _, err := netip.ParsePrefix(a); err != nil {
+					continue // Pool references and unparsable values
+				}
+				addrs = append(addrs, AddressUse{Address: a, Host: host, Interface: iface, Filename: filename, Source: "config"})
+			}
+		case "netdev":
+			netdev, _ := cfg["NetDev"].(map[string]interface{})
+			iface, mac = configValue(netdev["Name"]), configValue(netdev["MACAddress"])
+		case "link":
+			link, _ := cfg["Link"].(map[string]interface{})
+			iface, mac = configValue(link["Name"]), configValue(link["MACAddress"])
+		}
+		if hw, err := net.ParseMAC(mac); err == nil {
+			macs = append(macs, MACUse{MAC: hw.String(), Host: host, Interface: iface, Filename: filename, Source: "config"})
+		}
+	}
+
+	links, err := s.ListLinks(host)
+	if err != nil {
+		return addrs, macs, fmt.Errorf("failed to list links: %w", err)
+	}
+	for _, l := range links {
+		for _, a := range l.Addresses {
+			pfx, err := netip.ParsePrefix(a)
+			if err != nil || pfx.Addr().IsLoopback() || pfx.Addr().IsLinkLocalUnicast() {
+				continue
+			}
+			addrs = append(addrs, AddressUse{Address: a, Host: host, Interface: l.Name, Source: "runtime"})
+		}
+		if hw, err := net.ParseMAC(l.HardwareAddress); err == nil && l.Type != "loopback" {
+			macs = append(macs, MACUse{MAC: hw.String(), Host: host, Interface: l.Name, Source: "runtime"})
+		}
+	}
+	return addrs, macs, nil
+}
+
+// Conflict severities, most severe first.
+const (
+	SeverityCritical = "critical" // Live on the network now
+	SeverityWarning  = "warning"  // Will collide once configs are applied
+	SeverityInfo     = "info"     // Duplicate MACs on different segments
+)
+
+// FleetUse is one side of a conflict.
+type FleetUse struct {
+	Host      string `json:"host"`
+	Interface string `json:"interface,omitempty"`
+	Filename  string `json:"filename,omitempty"`
+	Source    string `json:"source"`
+	Address   string `json:"address,omitempty"`
+}
+
+// FleetConflict is an address or MAC used by more than one interface.
+type FleetConflict struct {
+	Kind  string `json:"kind"` // "address" or "mac"
+	Value string `json:"value"`
+	// Segment is the subnet of an address, or the subnets shared by the
+	// interfaces carrying a MAC; empty when unknown
+	Segment  string     `json:"segment,omitempty"`
+	Severity string     `json:"severity"`
+	Detail   string     `json:"detail"`
+	Uses     []FleetUse `json:"uses"`
+}
+
+// FleetScan is the result of scanning all managed hosts for conflicts.
+type FleetScan struct {
+	Hosts     []string          `json:"hosts"`
+	Conflicts []FleetConflict   `json:"conflicts"`
+	Errors    map[string]string `json:"errors"`
+}
+
+// ScanFleetConflicts collects configured and runtime addresses and MACs
+// from every managed host and reports the ones used more than once.
+// Unreachable hosts are listed in Errors; the rest are still compared.
+func (s *NetworkdService) ScanFleetConflicts() *FleetScan {
+	inv := s.fleetInventory()
+	scan := &FleetScan{Hosts: inv.hosts, Errors: inv.errors}
+	scan.Conflicts = findConflicts(inv.addrs, inv.macs)
+	return scan
+}
+
+// fleetInventory is what hostInventory found on every managed host.
+type fleetInventory struct {
+	hosts  []string
+	addrs  []AddressUse
+	macs   []MACUse
+	errors map[string]string
+}
+
+// fleetInventory runs hostInventory on all managed hosts at once. The uses
+// are in host order; hosts that fail are listed in errors with whatever was
+// collected from them before.
+func (s *NetworkdService) fleetInventory() *fleetInventory {
+	hosts := s.managedHosts()
+	type hostResult struct {
+		addrs []AddressUse
+		macs  []MACUse
+		err   error
+	}
+	results := make([]hostResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := &results[i]
+			r.addrs, r.macs, r.err = s.hostInventory(host)
+		}()
+	}
+	wg.Wait()
+
+	inv := &fleetInventory{hosts: hosts, addrs: []AddressUse{}, errors: map[string]string{}}
+	for i, r := range results {
+		if r.err != nil {
+			inv.errors[hosts[i]] = r.err.Error()
+		}
+		inv.addrs = append(inv.addrs, r.addrs...)
+		inv.macs = append(inv.macs, r.macs...)
+	}
+	return inv
+}
+
+func owner(host, iface string) string {
+	return host + "/" + iface
+}
+
+// matchesName reports whether a config's [Match] Name= patterns select the
+// link name. No patterns match any link.
+func matchesName(patterns, name string) bool {
+	fields := strings.Fields(patterns)
+	if len(fields) == 0 {
+		return true
+	}
+	for _, p := range fields {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// addressOwners groups the uses of one address by interface. A config file
+// names its interface by its [Match] Name= patterns and a running link by its
+// name, so a link on the same host selected by a config counts as that
+// config's owner rather than a second one. live holds the owners seen at
+// runtime.
+func addressOwners(uses []AddressUse) (owners, live map[string]bool) {
+	owners, live = map[string]bool{}, map[string]bool{}
+	var configs []AddressUse
+	for _, u := range uses {
+		if u.Source != "runtime" {
+			owners[owner(u.Host, u.Interface)] = true
+			configs = append(configs, u)
+		}
+	}
+	for _, u := range uses {
+		if u.Source != "runtime" {
+			continue
+		}
+		o := owner(u.Host, u.Interface)
+		for _, c := range configs {
+			if c.Host == u.Host && matchesName(c.Interface, u.Interface) {
+				o = owner(c.Host, c.Interface)
+				break
+			}
+		}
+		owners[o] = true
+		live[o] = true
+	}
+	return owners, live
+}
+
+// ownerList formats the distinct owners of a conflict for its detail.
+func ownerList(owners map[string]bool) string {
+	return strings.Join(sortedKeys(owners), ", ")
+}
+
+func findConflicts(addrs []AddressUse, macs []MACUse) []FleetConflict {
+	conflicts := []FleetConflict{}
+
+	// Subnets per interface locate MACs on their L2 segment
+	subnets := make(map[string]map[string]bool)
+	byAddr := make(map[netip.Addr][]AddressUse)
+	for _, u := range addrs {
+		pfx, err := netip.ParsePrefix(u.Address)
+		if err != nil {
+			continue
+		}
+		o := owner(u.Host, u.Interface)
+		if subnets[o] == nil {
+			subnets[o] = make(map[string]bool)
+		}
+		subnets[o][pfx.Masked().String()] = true
+		byAddr[pfx.Addr()] = append(byAddr[pfx.Addr()], u)
+	}
+
+	for addr, uses := range byAddr {
+		owners, live := addressOwners(uses)
+		segments := map[string]bool{}
+		for _, u := range uses {
+			pfx, _ := netip.ParsePrefix(u.Address)
+			segments[pfx.Masked().String()] = true
+		}
+		if len(owners) < 2 {
+			continue
+		}
+		c := FleetConflict{
+			Kind:     "address",
+			Value:    addr.String(),
+			Segment:  strings.Join(sortedKeys(segments), " "),
+			Severity: SeverityWarning,
+			Detail:   fmt.Sprintf("assigned to %d interfaces: %s", len(owners), ownerList(owners)),
+		}
+		if len(live) > 1 {
+			c.Severity = SeverityCritical
+		}
+		if len(segments) > 1 {
+			c.Detail += "; prefix lengths differ"
+		}
+		for _, u := range uses {
+			c.Uses = append(c.Uses, FleetUse{Host: u.Host, Interface: u.Interface, Filename: u.Filename, Source: u.Source, Address: u.Address})
+		}
+		conflicts = append(conflicts, c)
+	}
+
+	byMAC := make(map[string][]MACUse)
+	for _, u := range macs {
+		if u.MAC == "00:00:00:00:00:00" {
+			continue
+		}
+		byMAC[u.MAC] = append(byMAC[u.MAC], u)
+	}
+	for mac, uses := range byMAC {
+		// Bonds, bridges and VLANs share their parent's MAC, so only
+		// duplicates across hosts count
+		hosts, live := map[string]bool{}, map[string]bool{}
+		owners := map[string]bool{}
+		for _, u := range uses {
+			hosts[u.Host] = true
+			owners[owner(u.Host, u.Interface)] = true
+			if u.Source == "runtime" {
+				live[u.Host] = true
+			}
+		}
+		if len(hosts) < 2 {
+			continue
+		}
+		// Subnets reached by the MAC from more than one host
+		reach := map[string]map[string]bool{}
+		for _, u := range uses {
+			for subnet := range subnets[owner(u.Host, u.Interface)] {
+				if reach[subnet] == nil {
+					reach[subnet] = map[string]bool{}
+				}
+				reach[subnet][u.Host] = true
+			}
+		}
+		var shared []string
+		for _, subnet := range sortedKeys(reach) {
+			if len(reach[subnet]) > 1 {
+				shared = append(shared, subnet)
+			}
+		}
+
+		c := FleetConflict{
+			Kind:    "mac",
+			Value:   mac,
+			Segment: strings.Join(shared, " "),
+			Detail:  fmt.Sprintf("used on %d hosts: %s", len(hosts), ownerList(owners)),
+		}
+		switch {
+		case len(shared) > 0 && len(live) > 1:
+			c.Severity = SeverityCritical
+		case len(shared) > 0 || len(reach) == 0:
+			// Same segment but not live yet, or no addresses to tell
+			c.Severity = SeverityWarning
+		default:
+			c.Severity = SeverityInfo
+			c.Detail += "; on different subnets"
+		}
+		for _, u := range uses {
+			c.Uses = append(c.Uses, FleetUse{Host: u.Host, Interface: u.Interface, Filename: u.Filename, Source: u.Source})
+		}
+		conflicts = append(conflicts, c)
+	}
+
+	rank := map[string]int{SeverityCritical: 0, SeverityWarning: 1, SeverityInfo: 2}
+	sort.Slice(conflicts, func(i, j int) bool {
+		a, b := conflicts[i], conflicts[j]
+		if rank[a.Severity] != rank[b.Severity] {
+			return rank[a.Severity] < rank[b.Severity]
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Segment != b.Segment {
+			return a.Segment < b.Segment
+		}
+		return a.Value < b.Value
+	})
+	return conflicts
+}
@@ -0,0 +1,77 @@
+package service
+
+import "testing"
+
+func TestFindConflicts(t *testing.T) {
+	addrs := []AddressUse{
+		// Live on two hosts
+		{Address: "10.0.0.5/24", Host: "local", Interface: "eth0", Filename: "10-eth0.network", Source: "config"},
+		{Address: "10.0.0.5/24", Host: "local", Interface: "eth0", Source: "runtime"},
+		{Address: "10.0.0.5/24", Host: "web1", Interface: "ens3", Source: "runtime"},
+		// Only configured twice
+		{Address: "10.0.1.7/24", Host: "web1", Interface: "ens4", Filename: "20-ens4.network", Source: "config"},
+		{Address: "10.0.1.7/24", Host: "web2", Interface: "ens4", Filename: "20-ens4.network", Source: "config"},
+		{Address: "10.0.0.6/24", Host: "web2", Interface: "ens3", Source: "runtime"},
+		{Address: "192.168.9.2/24", Host: "db1", Interface: "eth0", Source: "runtime"},
+	}
+	macs := []MACUse{
+		// Cloned VM on the same subnet
+		{MAC: "52:54:00:aa:bb:cc", Host: "web1", Interface: "ens3", Source: "runtime"},
+		{MAC: "52:54:00:aa:bb:cc", Host: "web2", Interface: "ens3", Source: "runtime"},
+		// A VLAN sharing its parent's MAC is fine
+		{MAC: "52:54:00:00:00:01", Host: "local", Interface: "eth0", Source: "runtime"},
+		{MAC: "52:54:00:00:00:01", Host: "local", Interface: "eth0.10", Source: "runtime"},
+		// Same MAC on unrelated subnets
+		{MAC: "52:54:00:00:00:02", Host: "local", Interface: "eth0", Filename: "10-eth0.link", Source: "config"},
+		{MAC: "52:54:00:00:00:02", Host: "db1", Interface: "eth0", Source: "runtime"},
+	}
+
+	conflicts := findConflicts(addrs, macs)
+	want := []struct{ kind, value, severity, segment string }{
+		{"address", "10.0.0.5", SeverityCritical, "10.0.0.0/24"},
+		{"mac", "52:54:00:aa:bb:cc", SeverityCritical, "10.0.0.0/24"},
+		{"address", "10.0.1.7", SeverityWarning, "10.0.1.0/24"},
+		{"mac", "52:54:00:00:00:02", SeverityInfo, ""},
+	}
+	if len(conflicts) != len(want) {
+		t.Fatalf("Expected %d conflicts, got %+v", len(want), conflicts)
+	}
+	for i, w := range want {
+		c := conflicts[i]
+		if c.Kind != w.kind || c.Value != w.value || c.Severity != w.severity || c.Segment != w.segment {
+			t.Errorf("Conflict %d: got %s %s %s %q, want %+v", i, c.Kind, c.Value, c.Severity, c.Segment, w)
+		}
+	}
+	if files := conflicts[2].Uses; len(files) != 2 || files[0].Filename != "20-ens4.network" || files[1].Host != "web2" {
+		t.Errorf("Unexpected uses %+v", files)
+	}
+}
+
+func TestFindConflictsConfigAndLink(t *testing.T) {
+	addrs := []AddressUse{
+		// A config matching several links and the link it configured
+		{Address: "10.0.0.5/24", Host: "local", Interface: "en* eth0", Filename: "10-lan.network", Source: "config"},
+		{Address: "10.0.0.5/24", Host: "local", Interface: "ens3", Source: "runtime"},
+		// A config without Name= applies to any link
+		{Address: "10.0.2.1/24", Host: "web1", Filename: "10-any.network", Source: "config"},
+		{Address: "10.0.2.1/24", Host: "web1", Interface: "eth1", Source: "runtime"},
+		// A link the config does not select is another owner
+		{Address: "10.0.3.1/24", Host: "web2", Interface: "eth0", Filename: "10-eth0.network", Source: "config"},
+		{Address: "10.0.3.1/24", Host: "web2", Interface: "eth1", Source: "runtime"},
+	}
+	if conflicts := findConflicts(addrs, nil); len(conflicts) != 1 || conflicts[0].Value != "10.0.3.1" || conflicts[0].Severity != SeverityWarning {
+		t.Fatalf("Expected only the unselected link to conflict, got %+v", conflicts)
+	}
+
+	// The same address live on another host is still a duplicate
+	addrs = append(addrs, AddressUse{Address: "10.0.0.5/24", Host: "web1", Interface: "ens3", Source: "runtime"})
+	conflicts := findConflicts(addrs, nil)
+	if len(conflicts) != 2 {
+		t.Fatalf("Expected 2 conflicts, got %+v", conflicts)
+	}
+	for _, c := range conflicts {
+		if c.Value == "10.0.0.5" && (c.Severity != SeverityCritical || c.Detail != "assigned to 2 interfaces: local/en* eth0, web1/ens3") {
+			t.Errorf("Unexpected conflict %+v", c)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// ErrInvalidDropinName marks drop-in names that are not plain "<name>.conf".
+var ErrInvalidDropinName = errors.New("invalid drop-in name")
+
+var globalDropinName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]*\.conf$`)
+
+func validateGlobalDropinName(name string) error {
+	if !globalDropinName.MatchString(name) {
+		return fmt.Errorf("%w %q: expected <name>.conf", ErrInvalidDropinName, name)
+	}
+	return nil
+}
+
+// GlobalDropin is a networkd.conf drop-in of a host.
+type GlobalDropin struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// ListGlobalDropins returns the host's networkd.conf drop-ins in the order
+// networkd applies them. Only the drop-in directory next to networkd.conf is
+// read; drop-ins in /run, /usr/local/lib and /usr/lib are not managed here.
+func (s *NetworkdService) ListGlobalDropins(host string) ([]GlobalDropin, error) {
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	names, err := c.ListGlobalDropins()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	dir := c.GetGlobalConfigPath() + ".d"
+	dropins := make([]GlobalDropin, 0, len(names))
+	for _, name := range names {
+		dropins = append(dropins, GlobalDropin{Name: name, Path: filepath.Join(dir, name)})
+	}
+	return dropins, nil
+}
+
+func (s *NetworkdService) ReadGlobalDropin(host, name string) (string, error) {
+	if err := validateGlobalDropinName(name); err != nil {
+		return "", err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return "", err
+	}
+	return c.ReadGlobalDropin(name)
+}
+
+func (s *NetworkdService) WriteGlobalDropin(host, name, content string) error {
+	if err := validateGlobalDropinName(name); err != nil {
+		return err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return err
+	}
+	return c.WriteGlobalDropin(name, content)
+}
+
+func (s *NetworkdService) DeleteGlobalDropin(host, name string) error {
+	if err := validateGlobalDropinName(name); err != nil {
+		return err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return err
+	}
+	return c.DeleteGlobalDropin(name)
+}
+
+// MergedGlobalConfig is the effective networkd.conf of a host: the main
+// file with its drop-ins applied in order.
+type MergedGlobalConfig struct {
+	Config map[string]interface{} `json:"config"`
+	// Sources maps "Section.Key" to the file that set it last
+	Sources map[string]string `json:"sources"`
+	Files   []string          `json:"files"`
+}
+
+// GetMergedGlobalConfig applies the drop-ins to networkd.conf like networkd
+// does: later files override single values and extend lists, and an empty
+// list assignment clears what came before. Drop-ins outside <path>.d (see
+// ListGlobalDropins) are not included.
+func (s *NetworkdService) GetMergedGlobalConfig(host string) (*MergedGlobalConfig, error) {
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	merged := &MergedGlobalConfig{Config: map[string]interface{}{}, Sources: map[string]string{}, Files: []string{}}
+	apply := func(path, content string) error {
+		cfg, err := INIToMap(content, s.Schema, "networkd-conf")
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		merged.Files = append(merged.Files, path)
+		for _, name := range sortedKeys(cfg) {
+			values, ok := cfg[name].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			sec := section(merged.Config, name)
+			for key, v := range values {
+				if list, ok := v.([]string); ok {
+					if len(list) == 1 && list[0] == "" {
+						delete(sec, key)
+						delete(merged.Sources, name+"."+key)
+						continue
+					}
+					if prev, ok := sec[key].([]string); ok {
+						v = append(prev, list...)
+					}
+				}
+				sec[key] = v
+				merged.Sources[name+"."+key] = path
+			}
+		}
+		return nil
+	}
+
+	main, err := c.GetGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(c.GetGlobalConfigPath(), main); err != nil {
+		return nil, err
+	}
+	dropins, err := s.ListGlobalDropins(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dropins {
+		content, err := c.ReadGlobalDropin(d.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", d.Path, err)
+		}
+		if err := apply(d.Path, content); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
@@ -2,30 +2,95 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 )
 
+// Privilege escalation methods for commands run on a remote host.
+const (
+	PrivilegeAuto  = ""       // none for root, sudo otherwise
+	PrivilegeNone  = "none"   // run commands as the SSH user
+	PrivilegeSudo  = "sudo"   // sudo, relying on NOPASSWD
+	PrivilegeSudoN = "sudo-n" // sudo -n, failing with ErrSudoPasswordRequired instead of hanging
+	PrivilegeDoas  = "doas"
+)
+
+const (
+	DefaultConnectTimeout = 5 * time.Second
+	DefaultCommandTimeout = 60 * time.Second
+)
+
+// ErrHostNotFound is returned when updating a host that is not registered.
+var ErrHostNotFound = errors.New("host not found")
+
+// ErrInvalidHost marks host settings that are rejected before saving.
+var ErrInvalidHost = errors.New("invalid host")
+
 type HostConfig struct {
 	Name string `json:"name"`
 	Host string `json:"host"` // IP or Hostname
 	User string `json:"user"`
 	Port int    `json:"port"`
+
+	// Remote paths; empty means the systemd defaults
+	ConfigDir        string `json:"config_dir,omitempty"`
+	GlobalConfigPath string `json:"global_config_path,omitempty"`
+	// Privilege is one of the Privilege* methods
+	Privilege string `json:"privilege,omitempty"`
+	// KeyFile is the private key used to log in. Relative paths are
+	// resolved against the data dir and must lie in HostManager.KeyDir;
+	// empty means the shared id_rsa.
+	KeyFile string `json:"key_file,omitempty"`
+	// Timeouts in seconds; 0 means the default
+	ConnectTimeout int `json:"connect_timeout,omitempty"`
+	CommandTimeout int `json:"command_timeout,omitempty"`
+}
+
+// Validate checks the optional connector settings of a host.
+func (h HostConfig) Validate() error {
+	switch h.Privilege {
+	case PrivilegeAuto, PrivilegeNone, PrivilegeSudo, PrivilegeSudoN, PrivilegeDoas:
+	default:
+		return fmt.Errorf("invalid privilege %q: expected none, sudo, sudo-n or doas", h.Privilege)
+	}
+	for field, p := range map[string]string{"config_dir": h.ConfigDir, "global_config_path": h.GlobalConfigPath} {
+		if p != "" && !filepath.IsAbs(p) {
+			return fmt.Errorf("%s must be an absolute path", field)
+		}
+	}
+	if h.KeyFile != "" && !filepath.IsAbs(h.KeyFile) && strings.HasPrefix(filepath.Clean(h.KeyFile), "..") {
+		return fmt.Errorf("key_file must stay within the data dir or be absolute")
+	}
+	if h.ConnectTimeout < 0 || h.CommandTimeout < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	return nil
 }
 
 type HostManager struct {
 	DataDir string
-	Hosts   map[string]HostConfig
-	mu      sync.RWMutex
+	// KeyDir is the only directory key_file may point into, so a host
+	// cannot make the API read arbitrary files as SSH keys. Defaults to
+	// DataDir; NETWORKD_KEY_DIR overrides it.
+	KeyDir string
+	Hosts  map[string]HostConfig
+	mu     sync.RWMutex
 }
 
 func NewHostManager(dataDir string) (*HostManager, error) {
 	hm := &HostManager{
 		DataDir: dataDir,
+		KeyDir:  dataDir,
 		Hosts:   make(map[string]HostConfig),
 	}
+	if env := os.Getenv("NETWORKD_KEY_DIR"); env != "" {
+		hm.KeyDir = env
+	}
 	if err := hm.Load(); err != nil {
 		return nil, err
 	}
@@ -75,11 +140,64 @@ func (hm *HostManager) Save() error {
 	return os.WriteFile(filepath.Join(hm.DataDir, "hosts.json"), content, 0644)
 }
 
+// CheckKeyFile verifies that the host's login key lies in KeyDir. Relative
+// key files are resolved against DataDir, like NewSSHConnector does.
+func (hm *HostManager) CheckKeyFile(h HostConfig) error {
+	if h.KeyFile == "" {
+		return nil // The shared id_rsa
+	}
+	key := h.KeyFile
+	if !filepath.IsAbs(key) {
+		key = filepath.Join(hm.DataDir, key)
+	}
+	rel, err := filepath.Rel(hm.KeyDir, filepath.Clean(key))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("%w: key_file must be inside %s", ErrInvalidHost, hm.KeyDir)
+	}
+	return nil
+}
+
+// normalize fills in the default user and port and validates the settings.
+func (hm *HostManager) normalize(h *HostConfig) error {
+	if h.Name == "" || h.Host == "" {
+		return fmt.Errorf("%w: name and host are required", ErrInvalidHost)
+	}
+	if h.User == "" {
+		h.User = "networkd-api"
+	}
+	if h.Port == 0 {
+		h.Port = 22
+	}
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHost, err)
+	}
+	return hm.CheckKeyFile(*h)
+}
+
+// AddHost registers a host, filling in the default user and port.
 func (hm *HostManager) AddHost(h HostConfig) error {
-	hm.mu.Lock()
 	if h.Name == "local" {
+		return fmt.Errorf("%w: reserved name 'local'", ErrInvalidHost)
+	}
+	if err := hm.normalize(&h); err != nil {
+		return err
+	}
+	hm.mu.Lock()
+	hm.Hosts[h.Name] = h
+	hm.mu.Unlock()
+	return hm.Save()
+}
+
+// UpdateHost replaces the settings of a registered host, filling in the
+// default user and port like AddHost.
+func (hm *HostManager) UpdateHost(h HostConfig) error {
+	if err := hm.normalize(&h); err != nil {
+		return err
+	}
+	hm.mu.Lock()
+	if _, ok := hm.Hosts[h.Name]; !ok {
 		hm.mu.Unlock()
-		return fmt.Errorf("reserved name 'local'")
+		return fmt.Errorf("%w: %s", ErrHostNotFound, h.Name)
 	}
 	hm.Hosts[h.Name] = h
 	hm.mu.Unlock()
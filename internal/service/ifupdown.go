@@ -0,0 +1,736 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IfupdownPath is the main ifupdown configuration file on Debian hosts.
+const IfupdownPath = "/etc/network/interfaces"
+
+// ifupdownMaxDepth bounds nested source directives, which may loop.
+const ifupdownMaxDepth = 8
+
+type ifupdownOption struct {
+	Key, Value string
+}
+
+// ifupdownStanza is one "iface <name> <family> <method>" block.
+type ifupdownStanza struct {
+	Name, Family, Method string
+	Options              []ifupdownOption
+}
+
+func (st *ifupdownStanza) option(key string) (string, bool) {
+	for _, o := range st.Options {
+		if o.Key == key {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
+type ifupdownParser struct {
+	src      importSource
+	b        *importBuilder
+	stanzas  []*ifupdownStanza
+	optional map[string]bool // allow-hotplug without auto
+	auto     map[string]bool
+}
+
+// sourceDirectoryName is the run-parts naming rule source-directory applies.
+var sourceDirectoryName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ifupdownLines joins backslash continuations and drops comments.
+func ifupdownLines(data string) []string {
+	var lines []string
+	var cur strings.Builder
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.HasSuffix(line, "\\") {
+			cur.WriteString(strings.TrimSuffix(line, "\\") + " ")
+			continue
+		}
+		cur.WriteString(line)
+		full := strings.TrimSpace(cur.String())
+		cur.Reset()
+		if full != "" && !strings.HasPrefix(full, "#") {
+			lines = append(lines, full)
+		}
+	}
+	return lines
+}
+
+func (p *ifupdownParser) parseFile(path string, depth int) error {
+	if depth > ifupdownMaxDepth {
+		return fmt.Errorf("%s: source directives nested too deeply", path)
+	}
+	data, err := p.src.read(path)
+	if err != nil {
+		return err
+	}
+
+	var cur *ifupdownStanza
+	skipping := false // inside a stanza that is not converted (mapping)
+	for _, line := range ifupdownLines(string(data)) {
+		fields := strings.Fields(line)
+		switch keyword := fields[0]; {
+		case keyword == "auto" || keyword == "allow-auto":
+			for _, name := range fields[1:] {
+				p.auto[name] = true
+			}
+			cur, skipping = nil, false
+		case keyword == "allow-hotplug":
+			for _, name := range fields[1:] {
+				p.optional[name] = true
+			}
+			cur, skipping = nil, false
+		case strings.HasPrefix(keyword, "allow-"):
+			p.b.warn("%s: %q is not supported", path, line)
+			cur, skipping = nil, false
+		case keyword == "iface":
+			if len(fields) < 4 {
+				return fmt.Errorf("%s: malformed stanza %q", path, line)
+			}
+			cur = &ifupdownStanza{Name: fields[1], Family: fields[2], Method: fields[3]}
+			p.stanzas = append(p.stanzas, cur)
+			skipping = false
+			if len(fields) > 4 {
+				p.b.warn("%s: iface %s: inheritance (%s) is not supported", path, fields[1], strings.Join(fields[4:], " "))
+			}
+		case keyword == "source" || keyword == "source-directory":
+			if len(fields) < 2 {
+				return fmt.Errorf("%s: malformed %s directive", path, keyword)
+			}
+			pattern := fields[1]
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(path), pattern)
+			}
+			if keyword == "source-directory" {
+				pattern = filepath.Join(pattern, "*")
+			}
+			matches, err := p.src.glob(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: %s %s: %w", path, keyword, fields[1], err)
+			}
+			for _, m := range matches {
+				if keyword == "source-directory" && !sourceDirectoryName.MatchString(filepath.Base(m)) {
+					continue
+				}
+				if err := p.parseFile(m, depth+1); err != nil {
+					return err
+				}
+			}
+			cur, skipping = nil, false
+		case keyword == "mapping":
+			p.b.warn("%s: mapping stanzas are not supported (%s)", path, line)
+			cur, skipping = nil, true
+		case keyword == "rename" || keyword == "no-auto-down" || keyword == "no-scripts":
+			p.b.warn("%s: %q is not supported", path, line)
+		case cur != nil:
+			// Options are spelled with - or _ interchangeably
+			key := strings.ReplaceAll(keyword, "_", "-")
+			cur.Options = append(cur.Options, ifupdownOption{Key: key, Value: strings.TrimSpace(strings.TrimPrefix(line, keyword))})
+		case !skipping:
+			p.b.warn("%s: %q outside of an iface stanza was ignored", path, line)
+		}
+	}
+	return nil
+}
+
+// ifupdownIface collects all stanzas of one interface (inet and inet6).
+type ifupdownIface struct {
+	name         string
+	stanzas      []*ifupdownStanza
+	dhcp4, dhcp6 bool
+}
+
+func (ifc *ifupdownIface) option(key string) (string, bool) {
+	for _, st := range ifc.stanzas {
+		if v, ok := st.option(key); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// ConvertIfupdown converts the ifupdown configuration at path (following
+// source and source-directory) into networkd files.
+func ConvertIfupdown(src importSource, path, prefix string) (*ImportResult, error) {
+	b := newImportBuilder(prefix)
+	p := &ifupdownParser{src: src, b: b, optional: map[string]bool{}, auto: map[string]bool{}}
+	if err := p.parseFile(path, 0); err != nil {
+		return nil, err
+	}
+
+	var order []string
+	ifaces := map[string]*ifupdownIface{}
+	for _, st := range p.stanzas {
+		ifc, ok := ifaces[st.Name]
+		if !ok {
+			ifc = &ifupdownIface{name: st.Name}
+			ifaces[st.Name] = ifc
+			order = append(order, st.Name)
+		}
+		ifc.stanzas = append(ifc.stanzas, st)
+	}
+	for _, name := range order {
+		ifc := ifaces[name]
+		if name == "lo" {
+			continue
+		}
+		b.ifupdownKind(ifc)
+		for _, st := range ifc.stanzas {
+			b.ifupdownStanza(ifc, st)
+		}
+		network := section(b.networkFile(name).Config, "Network")
+		switch {
+		case ifc.dhcp4 && ifc.dhcp6:
+			network["DHCP"] = "yes"
+		case ifc.dhcp4:
+			network["DHCP"] = "ipv4"
+		case ifc.dhcp6:
+			network["DHCP"] = "ipv6"
+		}
+		if len(network) == 0 {
+			delete(b.networkFile(name).Config, "Network")
+		}
+		if p.optional[name] && !p.auto[name] {
+			section(b.networkFile(name).Config, "Link")["RequiredForOnline"] = "no"
+		}
+	}
+
+	for name := range b.network {
+		if err := ValidateInterfaceName(name); err != nil {
+			return nil, fmt.Errorf("interface %q cannot be used as a file name: %w", name, err)
+		}
+	}
+	return b.result(), nil
+}
+
+var ifupdownBondOptions = map[string]string{
+	"bond-mode":             "Mode",
+	"bond-miimon":           "MIIMonitorSec",
+	"bond-lacp-rate":        "LACPTransmitRate",
+	"bond-xmit-hash-policy": "TransmitHashPolicy",
+	"bond-updelay":          "UpDelaySec",
+	"bond-downdelay":        "DownDelaySec",
+	"bond-min-links":        "MinLinks",
+	"bond-ad-select":        "AdSelect",
+	"bond-arp-interval":     "ARPIntervalSec",
+	"bond-arp-ip-target":    "ARPIPTargets",
+	"bond-arp-validate":     "ARPValidate",
+	"bond-fail-over-mac":    "FailOverMACPolicy",
+	"bond-primary-reselect": "PrimaryReselectPolicy",
+	"bond-num-grat-arp":     "GratuitousARP",
+}
+
+// ifupdownMillis are the bonding options given in milliseconds without a unit.
+var ifupdownMillis = map[string]bool{
+	"bond-miimon": true, "bond-updelay": true, "bond-downdelay": true, "bond-arp-interval": true,
+}
+
+var ifupdownBridgeOptions = map[string]string{
+	"bridge-stp":        "STP",
+	"bridge-fd":         "ForwardDelaySec",
+	"bridge-hello":      "HelloTimeSec",
+	"bridge-maxage":     "MaxAgeSec",
+	"bridge-ageing":     "AgeingTimeSec",
+	"bridge-bridgeprio": "Priority",
+	"bridge-vlan-aware": "VLANFiltering",
+}
+
+var vlanDotName = regexp.MustCompile(`^(.+)\.(\d+)$`)
+var trailingDigits = regexp.MustCompile(`(\d+)$`)
+
+// ifupdownKind creates the .netdev for bonds, bridges and VLANs and attaches
+// their members.
+func (b *importBuilder) ifupdownKind(ifc *ifupdownIface) {
+	name := ifc.name
+	if master, ok := ifc.option("bond-master"); ok && master != "none" {
+		section(b.networkFile(name).Config, "Network")["Bond"] = master
+	}
+	// bond-primary names the primary member, on the bond or on its members
+	if primary, ok := ifc.option("bond-primary"); ok {
+		section(b.networkFile(primary).Config, "Network")["PrimarySlave"] = "yes"
+	}
+
+	slaves, isBond := ifc.option("bond-slaves")
+	if _, ok := ifc.option("bond-mode"); ok {
+		isBond = true
+	}
+	if isBond {
+		cfg := b.netdev(name, "bond")
+		bond := map[string]interface{}{}
+		for _, st := range ifc.stanzas {
+			for _, o := range st.Options {
+				key, ok := ifupdownBondOptions[o.Key]
+				if !ok {
+					continue
+				}
+				value := o.Value
+				if ifupdownMillis[o.Key] {
+					value += "ms"
+				}
+				if o.Key == "bond-lacp-rate" && (value == "0" || value == "1") {
+					value = map[string]string{"0": "slow", "1": "fast"}[value]
+				}
+				bond[key] = value
+			}
+		}
+		if len(bond) > 0 {
+			cfg["Bond"] = bond
+		}
+		if slaves != "" && slaves != "none" {
+			for _, m := range strings.Fields(slaves) {
+				section(b.networkFile(m).Config, "Network")["Bond"] = name
+			}
+		}
+	}
+
+	if ports, ok := ifc.option("bridge-ports"); ok {
+		cfg := b.netdev(name, "bridge")
+		bridge := map[string]interface{}{}
+		for _, st := range ifc.stanzas {
+			for _, o := range st.Options {
+				if key, ok := ifupdownBridgeOptions[o.Key]; ok {
+					bridge[key] = o.Value
+				}
+			}
+		}
+		if len(bridge) > 0 {
+			cfg["Bridge"] = bridge
+		}
+		for _, port := range strings.Fields(ports) {
+			switch port {
+			case "none":
+			case "all", "regex", "noregex":
+				b.warn("iface %s: bridge-ports %q is not supported; list the ports explicitly", name, port)
+			default:
+				section(b.networkFile(port).Config, "Network")["Bridge"] = name
+			}
+		}
+	}
+
+	raw, hasRaw := ifc.option("vlan-raw-device")
+	var id string
+	if m := vlanDotName.FindStringSubmatch(name); m != nil {
+		id = m[2]
+		if !hasRaw {
+			raw, hasRaw = m[1], true
+		}
+	} else if m := trailingDigits.FindStringSubmatch(name); m != nil && hasRaw {
+		id = m[1]
+	}
+	if v, ok := ifc.option("vlan-id"); ok {
+		id = v
+	}
+	if hasRaw {
+		if id == "" {
+			b.warn("iface %s: cannot determine the VLAN id from the name", name)
+			return
+		}
+		cfg := b.netdev(name, "vlan")
+		cfg["VLAN"] = map[string]interface{}{"Id": id}
+		addValue(section(b.networkFile(raw).Config, "Network"), "VLAN", name)
+	}
+}
+
+// ifupdownConsumed lists options handled by ifupdownKind.
+func ifupdownConsumed(key string) bool {
+	if _, ok := ifupdownBondOptions[key]; ok {
+		return true
+	}
+	if _, ok := ifupdownBridgeOptions[key]; ok {
+		return true
+	}
+	switch key {
+	case "bond-master", "bond-slaves", "bond-primary", "bridge-ports", "vlan-raw-device", "vlan-id":
+		return true
+	}
+	return false
+}
+
+var ifupdownTunnelModes = map[string]string{
+	"gre": "gre", "ipip": "ipip", "sit": "sit", "ip6gre": "ip6gre", "ipip6": "ip6tnl", "ip6ip6": "ip6tnl", "vti": "vti", "vti6": "vti6",
+}
+
+// ifupdownStanza converts the method and options of one stanza.
+func (b *importBuilder) ifupdownStanza(ifc *ifupdownIface, st *ifupdownStanza) {
+	where := fmt.Sprintf("iface %s %s %s", st.Name, st.Family, st.Method)
+	cfg := b.networkFile(st.Name).Config
+	network := section(cfg, "Network")
+	inet6 := st.Family == "inet6"
+
+	switch st.Method {
+	case "static":
+		address, _ := st.option("address")
+		if address == "" {
+			b.warn("%s: no address given", where)
+			break
+		}
+		if !strings.Contains(address, "/") {
+			if mask, ok := st.option("netmask"); ok {
+				if inet6 {
+					address += "/" + mask
+				} else if ip := net.ParseIP(mask).To4(); ip != nil {
+					ones, _ := net.IPMask(ip).Size()
+					address += "/" + strconv.Itoa(ones)
+				} else {
+					address += "/" + mask
+				}
+			} else {
+				b.warn("%s: address %s has no netmask; networkd will assume a host address", where, address)
+			}
+		}
+		addValue(network, "Address", address)
+		if gw, ok := st.option("gateway"); ok {
+			addValue(network, "Gateway", gw)
+		}
+	case "dhcp":
+		if inet6 {
+			ifc.dhcp6 = true
+		} else {
+			ifc.dhcp4 = true
+		}
+	case "auto":
+		if inet6 {
+			network["IPv6AcceptRA"] = "yes"
+		} else {
+			b.warn("%s: unknown method", where)
+		}
+	case "ipv4ll":
+		network["LinkLocalAddressing"] = "ipv4"
+	case "manual", "loopback":
+	case "tunnel", "v4tunnel":
+		mode, _ := st.option("mode")
+		if st.Method == "v4tunnel" {
+			mode = "sit"
+		}
+		kind, ok := ifupdownTunnelModes[mode]
+		if !ok {
+			b.warn("%s: tunnel mode %q is not supported", where, mode)
+			break
+		}
+		tunnel := map[string]interface{}{}
+		for opt, key := range map[string]string{"local": "Local", "endpoint": "Remote", "ttl": "TTL", "key": "Key"} {
+			if v, ok := st.option(opt); ok {
+				tunnel[key] = v
+			}
+		}
+		b.netdev(st.Name, kind)["Tunnel"] = tunnel
+		if address, ok := st.option("address"); ok {
+			if mask, ok := st.option("netmask"); ok && !strings.Contains(address, "/") {
+				address += "/" + mask
+			}
+			addValue(network, "Address", address)
+		}
+		if gw, ok := st.option("gateway"); ok {
+			addValue(network, "Gateway", gw)
+		}
+		b.warn("%s: attach the tunnel to its underlay with Tunnel=%s in that link's .network", where, st.Name)
+	default:
+		b.warn("%s: method %q is not supported", where, st.Method)
+	}
+
+	wireless := false
+	for _, o := range st.Options {
+		switch {
+		case ifupdownConsumed(o.Key):
+		case o.Key == "address" || o.Key == "netmask" || o.Key == "gateway":
+			if st.Method != "static" && st.Method != "tunnel" && st.Method != "v4tunnel" {
+				b.warn("%s: %s is ignored for method %s", where, o.Key, st.Method)
+			}
+		case (o.Key == "mode" || o.Key == "local" || o.Key == "endpoint" || o.Key == "ttl" || o.Key == "key") &&
+			(st.Method == "tunnel" || st.Method == "v4tunnel"):
+		case o.Key == "mtu":
+			section(cfg, "Link")["MTUBytes"] = o.Value
+		case o.Key == "hwaddress":
+			section(cfg, "Link")["MACAddress"] = strings.TrimSpace(strings.TrimPrefix(o.Value, "ether"))
+		case o.Key == "dns-nameservers":
+			addValue(network, "DNS", strings.Fields(o.Value)...)
+		case o.Key == "dns-search" || o.Key == "dns-domain":
+			if cur, ok := network["Domains"].(string); ok {
+				network["Domains"] = cur + " " + o.Value
+			} else {
+				network["Domains"] = o.Value
+			}
+		case o.Key == "accept-ra":
+			network["IPv6AcceptRA"] = boolString(o.Value != "0")
+		case o.Key == "privext":
+			network["IPv6PrivacyExtensions"] = boolString(o.Value != "0")
+		case strings.HasPrefix(o.Key, "wpa-") || strings.HasPrefix(o.Key, "wireless-"):
+			wireless = true
+		case o.Key == "pre-up" || o.Key == "up" || o.Key == "post-up":
+			if !b.ifupdownCommand(st.Name, o.Value) {
+				b.warn("%s: %s command not converted: %s", where, o.Key, o.Value)
+			}
+		case o.Key == "down" || o.Key == "pre-down" || o.Key == "post-down":
+			// Teardown of routes and rules added on up is implied in networkd
+			if !ifupdownTeardown(o.Value) {
+				b.warn("%s: %s command not converted: %s", where, o.Key, o.Value)
+			}
+		default:
+			b.warn("%s: option %q is not supported", where, o.Key)
+		}
+	}
+	if wireless {
+		b.warn("%s: wireless settings are not managed by networkd; configure wpa_supplicant or iwd separately", where)
+	}
+}
+
+// ifupdownTeardown reports whether cmd only removes routes or rules, or
+// takes the link down, all of which networkd does on its own.
+func ifupdownTeardown(cmd string) bool {
+	fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(cmd), "|| true"))
+	if len(fields) == 0 || filepath.Base(fields[0]) != "ip" {
+		return false
+	}
+	args := fields[1:]
+	for len(args) > 0 && (args[0] == "-4" || args[0] == "-6") {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return false
+	}
+	switch args[0] {
+	case "route", "ro", "r", "rule", "ru":
+		return args[1] == "del" || args[1] == "delete" || args[1] == "flush"
+	case "link", "l":
+		return len(args) == 4 && args[1] == "set" && args[3] == "down"
+	}
+	return false
+}
+
+// ifupdownCommand converts "ip route add", "ip rule add" and "route add"
+// commands into [Route] and [RoutingPolicyRule] sections. It reports false
+// for anything it cannot translate exactly.
+func (b *importBuilder) ifupdownCommand(iface, cmd string) bool {
+	cmd = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(cmd), "|| true"))
+	cmd = strings.NewReplacer("${IFACE}", iface, "$IFACE", iface).Replace(cmd)
+	if strings.ContainsAny(cmd, ";&|`$") {
+		return false
+	}
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return false
+	}
+	switch filepath.Base(fields[0]) {
+	case "ip":
+		return b.ipCommand(iface, fields[1:])
+	case "route":
+		return b.routeCommand(iface, fields[1:])
+	}
+	return false
+}
+
+func (b *importBuilder) ipCommand(iface string, args []string) bool {
+	family := ""
+	for len(args) > 0 && (args[0] == "-4" || args[0] == "-6") {
+		family, args = args[0], args[1:]
+	}
+	if len(args) < 3 || (args[1] != "add" && args[1] != "replace" && args[1] != "append") {
+		return false
+	}
+	switch args[0] {
+	case "route", "ro", "r":
+		return b.ipRoute(iface, family, args[2:])
+	case "rule", "ru":
+		return b.ipRule(iface, args[2:])
+	}
+	return false
+}
+
+func defaultDestination(family, gateway string) string {
+	if family == "-6" {
+		return "::/0"
+	}
+	if addr, err := netip.ParseAddr(gateway); err == nil && addr.Is6() {
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
+
+// ipRouteKeys and ipRuleKeys map ip(8) arguments to networkd keys; dev is
+// handled separately.
+var ipRouteKeys = map[string]string{
+	"via": "Gateway", "metric": "Metric", "table": "Table", "src": "PreferredSource", "proto": "Protocol",
+	"scope": "Scope", "mtu": "MTUBytes", "dev": "", "preference": "IPv6Preference",
+}
+
+var ipRuleKeys = map[string]string{
+	"from": "From", "to": "To", "table": "Table", "lookup": "Table", "priority": "Priority", "pref": "Priority",
+	"prio": "Priority", "fwmark": "FirewallMark", "iif": "IncomingInterface", "oif": "OutgoingInterface",
+	"tos": "TypeOfService",
+}
+
+func (b *importBuilder) ipRoute(iface, family string, args []string) bool {
+	route := map[string]interface{}{}
+	dest := ""
+	target := iface
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "onlink" {
+			route["GatewayOnLink"] = "yes"
+			continue
+		}
+		key, ok := ipRouteKeys[arg]
+		if !ok {
+			if dest != "" {
+				return false
+			}
+			dest = arg
+			continue
+		}
+		if i+1 >= len(args) {
+			return false
+		}
+		i++
+		if arg == "dev" {
+			target = args[i]
+			continue
+		}
+		route[key] = args[i]
+	}
+	if dest == "" {
+		return false
+	}
+	if dest == "default" {
+		gw, _ := route["Gateway"].(string)
+		dest = defaultDestination(family, gw)
+	}
+	route["Destination"] = dest
+	if ValidateInterfaceName(target) != nil {
+		return false
+	}
+	appendSectionItem(b.networkFile(target).Config, "Route", route)
+	return true
+}
+
+func (b *importBuilder) ipRule(iface string, args []string) bool {
+	rule := map[string]interface{}{}
+	for i := 0; i < len(args); i += 2 {
+		key, ok := ipRuleKeys[args[i]]
+		if !ok || i+1 >= len(args) {
+			return false
+		}
+		rule[key] = args[i+1]
+	}
+	if len(rule) == 0 {
+		return false
+	}
+	appendSectionItem(b.networkFile(iface).Config, "RoutingPolicyRule", rule)
+	return true
+}
+
+// routeCommand handles the net-tools form:
+// route add [-net|-host] <dest> [netmask <mask>] [gw <gw>] [metric <n>] [dev <if>]
+func (b *importBuilder) routeCommand(iface string, args []string) bool {
+	family := ""
+	if len(args) > 0 && (args[0] == "-A" || args[0] == "-6") {
+		if args[0] == "-6" || (len(args) > 1 && args[1] == "inet6") {
+			family = "-6"
+		}
+		if args[0] == "-A" {
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	if len(args) < 2 || args[0] != "add" {
+		return false
+	}
+	args = args[1:]
+	route := map[string]interface{}{}
+	dest, mask, host := "", "", false
+	target := iface
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-net":
+		case "-host":
+			host = true
+		case "default":
+			dest = "default"
+		case "netmask", "gw", "metric", "dev":
+			if i+1 >= len(args) {
+				return false
+			}
+			i++
+			switch args[i-1] {
+			case "netmask":
+				mask = args[i]
+			case "gw":
+				route["Gateway"] = args[i]
+			case "metric":
+				route["Metric"] = args[i]
+			case "dev":
+				target = args[i]
+			}
+		default:
+			if dest != "" {
+				if i == len(args)-1 {
+					// Trailing interface name without "dev"
+					target = args[i]
+					continue
+				}
+				return false
+			}
+			dest = args[i]
+		}
+	}
+	switch {
+	case dest == "":
+		return false
+	case dest == "default":
+		gw, _ := route["Gateway"].(string)
+		dest = defaultDestination(family, gw)
+	case strings.Contains(dest, "/"):
+	case mask != "":
+		ip := net.ParseIP(mask).To4()
+		if ip == nil {
+			return false
+		}
+		ones, _ := net.IPMask(ip).Size()
+		dest += "/" + strconv.Itoa(ones)
+	case host && family == "-6":
+		dest += "/128"
+	case host:
+		dest += "/32"
+	default:
+		return false
+	}
+	route["Destination"] = dest
+	if ValidateInterfaceName(target) != nil {
+		return false
+	}
+	appendSectionItem(b.networkFile(target).Config, "Route", route)
+	return true
+}
+
+// ImportIfupdown converts a host's /etc/network/interfaces (read through its
+// connector, or from uploaded files keyed by path), validates the result and,
+// with apply, writes it.
+func (s *NetworkdService) ImportIfupdown(host string, files map[string]string, opts ImportOptions) (*ImportResult, error) {
+	src, err := s.importSourceFor(host, files)
+	if err != nil {
+		return nil, err
+	}
+	path := IfupdownPath
+	if _, ok := files[path]; !ok && len(files) == 1 {
+		for name := range files {
+			path = name
+		}
+	}
+	res, err := ConvertIfupdown(src, path, opts.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if err := s.finishImport(host, res, opts); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertIfupdown(t *testing.T) {
+	files := map[string]string{
+		"/etc/network/interfaces": `# The loopback network interface
+auto lo
+iface lo inet loopback
+
+source interfaces.d/*
+
+auto bond0
+iface bond0 inet static
+    address 192.168.1.10
+    netmask 255.255.255.0
+    gateway 192.168.1.1
+    dns-nameservers 1.1.1.1 9.9.9.9
+    dns-search example.com
+    bond-slaves eno1 eno2
+    bond_mode 802.3ad
+    bond-miimon 100
+    bond-lacp-rate 1
+    post-up ip route add 10.0.0.0/8 via 192.168.1.254 metric 50 || true
+    post-up ip rule add from 192.168.1.0/24 table 10
+    pre-down ip route del 10.0.0.0/8 via 192.168.1.254
+    up /usr/local/bin/firewall.sh
+
+iface bond0 inet6 auto
+`,
+		"/etc/network/interfaces.d/vlans": `auto bond0.20
+iface bond0.20 inet dhcp
+    post-up route add -net 172.16.0.0 netmask 255.255.0.0 gw 10.20.0.1 dev $IFACE
+
+allow-hotplug vlan30
+iface vlan30 inet manual
+    vlan-raw-device bond0
+
+auto br0
+iface br0 inet dhcp
+    bridge_ports vlan30
+    bridge_stp off
+    bridge_fd 0
+`,
+	}
+
+	res, err := ConvertIfupdown(filesImportSource(files), IfupdownPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findFile(res, "10-lo.network") != nil {
+		t.Error("Loopback should not be converted")
+	}
+
+	bond := findFile(res, "10-bond0.netdev")
+	if bond == nil {
+		t.Fatalf("Missing bond netdev in %+v", res.Files)
+	}
+	params := bond["Bond"].(map[string]interface{})
+	if params["Mode"] != "802.3ad" || params["MIIMonitorSec"] != "100ms" || params["LACPTransmitRate"] != "fast" {
+		t.Errorf("Unexpected [Bond]: %+v", params)
+	}
+	for _, member := range []string{"eno1", "eno2"} {
+		if findFile(res, "10-"+member+".network")["Network"].(map[string]interface{})["Bond"] != "bond0" {
+			t.Errorf("%s not enslaved to bond0", member)
+		}
+	}
+
+	net := findFile(res, "10-bond0.network")
+	network := net["Network"].(map[string]interface{})
+	if network["Address"] != "192.168.1.10/24" || network["Gateway"] != "192.168.1.1" || network["IPv6AcceptRA"] != "yes" {
+		t.Errorf("Unexpected [Network]: %+v", network)
+	}
+	if vlans, _ := network["VLAN"].([]interface{}); len(vlans) != 2 {
+		t.Errorf("Expected both VLANs on bond0, got %v", network["VLAN"])
+	}
+	route := net["Route"].([]interface{})[0].(map[string]interface{})
+	if route["Destination"] != "10.0.0.0/8" || route["Gateway"] != "192.168.1.254" || route["Metric"] != "50" {
+		t.Errorf("Unexpected route: %+v", route)
+	}
+	if rule := net["RoutingPolicyRule"].([]interface{})[0].(map[string]interface{}); rule["Table"] != "10" {
+		t.Errorf("Unexpected rule: %+v", rule)
+	}
+
+	if vlan := findFile(res, "10-bond0.20.netdev"); vlan["VLAN"].(map[string]interface{})["Id"] != "20" {
+		t.Errorf("Unexpected dotted VLAN: %+v", vlan)
+	}
+	if vlan := findFile(res, "10-vlan30.netdev"); vlan["VLAN"].(map[string]interface{})["Id"] != "30" {
+		t.Errorf("Unexpected VLAN: %+v", vlan)
+	}
+	v20 := findFile(res, "10-bond0.20.network")
+	if r := v20["Route"].([]interface{})[0].(map[string]interface{}); r["Destination"] != "172.16.0.0/16" {
+		t.Errorf("Unexpected net-tools route: %+v", r)
+	}
+	if findFile(res, "10-vlan30.network")["Link"].(map[string]interface{})["RequiredForOnline"] != "no" {
+		t.Error("allow-hotplug without auto should not be required for online")
+	}
+	if findFile(res, "10-vlan30.network")["Network"].(map[string]interface{})["Bridge"] != "br0" {
+		t.Error("vlan30 not attached to br0")
+	}
+	if br := findFile(res, "10-br0.netdev")["Bridge"].(map[string]interface{}); br["STP"] != "off" {
+		t.Errorf("Unexpected [Bridge]: %+v", br)
+	}
+
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "firewall.sh") {
+		t.Errorf("Expected only the script to be reported, got %v", res.Warnings)
+	}
+}
+
+func TestConvertIfupdownMissingSource(t *testing.T) {
+	files := map[string]string{IfupdownPath: "source /etc/network/other\n"}
+	if _, err := ConvertIfupdown(filesImportSource(files), IfupdownPath, ""); err != nil {
+		t.Errorf("A source glob without matches should be ignored: %v", err)
+	}
+	files = map[string]string{IfupdownPath: "iface eth0 inet\n"}
+	if _, err := ConvertIfupdown(filesImportSource(files), IfupdownPath, ""); err == nil {
+		t.Error("Expected malformed stanza to fail")
+	}
+}
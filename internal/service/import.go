@@ -0,0 +1,266 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidImport marks imports rejected before anything was written.
+var ErrInvalidImport = errors.New("invalid import")
+
+// ImportResult is the set of networkd files converted from another network
+// configuration system, plus everything that could not be converted.
+type ImportResult struct {
+	Files []GeneratedFile `json:"files"`
+	// Warnings lists unsupported or approximated constructs
+	Warnings []string `json:"warnings"`
+	Applied  bool     `json:"applied"`
+}
+
+// ImportOptions control how converted files are written.
+type ImportOptions struct {
+	// Prefix is the filename prefix, 10 by default
+	Prefix string
+	Apply  bool
+	// Overwrite allows replacing existing files
+	Overwrite bool
+	// SecretsToFiles moves inline key material into key files, as for
+	// single writes
+	SecretsToFiles bool
+}
+
+// importSource reads the files to convert, either from a host through its
+// connector or from contents uploaded with the request (keyed by path).
+type importSource struct {
+	read func(path string) ([]byte, error)
+	glob func(pattern string) ([]string, error)
+}
+
+func (s *NetworkdService) hostImportSource(host string) (importSource, error) {
+	conn, err := s.GetConnector(host)
+	if err != nil {
+		return importSource{}, err
+	}
+	return importSource{read: conn.ReadHostFile, glob: conn.GlobHostFiles}, nil
+}
+
+func filesImportSource(files map[string]string) importSource {
+	return importSource{
+		read: func(path string) ([]byte, error) {
+			content, ok := files[path]
+			if !ok {
+				return nil, fmt.Errorf("%s: not included in the uploaded files", path)
+			}
+			return []byte(content), nil
+		},
+		glob: func(pattern string) ([]string, error) {
+			var matches []string
+			for path := range files {
+				if ok, _ := filepath.Match(pattern, path); ok {
+					matches = append(matches, path)
+				}
+			}
+			sort.Strings(matches)
+			return matches, nil
+		},
+	}
+}
+
+// importSourceFor uses the uploaded files when given, the host's otherwise.
+func (s *NetworkdService) importSourceFor(host string, files map[string]string) (importSource, error) {
+	if len(files) > 0 {
+		return filesImportSource(files), nil
+	}
+	return s.hostImportSource(host)
+}
+
+// readAll reads every file matching pattern, keyed by path.
+func (src importSource) readAll(pattern string) (map[string]string, error) {
+	paths, err := src.glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(paths))
+	for _, path := range paths {
+		data, err := src.read(path)
+		if err != nil {
+			return nil, err
+		}
+		files[path] = string(data)
+	}
+	return files, nil
+}
+
+// importBuilder accumulates converted files keyed by interface name, so that
+// settings for the same interface coming from different places (a bond
+// listing its members, a VLAN naming its parent) end up in one file.
+type importBuilder struct {
+	prefix   string
+	files    map[string]*GeneratedFile
+	network  map[string]*GeneratedFile
+	warnings []string
+}
+
+func newImportBuilder(prefix string) *importBuilder {
+	if prefix == "" {
+		prefix = "10"
+	}
+	return &importBuilder{
+		prefix:  prefix,
+		files:   make(map[string]*GeneratedFile),
+		network: make(map[string]*GeneratedFile),
+	}
+}
+
+func (b *importBuilder) warn(format string, args ...interface{}) {
+	b.warnings = append(b.warnings, fmt.Sprintf(format, args...))
+}
+
+func (b *importBuilder) filename(id, configType string) string {
+	suffix, _ := ConfigSuffix(configType)
+	return fmt.Sprintf("%s-%s%s", b.prefix, id, suffix)
+}
+
+// file returns the file of the given type for an interface, creating it.
+func (b *importBuilder) file(id, configType string) *GeneratedFile {
+	filename := b.filename(id, configType)
+	if f, ok := b.files[filename]; ok {
+		return f
+	}
+	f := &GeneratedFile{Filename: filename, Type: configType, Action: "create", Config: map[string]interface{}{}}
+	b.files[filename] = f
+	return f
+}
+
+// networkFile returns the .network for id, matching on Name= unless the
+// caller replaces [Match].
+func (b *importBuilder) networkFile(id string) *GeneratedFile {
+	if f, ok := b.network[id]; ok {
+		return f
+	}
+	f := b.file(id, "network")
+	f.Config["Match"] = map[string]interface{}{"Name": id}
+	b.network[id] = f
+	return f
+}
+
+// netdev returns the .netdev for id with [NetDev] filled in.
+func (b *importBuilder) netdev(id, kind string) map[string]interface{} {
+	cfg := b.file(id, "netdev").Config
+	cfg["NetDev"] = map[string]interface{}{"Name": id, "Kind": kind}
+	return cfg
+}
+
+func section(cfg map[string]interface{}, name string) map[string]interface{} {
+	if sec, ok := cfg[name].(map[string]interface{}); ok {
+		return sec
+	}
+	sec := map[string]interface{}{}
+	cfg[name] = sec
+	return sec
+}
+
+// appendSectionItem adds an instance of a repeatable section such as [Route].
+func appendSectionItem(cfg map[string]interface{}, name string, item map[string]interface{}) {
+	items, _ := cfg[name].([]interface{})
+	cfg[name] = append(items, item)
+}
+
+// addValue appends to a possibly multi-valued key, skipping values that are
+// already present.
+func addValue(sec map[string]interface{}, key string, values ...string) {
+	var list []interface{}
+	switch cur := sec[key].(type) {
+	case string:
+		list = []interface{}{cur}
+	case []interface{}:
+		list = cur
+	case []string:
+		for _, v := range cur {
+			list = append(list, v)
+		}
+	}
+	for _, v := range values {
+		present := false
+		for _, have := range list {
+			present = present || have == v
+		}
+		if !present {
+			list = append(list, v)
+		}
+	}
+	if len(list) == 1 {
+		sec[key] = list[0]
+	} else if len(list) > 1 {
+		sec[key] = list
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (b *importBuilder) result() *ImportResult {
+	res := &ImportResult{Files: []GeneratedFile{}, Warnings: b.warnings}
+	if res.Warnings == nil {
+		res.Warnings = []string{}
+	}
+	for _, f := range b.files {
+		res.Files = append(res.Files, *f)
+	}
+	sort.Slice(res.Files, func(i, j int) bool { return res.Files[i].Filename < res.Files[j].Filename })
+	return res
+}
+
+// finishImport validates converted files against the schemas and the host's
+// config directory and, with apply, writes them all-or-nothing through the
+// same pipeline as single writes. Existing files are only replaced when
+// overwrite is set. Secrets are left in the result; callers redact them.
+func (s *NetworkdService) finishImport(host string, res *ImportResult, opts ImportOptions) error {
+	var conflicts []string
+	for i := range res.Files {
+		f := &res.Files[i]
+		if err := validateFilename(f.Filename); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		if err := s.Schema.Validate(f.Type, f.Config); err != nil {
+			return fmt.Errorf("%w: validation of %s failed: %v", ErrInvalidImport, f.Filename, err)
+		}
+		if _, err := s.ReadNetworkFile(host, f.Filename); err == nil {
+			f.Action = "update"
+			conflicts = append(conflicts, f.Filename)
+		}
+	}
+	if !opts.Apply {
+		return nil
+	}
+	if len(conflicts) > 0 && !opts.Overwrite {
+		return fmt.Errorf("%w: files already exist: %s (set overwrite to replace them)", ErrInvalidImport, strings.Join(conflicts, ", "))
+	}
+	var changes []fileChange
+	var allocs []IPAMAllocation
+	for i := range res.Files {
+		f := &res.Files[i]
+		fileChanges, fileAllocs, err := s.prepareConfig(host, f.Filename, f.Type, f.Config, opts.SecretsToFiles)
+		if err != nil {
+			s.ReleaseAllocations(allocs)
+			return fmt.Errorf("%s: %w", f.Filename, err)
+		}
+		changes = append(changes, fileChanges...)
+		allocs = append(allocs, fileAllocs...)
+	}
+	if err := s.applyChanges(host, changes); err != nil {
+		s.ReleaseAllocations(allocs)
+		return err
+	}
+	res.Applied = true
+	return nil
+}
@@ -0,0 +1,552 @@
+package service
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Intent describes a host's network in operator terms: which interfaces
+// exist, how they are addressed and where traffic goes. CompileIntent turns
+// it into networkd files, SummarizeIntent reads existing files back into it.
+type Intent struct {
+	Interfaces []InterfaceIntent `json:"interfaces" yaml:"interfaces"`
+	Routes     []RouteIntent     `json:"routes,omitempty" yaml:"routes,omitempty"`
+}
+
+// InterfaceIntent is one interface and its addressing. Bond members and VLAN
+// parents need not be listed unless they carry settings of their own.
+type InterfaceIntent struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"` // Default <parent>.<vlan_id> for VLANs
+	// Role is an optional label such as "uplink" that routes can refer to
+	Role     string   `json:"role,omitempty" yaml:"role,omitempty"`
+	Type     string   `json:"type,omitempty" yaml:"type,omitempty"`           // ethernet (default), bond, bridge, vlan
+	Members  []string `json:"members,omitempty" yaml:"members,omitempty"`     // Bond members or bridge ports
+	BondMode string   `json:"bond_mode,omitempty" yaml:"bond_mode,omitempty"` // Default 802.3ad
+	Parent   string   `json:"parent,omitempty" yaml:"parent,omitempty"`       // VLAN parent
+	VLANID   int      `json:"vlan_id,omitempty" yaml:"vlan_id,omitempty"`
+
+	DHCP      string   `json:"dhcp,omitempty" yaml:"dhcp,omitempty"` // yes, no, ipv4, ipv6
+	Addresses []string `json:"addresses,omitempty" yaml:"addresses,omitempty"`
+	Gateway   string   `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	DNS       []string `json:"dns,omitempty" yaml:"dns,omitempty"`
+	MTU       int      `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+}
+
+// RouteIntent is a route out of an interface, given by name or role. A
+// default route without a gateway on a DHCP interface sets the metric and
+// table of the routes learned through DHCP and router advertisements.
+type RouteIntent struct {
+	To      string `json:"to,omitempty" yaml:"to,omitempty"` // Prefix, or "default" (the default)
+	Via     string `json:"via" yaml:"via"`
+	Gateway string `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	Metric  int    `json:"metric,omitempty" yaml:"metric,omitempty"`
+	Table   int    `json:"table,omitempty" yaml:"table,omitempty"`
+}
+
+func (ifc *InterfaceIntent) addressed() bool {
+	return (ifc.DHCP != "" && ifc.DHCP != "no") || len(ifc.Addresses) > 0 || ifc.Gateway != ""
+}
+
+// ParseIntent decodes an intent in YAML or JSON.
+func ParseIntent(data []byte) (*Intent, error) {
+	var intent Intent
+	if err := decodeDocument(data, &intent); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	return &intent, nil
+}
+
+// intentCompiler resolves names and roles while the files are generated.
+type intentCompiler struct {
+	b        *importBuilder
+	byName   map[string]*InterfaceIntent
+	byRole   map[string]*InterfaceIntent
+	memberOf map[string]string
+}
+
+// CompileIntent generates the networkd files for intent and validates them
+// like an import: without apply they are only returned, with it they are
+// written all-or-nothing, replacing existing files only with overwrite.
+func (s *NetworkdService) CompileIntent(host string, intent *Intent, opts ImportOptions) (*ImportResult, error) {
+	res, err := compileIntent(intent, opts.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if err := s.finishImport(host, res, opts); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func compileIntent(intent *Intent, prefix string) (*ImportResult, error) {
+	c := &intentCompiler{
+		b:        newImportBuilder(prefix),
+		byName:   make(map[string]*InterfaceIntent),
+		byRole:   make(map[string]*InterfaceIntent),
+		memberOf: make(map[string]string),
+	}
+	if len(intent.Interfaces) == 0 {
+		return nil, fmt.Errorf("the intent has no interfaces")
+	}
+	for i := range intent.Interfaces {
+		ifc := &intent.Interfaces[i]
+		if ifc.Type == "" {
+			ifc.Type = "ethernet"
+		}
+		if ifc.Name == "" && ifc.Type == "vlan" && ifc.Parent != "" {
+			ifc.Name = fmt.Sprintf("%s.%d", ifc.Parent, ifc.VLANID)
+		}
+		if err := ValidateInterfaceName(ifc.Name); err != nil {
+			return nil, err
+		}
+		if _, ok := c.byName[ifc.Name]; ok {
+			return nil, fmt.Errorf("interface %s is listed twice", ifc.Name)
+		}
+		c.byName[ifc.Name] = ifc
+		if ifc.Role != "" {
+			if _, ok := c.byRole[ifc.Role]; ok {
+				return nil, fmt.Errorf("role %q is used twice", ifc.Role)
+			}
+			c.byRole[ifc.Role] = ifc
+		}
+	}
+	for i := range intent.Interfaces {
+		if err := c.iface(&intent.Interfaces[i]); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range intent.Routes {
+		if err := c.route(r); err != nil {
+			return nil, err
+		}
+	}
+	return c.b.result(), nil
+}
+
+func (c *intentCompiler) iface(ifc *InterfaceIntent) error {
+	switch ifc.Type {
+	case "ethernet":
+		if len(ifc.Members) > 0 || ifc.Parent != "" || ifc.VLANID != 0 {
+			return fmt.Errorf("%s: members, parent and vlan_id need type bond, bridge or vlan", ifc.Name)
+		}
+	case "bond":
+		if len(ifc.Members) == 0 {
+			return fmt.Errorf("%s: a bond needs at least one member", ifc.Name)
+		}
+		mode := ifc.BondMode
+		if mode == "" {
+			mode = "802.3ad"
+		}
+		cfg := c.b.netdev(ifc.Name, "bond")
+		cfg["Bond"] = map[string]interface{}{"Mode": mode, "MIIMonitorSec": "100ms"}
+	case "bridge":
+		c.b.netdev(ifc.Name, "bridge")
+	case "vlan":
+		if ifc.Parent == "" {
+			return fmt.Errorf("%s: a VLAN needs a parent", ifc.Name)
+		}
+		if ifc.VLANID < 1 || ifc.VLANID > 4094 {
+			return fmt.Errorf("%s: invalid VLAN id %d", ifc.Name, ifc.VLANID)
+		}
+		cfg := c.b.netdev(ifc.Name, "vlan")
+		cfg["VLAN"] = map[string]interface{}{"Id": ifc.VLANID}
+		if err := c.attach(ifc.Parent, "VLAN", ifc.Name); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s: unknown type %q (expected ethernet, bond, bridge or vlan)", ifc.Name, ifc.Type)
+	}
+	if ifc.Type != "bond" && ifc.BondMode != "" {
+		return fmt.Errorf("%s: bond_mode needs type bond", ifc.Name)
+	}
+
+	key := map[string]string{"bond": "Bond", "bridge": "Bridge"}[ifc.Type]
+	for _, m := range ifc.Members {
+		if prev, ok := c.memberOf[m]; ok {
+			return fmt.Errorf("%s is a member of both %s and %s", m, prev, ifc.Name)
+		}
+		c.memberOf[m] = ifc.Name
+		if member, ok := c.byName[m]; ok && member.addressed() {
+			return fmt.Errorf("%s is a member of %s and cannot have its own addressing", m, ifc.Name)
+		}
+		if err := c.attach(m, key, ifc.Name); err != nil {
+			return err
+		}
+	}
+
+	cfg := c.b.networkFile(ifc.Name).Config
+	network := section(cfg, "Network")
+	switch ifc.DHCP {
+	case "":
+	case "yes", "no", "ipv4", "ipv6":
+		network["DHCP"] = ifc.DHCP
+	default:
+		return fmt.Errorf("%s: invalid dhcp %q (expected yes, no, ipv4 or ipv6)", ifc.Name, ifc.DHCP)
+	}
+	for _, a := range ifc.Addresses {
+		if _, err := netip.ParsePrefix(a); err != nil {
+			return fmt.Errorf("%s: invalid address %q, expected address/prefix", ifc.Name, a)
+		}
+		addValue(network, "Address", a)
+	}
+	if ifc.Gateway != "" {
+		if _, err := netip.ParseAddr(ifc.Gateway); err != nil {
+			return fmt.Errorf("%s: invalid gateway %q", ifc.Name, ifc.Gateway)
+		}
+		network["Gateway"] = ifc.Gateway
+	}
+	for _, d := range ifc.DNS {
+		if _, err := netip.ParseAddr(d); err != nil {
+			return fmt.Errorf("%s: invalid DNS server %q", ifc.Name, d)
+		}
+		addValue(network, "DNS", d)
+	}
+	if ifc.MTU < 0 {
+		return fmt.Errorf("%s: invalid mtu %d", ifc.Name, ifc.MTU)
+	} else if ifc.MTU > 0 {
+		section(cfg, "Link")["MTUBytes"] = ifc.MTU
+	}
+	return nil
+}
+
+// attach adds key=value (Bond=, Bridge=, VLAN=) to iface's .network.
+func (c *intentCompiler) attach(iface, key, value string) error {
+	if err := ValidateInterfaceName(iface); err != nil {
+		return err
+	}
+	addValue(section(c.b.networkFile(iface).Config, "Network"), key, value)
+	return nil
+}
+
+func (c *intentCompiler) route(r RouteIntent) error {
+	ifc, ok := c.byName[r.Via]
+	if !ok {
+		if ifc, ok = c.byRole[r.Via]; !ok {
+			return fmt.Errorf("route to %s: via %q is neither an interface nor a role", r.To, r.Via)
+		}
+	}
+	isDefault := r.To == "" || r.To == "default"
+	if !isDefault {
+		if _, err := netip.ParsePrefix(r.To); err != nil {
+			return fmt.Errorf("route via %s: invalid destination %q", r.Via, r.To)
+		}
+	}
+	if r.Metric < 0 || r.Table < 0 {
+		return fmt.Errorf("route via %s: metric and table must not be negative", r.Via)
+	}
+	cfg := c.b.networkFile(ifc.Name).Config
+
+	if isDefault && r.Gateway == "" {
+		if ifc.DHCP == "" || ifc.DHCP == "no" {
+			return fmt.Errorf("default route via %s needs a gateway or DHCP", r.Via)
+		}
+		// The default route comes from DHCP or router advertisements
+		var sections []string
+		if ifc.DHCP != "ipv6" {
+			sections = append(sections, "DHCPv4")
+		}
+		if ifc.DHCP != "ipv4" {
+			sections = append(sections, "IPv6AcceptRA")
+		}
+		for _, name := range sections {
+			if r.Metric > 0 {
+				section(cfg, name)["RouteMetric"] = r.Metric
+			}
+			if r.Table > 0 {
+				section(cfg, name)["RouteTable"] = r.Table
+			}
+		}
+		return nil
+	}
+
+	item := map[string]interface{}{}
+	if !isDefault {
+		item["Destination"] = r.To
+	}
+	if r.Gateway != "" {
+		if _, err := netip.ParseAddr(r.Gateway); err != nil {
+			return fmt.Errorf("route via %s: invalid gateway %q", r.Via, r.Gateway)
+		}
+		item["Gateway"] = r.Gateway
+	}
+	if r.Metric > 0 {
+		item["Metric"] = r.Metric
+	}
+	if r.Table > 0 {
+		item["Table"] = r.Table
+	}
+	appendSectionItem(cfg, "Route", item)
+	return nil
+}
+
+// IntentSummary is a host's configuration expressed as an intent, with a
+// warning for everything the intent model cannot represent.
+type IntentSummary struct {
+	Intent   Intent   `json:"intent" yaml:"intent"`
+	Warnings []string `json:"warnings" yaml:"warnings"`
+}
+
+// intentKeys are the settings SummarizeIntent understands, per config type
+// and section.
+var intentKeys = map[string]map[string][]string{
+	"netdev": {
+		"NetDev": {"Name", "Kind"},
+		"Bond":   {"Mode", "MIIMonitorSec"},
+		"VLAN":   {"Id"},
+	},
+	"network": {
+		"Match":        {"Name"},
+		"Network":      {"DHCP", "Address", "Gateway", "DNS", "Bond", "Bridge", "VLAN"},
+		"Address":      {"Address"},
+		"Link":         {"MTUBytes"},
+		"Route":        {"Destination", "Gateway", "Metric", "Table"},
+		"DHCPv4":       {"RouteMetric", "RouteTable"},
+		"IPv6AcceptRA": {"RouteMetric", "RouteTable"},
+	},
+}
+
+// configValues flattens a parsed value into strings.
+func configValues(v interface{}) []string {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return strings.Fields(val)
+	case []string:
+		return val
+	case []interface{}:
+		var out []string
+		for _, item := range val {
+			out = append(out, configValues(item)...)
+		}
+		return out
+	case bool:
+		return []string{boolString(val)}
+	}
+	return []string{fmt.Sprint(v)}
+}
+
+func configValue(v interface{}) string {
+	values := configValues(v)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+func configInt(v interface{}) int {
+	return parseInt(configValue(v))
+}
+
+// intentSummarizer collects interfaces from netdev and network files.
+type intentSummarizer struct {
+	ifaces   map[string]*InterfaceIntent
+	members  map[string][]string // Bond or bridge -> members
+	parents  map[string]string   // VLAN -> parent
+	attached map[string]bool     // Interfaces configured only as a member or parent
+	routes   []RouteIntent
+	warnings []string
+}
+
+func (m *intentSummarizer) warn(format string, args ...interface{}) {
+	m.warnings = append(m.warnings, fmt.Sprintf(format, args...))
+}
+
+func (m *intentSummarizer) iface(name string) *InterfaceIntent {
+	ifc, ok := m.ifaces[name]
+	if !ok {
+		ifc = &InterfaceIntent{Name: name, Type: "ethernet"}
+		m.ifaces[name] = ifc
+	}
+	return ifc
+}
+
+// unsupported warns about every setting of cfg outside intentKeys.
+func (m *intentSummarizer) unsupported(filename, configType string, cfg map[string]interface{}) {
+	known := intentKeys[configType]
+	for _, name := range sortedKeys(cfg) {
+		keys, ok := known[name]
+		if !ok {
+			m.warn("%s: [%s] is not represented", filename, name)
+			continue
+		}
+		for _, item := range sectionItems(cfg[name]) {
+			for _, key := range sortedKeys(item) {
+				if !containsString(keys, key) {
+					m.warn("%s: [%s] %s is not represented", filename, name, key)
+				}
+			}
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SummarizeIntent reads the host's config files back into the intent model.
+// Files that only partly fit are summarised as far as possible and the rest
+// is listed in the warnings.
+func (s *NetworkdService) SummarizeIntent(host string) (*IntentSummary, error) {
+	files, err := s.listConfigFilenames(host)
+	if err != nil {
+		return nil, err
+	}
+	m := &intentSummarizer{
+		ifaces:   make(map[string]*InterfaceIntent),
+		members:  make(map[string][]string),
+		parents:  make(map[string]string),
+		attached: make(map[string]bool),
+	}
+	for _, filename := range files {
+		configType, _ := ConfigTypeForFilename(filename)
+		if configType == "link" {
+			m.warn("%s: .link files are not part of the intent model", filename)
+			continue
+		}
+		content, err := s.ReadNetworkFile(host, filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		cfg, err := INIToMap(content, s.Schema, configType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		if configType == "netdev" {
+			m.netdev(filename, cfg)
+		} else {
+			m.network(filename, cfg)
+		}
+	}
+	return m.result(), nil
+}
+
+func (m *intentSummarizer) netdev(filename string, cfg map[string]interface{}) {
+	netdev, _ := cfg["NetDev"].(map[string]interface{})
+	name, kind := configValue(netdev["Name"]), configValue(netdev["Kind"])
+	if kind != "bond" && kind != "bridge" && kind != "vlan" {
+		m.warn("%s: netdev kind %q is not part of the intent model", filename, kind)
+		return
+	}
+	if ValidateInterfaceName(name) != nil {
+		m.warn("%s: invalid netdev name %q", filename, name)
+		return
+	}
+	ifc := m.iface(name)
+	ifc.Type = kind
+	switch kind {
+	case "bond":
+		bond, _ := cfg["Bond"].(map[string]interface{})
+		ifc.BondMode = configValue(bond["Mode"])
+	case "vlan":
+		vlan, _ := cfg["VLAN"].(map[string]interface{})
+		ifc.VLANID = configInt(vlan["Id"])
+	}
+	m.unsupported(filename, "netdev", cfg)
+}
+
+func (m *intentSummarizer) network(filename string, cfg map[string]interface{}) {
+	match, _ := cfg["Match"].(map[string]interface{})
+	names := configValues(match["Name"])
+	if len(match) != 1 || len(names) != 1 || strings.ContainsAny(names[0], "*?[!") || ValidateInterfaceName(names[0]) != nil {
+		m.warn("%s: only files matching a single interface by Name= are part of the intent model", filename)
+		return
+	}
+	name := names[0]
+	ifc := m.iface(name)
+	network, _ := cfg["Network"].(map[string]interface{})
+
+	if dhcp := configValue(network["DHCP"]); dhcp != "" && dhcp != "no" {
+		ifc.DHCP = dhcp
+	}
+	ifc.Addresses = append(ifc.Addresses, configValues(network["Address"])...)
+	for _, item := range sectionItems(cfg["Address"]) {
+		ifc.Addresses = append(ifc.Addresses, configValues(item["Address"])...)
+	}
+	if gateways := configValues(network["Gateway"]); len(gateways) > 0 {
+		ifc.Gateway = gateways[0]
+		for _, gw := range gateways[1:] {
+			m.routes = append(m.routes, RouteIntent{Via: name, Gateway: gw})
+		}
+	}
+	ifc.DNS = append(ifc.DNS, configValues(network["DNS"])...)
+	if link, ok := cfg["Link"].(map[string]interface{}); ok {
+		ifc.MTU = configInt(link["MTUBytes"])
+	}
+
+	attachedOnly := !ifc.addressed() && ifc.MTU == 0 && len(ifc.DNS) == 0
+	for _, key := range []string{"Bond", "Bridge"} {
+		for _, master := range configValues(network[key]) {
+			m.members[master] = append(m.members[master], name)
+		}
+	}
+	vlans := configValues(network["VLAN"])
+	for _, vlan := range vlans {
+		m.parents[vlan] = name
+	}
+	if attachedOnly && (len(vlans) > 0 || network["Bond"] != nil || network["Bridge"] != nil) {
+		m.attached[name] = true
+	}
+
+	for _, r := range sectionItems(cfg["Route"]) {
+		m.routes = append(m.routes, RouteIntent{
+			To:      configValue(r["Destination"]),
+			Via:     name,
+			Gateway: configValue(r["Gateway"]),
+			Metric:  configInt(r["Metric"]),
+			Table:   configInt(r["Table"]),
+		})
+	}
+	// Metrics of learned default routes; compiled into both sections for
+	// dhcp=yes, so only report one route
+	for _, sec := range []string{"DHCPv4", "IPv6AcceptRA"} {
+		learned, _ := cfg[sec].(map[string]interface{})
+		metric, table := configInt(learned["RouteMetric"]), configInt(learned["RouteTable"])
+		if metric > 0 || table > 0 {
+			m.routes = append(m.routes, RouteIntent{Via: name, Metric: metric, Table: table})
+			break
+		}
+	}
+	m.unsupported(filename, "network", cfg)
+}
+
+func (m *intentSummarizer) result() *IntentSummary {
+	for _, master := range sortedKeys(m.members) {
+		ifc, ok := m.ifaces[master]
+		if !ok || (ifc.Type != "bond" && ifc.Type != "bridge") {
+			m.warn("%s: members %s refer to a bond or bridge without a .netdev", master, strings.Join(m.members[master], ", "))
+			continue
+		}
+		ifc.Members = m.members[master]
+		sort.Strings(ifc.Members)
+	}
+	for _, vlan := range sortedKeys(m.parents) {
+		ifc, ok := m.ifaces[vlan]
+		if !ok || ifc.Type != "vlan" {
+			m.warn("%s: VLAN on %s has no .netdev", vlan, m.parents[vlan])
+			continue
+		}
+		ifc.Parent = m.parents[vlan]
+	}
+
+	summary := &IntentSummary{Intent: Intent{Interfaces: []InterfaceIntent{}}, Warnings: m.warnings}
+	for _, name := range sortedKeys(m.ifaces) {
+		// Members and parents are recreated from the bond, bridge or VLAN
+		if m.attached[name] && m.ifaces[name].Type == "ethernet" {
+			continue
+		}
+		summary.Intent.Interfaces = append(summary.Intent.Interfaces, *m.ifaces[name])
+	}
+	summary.Intent.Routes = m.routes
+	if summary.Warnings == nil {
+		summary.Warnings = []string{}
+	}
+	return summary
+}
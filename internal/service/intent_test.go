@@ -0,0 +1,87 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileIntent(t *testing.T) {
+	intent, err := ParseIntent([]byte(`
+interfaces:
+  - name: eth0
+    role: uplink
+    dhcp: yes
+  - name: bond0
+    type: bond
+    members: [eth1, eth2]
+  - type: vlan
+    role: mgmt
+    parent: bond0
+    vlan_id: 10
+    addresses: [10.0.10.5/24]
+routes:
+  - to: default
+    via: uplink
+    metric: 100
+  - to: 10.20.0.0/16
+    via: mgmt
+    gateway: 10.0.10.1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := compileIntent(intent, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 7 {
+		t.Fatalf("Expected 7 files, got %+v", res.Files)
+	}
+
+	uplink := findFile(res, "10-eth0.network")
+	if uplink["Network"].(map[string]interface{})["DHCP"] != "yes" {
+		t.Errorf("Unexpected uplink %v", uplink)
+	}
+	for _, sec := range []string{"DHCPv4", "IPv6AcceptRA"} {
+		if uplink[sec].(map[string]interface{})["RouteMetric"] != 100 {
+			t.Errorf("Missing %s RouteMetric: %v", sec, uplink)
+		}
+	}
+	if bond := findFile(res, "10-bond0.netdev"); bond["Bond"].(map[string]interface{})["Mode"] != "802.3ad" {
+		t.Errorf("Unexpected bond %v", bond)
+	}
+	if eth1 := findFile(res, "10-eth1.network"); eth1["Network"].(map[string]interface{})["Bond"] != "bond0" {
+		t.Errorf("eth1 not enslaved: %v", eth1)
+	}
+	if parent := findFile(res, "10-bond0.network"); parent["Network"].(map[string]interface{})["VLAN"] != "bond0.10" {
+		t.Errorf("VLAN not attached to its parent: %v", parent)
+	}
+	mgmt := findFile(res, "10-bond0.10.network")
+	routes, _ := mgmt["Route"].([]interface{})
+	if len(routes) != 1 || routes[0].(map[string]interface{})["Gateway"] != "10.0.10.1" {
+		t.Errorf("Unexpected mgmt routes: %v", mgmt)
+	}
+}
+
+func TestCompileIntentErrors(t *testing.T) {
+	for _, tc := range []struct {
+		doc, want string
+	}{
+		{`{"interfaces": [{"name": "eth0", "dhcp": "maybe"}]}`, "invalid dhcp"},
+		{`{"interfaces": [{"name": "bond0", "type": "bond", "members": ["eth1"]}, {"name": "eth1", "addresses": ["10.0.0.1/24"]}]}`, "cannot have its own addressing"},
+		{`{"interfaces": [{"name": "eth0", "addresses": ["10.0.0.1/24"]}], "routes": [{"via": "eth0"}]}`, "needs a gateway or DHCP"},
+		{`{"interfaces": [{"name": "eth0"}], "routes": [{"via": "wan", "gateway": "10.0.0.1"}]}`, "neither an interface nor a role"},
+		{`{"interfaces": [{"name": "v10", "type": "vlan", "parent": "eth0", "vlan_id": 5000}]}`, "invalid VLAN id"},
+	} {
+		intent, err := ParseIntent([]byte(tc.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := compileIntent(intent, ""); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q, got %v", tc.doc, tc.want, err)
+		}
+	}
+	if _, err := ParseIntent([]byte(`{"interfaces": [{"nmae": "eth0"}]}`)); err == nil {
+		t.Error("Expected unknown field to be rejected")
+	}
+}
@@ -0,0 +1,703 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidPool  = errors.New("invalid pool")
+	ErrPoolNotFound = errors.New("pool not found")
+	// ErrAddressInUse marks allocations that collide with a used address
+	// or find none left in the pool
+	ErrAddressInUse = errors.New("address in use")
+	// ErrScanIncomplete marks allocations refused because some managed
+	// hosts could not be scanned for the addresses they use
+	ErrScanIncomplete = errors.New("address scan incomplete")
+)
+
+// PoolAddressPrefix marks Address= and Gateway= values in create and update
+// requests that are to be filled from a pool, e.g. "ipam:mgmt".
+const PoolAddressPrefix = "ipam:"
+
+// IPAMPool is a prefix from which static addresses are handed out.
+// Allocations carry the prefix length, e.g. 10.0.10.5/24.
+type IPAMPool struct {
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	// Start and End narrow the allocatable range; default all usable addresses
+	Start       string           `json:"start,omitempty"`
+	End         string           `json:"end,omitempty"`
+	Gateway     string           `json:"gateway,omitempty"` // Never allocated
+	Reserved    []string         `json:"reserved,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Allocations []IPAMAllocation `json:"allocations"`
+}
+
+type IPAMAllocation struct {
+	Address string `json:"address"` // With prefix length
+	// Pool is set on allocations returned to callers, not stored ones
+	Pool        string    `json:"pool,omitempty"`
+	Host        string    `json:"host"`
+	Interface   string    `json:"interface,omitempty"`
+	Filename    string    `json:"filename,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	AllocatedAt time.Time `json:"allocated_at"`
+}
+
+// IPAMManager stores the pools in DataDir/ipam.json.
+type IPAMManager struct {
+	DataDir string
+	Pools   map[string]*IPAMPool
+	mu      sync.Mutex
+}
+
+func NewIPAMManager(dataDir string) (*IPAMManager, error) {
+	m := &IPAMManager{DataDir: dataDir, Pools: make(map[string]*IPAMPool)}
+	path := filepath.Join(dataDir, "ipam.json")
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return m, err
+	}
+	var pools []*IPAMPool
+	if err := json.Unmarshal(content, &pools); err != nil {
+		return m, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, p := range pools {
+		m.Pools[p.Name] = p
+	}
+	return m, nil
+}
+
+// save writes all pools; the caller holds mu.
+func (m *IPAMManager) save() error {
+	pools := make([]*IPAMPool, 0, len(m.Pools))
+	for _, name := range sortedKeys(m.Pools) {
+		pools = append(pools, m.Pools[name])
+	}
+	content, err := json.MarshalIndent(pools, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.DataDir, "ipam.json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func copyPool(p *IPAMPool) IPAMPool {
+	c := *p
+	c.Reserved = append([]string(nil), p.Reserved...)
+	c.Allocations = append([]IPAMAllocation{}, p.Allocations...)
+	return c
+}
+
+func (m *IPAMManager) ListPools() []IPAMPool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pools := make([]IPAMPool, 0, len(m.Pools))
+	for _, name := range sortedKeys(m.Pools) {
+		pools = append(pools, copyPool(m.Pools[name]))
+	}
+	return pools
+}
+
+func (m *IPAMManager) GetPool(name string) (IPAMPool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.Pools[name]
+	if !ok {
+		return IPAMPool{}, fmt.Errorf("%w: %s", ErrPoolNotFound, name)
+	}
+	return copyPool(p), nil
+}
+
+// poolRange is a validated pool: its prefix and the allocatable range.
+type poolRange struct {
+	prefix     netip.Prefix
+	start, end netip.Addr
+	excluded   map[netip.Addr]bool
+}
+
+func (p *IPAMPool) parse() (*poolRange, error) {
+	prefix, err := netip.ParsePrefix(p.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid prefix %q", ErrInvalidPool, p.Prefix)
+	}
+	prefix = prefix.Masked()
+	r := &poolRange{prefix: prefix, excluded: make(map[netip.Addr]bool)}
+
+	bits := prefix.Addr().BitLen() - prefix.Bits()
+	r.start, r.end = prefix.Addr(), lastAddr(prefix)
+	if bits > 1 {
+		// Skip the network address, and the broadcast address on IPv4
+		r.start = r.start.Next()
+		if prefix.Addr().Is4() {
+			r.end = r.end.Prev()
+		}
+	}
+	inPrefix := func(field, s string) (netip.Addr, error) {
+		a, err := netip.ParseAddr(s)
+		if err != nil || !prefix.Contains(a) {
+			return netip.Addr{}, fmt.Errorf("%w: %s %q is not an address in %s", ErrInvalidPool, field, s, prefix)
+		}
+		return a, nil
+	}
+	if p.Start != "" {
+		if r.start, err = inPrefix("start", p.Start); err != nil {
+			return nil, err
+		}
+	}
+	if p.End != "" {
+		if r.end, err = inPrefix("end", p.End); err != nil {
+			return nil, err
+		}
+	}
+	if r.end.Less(r.start) {
+		return nil, fmt.Errorf("%w: empty range %s-%s", ErrInvalidPool, r.start, r.end)
+	}
+	if p.Gateway != "" {
+		gw, err := inPrefix("gateway", p.Gateway)
+		if err != nil {
+			return nil, err
+		}
+		r.excluded[gw] = true
+	}
+	for _, s := range p.Reserved {
+		a, err := inPrefix("reserved address", s)
+		if err != nil {
+			return nil, err
+		}
+		r.excluded[a] = true
+	}
+	return r, nil
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+// AddPool validates and stores a new pool.
+func (m *IPAMManager) AddPool(p IPAMPool) (IPAMPool, error) {
+	if p.Name == "" || strings.ContainsAny(p.Name, "/: \t\n") {
+		return IPAMPool{}, fmt.Errorf("%w: invalid name %q", ErrInvalidPool, p.Name)
+	}
+	r, err := p.parse()
+	if err != nil {
+		return IPAMPool{}, err
+	}
+	p.Prefix = r.prefix.String()
+	p.Allocations = []IPAMAllocation{}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.Pools[p.Name]; ok {
+		return IPAMPool{}, fmt.Errorf("%w: pool %s already exists", ErrInvalidPool, p.Name)
+	}
+	for _, other := range m.Pools {
+		if o, err := netip.ParsePrefix(other.Prefix); err == nil && o.Overlaps(r.prefix) {
+			return IPAMPool{}, fmt.Errorf("%w: %s overlaps pool %s (%s)", ErrInvalidPool, r.prefix, other.Name, other.Prefix)
+		}
+	}
+	m.Pools[p.Name] = &p
+	if err := m.save(); err != nil {
+		delete(m.Pools, p.Name)
+		return IPAMPool{}, err
+	}
+	return copyPool(&p), nil
+}
+
+// RemovePool deletes a pool; pools with allocations are only removed with force.
+func (m *IPAMManager) RemovePool(name string, force bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.Pools[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPoolNotFound, name)
+	}
+	if len(p.Allocations) > 0 && !force {
+		return fmt.Errorf("%w: pool %s still has %d allocations", ErrAddressInUse, name, len(p.Allocations))
+	}
+	delete(m.Pools, name)
+	if err := m.save(); err != nil {
+		m.Pools[name] = p
+		return err
+	}
+	return nil
+}
+
+// allocate records an allocation from pool. With req.Address empty the
+// lowest free address is taken; used lists addresses found on hosts, which
+// are skipped (or refused when requested explicitly).
+func (m *IPAMManager) allocate(name string, req IPAMAllocation, used map[netip.Addr][]AddressUse) (IPAMAllocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.Pools[name]
+	if !ok {
+		return IPAMAllocation{}, fmt.Errorf("%w: %s", ErrPoolNotFound, name)
+	}
+	r, err := p.parse()
+	if err != nil {
+		return IPAMAllocation{}, err
+	}
+	taken := make(map[netip.Addr]bool, len(p.Allocations))
+	for _, a := range p.Allocations {
+		if pfx, err := netip.ParsePrefix(a.Address); err == nil {
+			taken[pfx.Addr()] = true
+		}
+	}
+	free := func(a netip.Addr) error {
+		switch {
+		case taken[a]:
+			return fmt.Errorf("%w: %s is already allocated from pool %s", ErrAddressInUse, a, name)
+		case r.excluded[a]:
+			return fmt.Errorf("%w: %s is reserved in pool %s", ErrAddressInUse, a, name)
+		case len(used[a]) > 0:
+			u := used[a][0]
+			return fmt.Errorf("%w: %s is used on %s/%s", ErrAddressInUse, a, u.Host, u.Interface)
+		}
+		return nil
+	}
+
+	var addr netip.Addr
+	if req.Address != "" {
+		a, err := netip.ParseAddr(strings.Split(req.Address, "/")[0])
+		if err != nil || a.Less(r.start) || r.end.Less(a) {
+			return IPAMAllocation{}, fmt.Errorf("%w: %s is not in the range of pool %s", ErrInvalidPool, req.Address, name)
+		}
+		if err := free(a); err != nil {
+			return IPAMAllocation{}, err
+		}
+		addr = a
+	} else {
+		for a := r.start; ; a = a.Next() {
+			if free(a) == nil {
+				addr = a
+				break
+			}
+			if a == r.end {
+				return IPAMAllocation{}, fmt.Errorf("%w: pool %s is exhausted", ErrAddressInUse, name)
+			}
+		}
+	}
+
+	req.Address = netip.PrefixFrom(addr, r.prefix.Bits()).String()
+	req.Pool = ""
+	req.Host = normalizeHost(req.Host)
+	req.AllocatedAt = time.Now().UTC()
+	p.Allocations = append(p.Allocations, req)
+	sortAllocations(p.Allocations)
+	if err := m.save(); err != nil {
+		p.Allocations = removeAllocation(p.Allocations, addr)
+		return IPAMAllocation{}, err
+	}
+	req.Pool = name
+	return req, nil
+}
+
+func sortAllocations(allocs []IPAMAllocation) {
+	sort.Slice(allocs, func(i, j int) bool {
+		a, _ := netip.ParsePrefix(allocs[i].Address)
+		b, _ := netip.ParsePrefix(allocs[j].Address)
+		return a.Addr().Less(b.Addr())
+	})
+}
+
+func removeAllocation(allocs []IPAMAllocation, addr netip.Addr) []IPAMAllocation {
+	out := allocs[:0]
+	for _, a := range allocs {
+		if pfx, err := netip.ParsePrefix(a.Address); err != nil || pfx.Addr() != addr {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Release returns an address to its pool.
+func (m *IPAMManager) Release(name, address string) error {
+	addr, err := netip.ParseAddr(strings.Split(address, "/")[0])
+	if err != nil {
+		return fmt.Errorf("%w: invalid address %q", ErrInvalidPool, address)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.Pools[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPoolNotFound, name)
+	}
+	before := len(p.Allocations)
+	p.Allocations = removeAllocation(p.Allocations, addr)
+	if len(p.Allocations) == before {
+		return fmt.Errorf("%w: %s is not allocated from pool %s", ErrPoolNotFound, addr, name)
+	}
+	return m.save()
+}
+
+// fileAllocation returns an allocation from pool recorded for host and
+// filename whose address is not in skip.
+func (m *IPAMManager) fileAllocation(name, host, filename string, skip map[string]bool) (IPAMAllocation, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.Pools[name]
+	if !ok {
+		return IPAMAllocation{}, false
+	}
+	for _, a := range p.Allocations {
+		if a.Host == host && a.Filename == filename && !skip[a.Address] {
+			a.Pool = name
+			return a, true
+		}
+	}
+	return IPAMAllocation{}, false
+}
+
+// releaseFile releases the allocations recorded for host and filename in
+// all pools, except the addresses in keep.
+func (m *IPAMManager) releaseFile(host, filename string, keep map[netip.Addr]bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := false
+	for _, p := range m.Pools {
+		out := p.Allocations[:0]
+		for _, a := range p.Allocations {
+			pfx, err := netip.ParsePrefix(a.Address)
+			if a.Host == host && a.Filename == filename && err == nil && !keep[pfx.Addr()] {
+				changed = true
+				continue
+			}
+			out = append(out, a)
+		}
+		p.Allocations = out
+	}
+	if !changed {
+		return nil
+	}
+	return m.save()
+}
+
+// AddressUse is an address found on a managed host, either in a .network
+// file or on a running link.
+type AddressUse struct {
+	Address   string `json:"address"` // With prefix length
+	Host      string `json:"host"`
+	Interface string `json:"interface,omitempty"`
+	Filename  string `json:"filename,omitempty"` // Empty for runtime addresses
+	Source    string `json:"source"`             // "config" or "runtime"
+}
+
+// AddressUsage is the result of scanning all managed hosts. Hosts that
+// could not be scanned are listed in Errors rather than failing the scan.
+type AddressUsage struct {
+	Addresses []AddressUse      `json:"addresses"`
+	Errors    map[string]string `json:"errors"`
+}
+
+// managedHosts returns "local" followed by the registered hosts, sorted.
+func (s *NetworkdService) managedHosts() []string {
+	hosts := []string{"local"}
+	if s.HostManager == nil {
+		return hosts
+	}
+	var names []string
+	for _, h := range s.HostManager.ListHosts() {
+		names = append(names, h.Name)
+	}
+	sort.Strings(names)
+	return append(hosts, names...)
+}
+
+// ScanAddressUsage collects the static addresses of every managed host's
+// .network files and the addresses on its running links.
+func (s *NetworkdService) ScanAddressUsage() *AddressUsage {
+	inv := s.fleetInventory()
+	return &AddressUsage{Addresses: inv.addrs, Errors: inv.errors}
+}
+
+// allocationScan scans all hosts before allocating. Unless
+// IPAMIgnoreScanErrors is set, a host that cannot be scanned fails the
+// allocation, since an address in use there would go unnoticed.
+func (s *NetworkdService) allocationScan() ([]AddressUse, error) {
+	usage := s.ScanAddressUsage()
+	if len(usage.Errors) > 0 && !s.IPAMIgnoreScanErrors {
+		var failed []string
+		for _, host := range sortedKeys(usage.Errors) {
+			failed = append(failed, host+": "+usage.Errors[host])
+		}
+		return nil, fmt.Errorf("%w: %s", ErrScanIncomplete, strings.Join(failed, "; "))
+	}
+	return usage.Addresses, nil
+}
+
+// usesByAddr indexes uses by address, leaving out those on host/iface,
+// which may keep using their own address.
+func usesByAddr(uses []AddressUse, host, iface string) map[netip.Addr][]AddressUse {
+	byAddr := make(map[netip.Addr][]AddressUse)
+	for _, u := range uses {
+		if u.Host == host && iface != "" && u.Interface == iface {
+			continue
+		}
+		if pfx, err := netip.ParsePrefix(u.Address); err == nil {
+			byAddr[pfx.Addr()] = append(byAddr[pfx.Addr()], u)
+		}
+	}
+	return byAddr
+}
+
+// AllocateAddress takes the next free address (or req.Address) from a pool,
+// skipping every address already in use on a managed host.
+func (s *NetworkdService) AllocateAddress(pool string, req IPAMAllocation) (IPAMAllocation, error) {
+	if _, err := s.IPAM.GetPool(pool); err != nil {
+		return IPAMAllocation{}, err
+	}
+	uses, err := s.allocationScan()
+	if err != nil {
+		return IPAMAllocation{}, err
+	}
+	return s.IPAM.allocate(pool, req, usesByAddr(uses, normalizeHost(req.Host), req.Interface))
+}
+
+// PoolConflict is a disagreement between a pool and what the hosts use.
+type PoolConflict struct {
+	Address string `json:"address"`
+	// Kind is "duplicate" (used more than once), "unallocated" (used but not
+	// recorded in the pool) or "mismatch" (allocated to another host/interface)
+	Kind   string       `json:"kind"`
+	Detail string       `json:"detail"`
+	Uses   []AddressUse `json:"uses"`
+}
+
+type PoolStatus struct {
+	IPAMPool
+	Size      int               `json:"size"`
+	Free      int               `json:"free"`
+	Used      []AddressUse      `json:"used"`
+	Conflicts []PoolConflict    `json:"conflicts"`
+	Errors    map[string]string `json:"errors"`
+}
+
+// GetPoolStatus compares a pool with the addresses found on all hosts.
+func (s *NetworkdService) GetPoolStatus(name string) (*PoolStatus, error) {
+	pool, err := s.IPAM.GetPool(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := pool.parse()
+	if err != nil {
+		return nil, err
+	}
+	usage := s.ScanAddressUsage()
+	st := &PoolStatus{IPAMPool: pool, Used: []AddressUse{}, Conflicts: []PoolConflict{}, Errors: usage.Errors}
+
+	byAddr := make(map[netip.Addr][]AddressUse)
+	for _, u := range usage.Addresses {
+		if pfx, err := netip.ParsePrefix(u.Address); err == nil && r.prefix.Contains(pfx.Addr()) {
+			st.Used = append(st.Used, u)
+			byAddr[pfx.Addr()] = append(byAddr[pfx.Addr()], u)
+		}
+	}
+	allocated := make(map[netip.Addr]IPAMAllocation)
+	for _, a := range pool.Allocations {
+		if pfx, err := netip.ParsePrefix(a.Address); err == nil {
+			allocated[pfx.Addr()] = a
+		}
+	}
+
+	addrs := make([]netip.Addr, 0, len(byAddr))
+	for a := range byAddr {
+		addrs = append(addrs, a)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Less(addrs[j]) })
+	for _, a := range addrs {
+		uses := byAddr[a]
+		// The same address in a host's config and on its link is one use
+		owners, _ := addressOwners(uses)
+		alloc, isAllocated := allocated[a]
+		switch {
+		case len(owners) > 1:
+			st.Conflicts = append(st.Conflicts, PoolConflict{Address: a.String(), Kind: "duplicate", Detail: fmt.Sprintf("used by %d interfaces", len(owners)), Uses: uses})
+		case !isAllocated && !r.excluded[a]:
+			st.Conflicts = append(st.Conflicts, PoolConflict{Address: a.String(), Kind: "unallocated", Detail: "in use but not allocated from the pool", Uses: uses})
+		case isAllocated && (uses[0].Host != alloc.Host || (alloc.Interface != "" && uses[0].Interface != alloc.Interface)):
+			st.Conflicts = append(st.Conflicts, PoolConflict{Address: a.String(), Kind: "mismatch", Detail: fmt.Sprintf("allocated to %s/%s", alloc.Host, alloc.Interface), Uses: uses})
+		}
+	}
+
+	st.Size = rangeSize(r.start, r.end)
+	taken := len(r.excluded)
+	for a := range allocated {
+		if !r.excluded[a] {
+			taken++
+		}
+	}
+	for a := range byAddr {
+		if _, ok := allocated[a]; !ok && !r.excluded[a] && !a.Less(r.start) && !r.end.Less(a) {
+			taken++
+		}
+	}
+	st.Free = max(st.Size-taken, 0)
+	return st, nil
+}
+
+// rangeSize counts the addresses from start to end, capped at MaxInt32 for
+// large IPv6 ranges.
+func rangeSize(start, end netip.Addr) int {
+	const limit = 1<<31 - 1
+	a, b := start.As16(), end.As16()
+	n := 0
+	for i := 0; i < 16; i++ {
+		n = n*256 + int(b[i]) - int(a[i])
+		if n > limit {
+			return limit
+		}
+	}
+	return n + 1
+}
+
+// ResolvePoolAddresses replaces "ipam:<pool>" in a .network config's
+// Address= values with addresses for host, and in Gateway= with the pool's
+// gateway. An address the pool already holds for the same host and file is
+// reused, so resubmitting a config does not take a new one; otherwise the
+// hosts are scanned once and the next free address is allocated. The new
+// allocations are returned so the caller can release them if the config is
+// not written after all.
+func (s *NetworkdService) ResolvePoolAddresses(host, filename, configType string, cfg map[string]interface{}) ([]IPAMAllocation, error) {
+	if configType != "network" || s.IPAM == nil {
+		return nil, nil
+	}
+	host = normalizeHost(host)
+	match, _ := cfg["Match"].(map[string]interface{})
+	iface := strings.Join(configValues(match["Name"]), " ")
+
+	var allocs []IPAMAllocation
+	var used map[netip.Addr][]AddressUse
+	reused := map[string]bool{}
+	resolve := func(v string) (string, error) {
+		pool, ok := strings.CutPrefix(v, PoolAddressPrefix)
+		if !ok {
+			return v, nil
+		}
+		if a, ok := s.IPAM.fileAllocation(pool, host, filename, reused); ok {
+			reused[a.Address] = true
+			return a.Address, nil
+		}
+		if _, err := s.IPAM.GetPool(pool); err != nil {
+			return "", err
+		}
+		if used == nil {
+			uses, err := s.allocationScan()
+			if err != nil {
+				return "", err
+			}
+			used = usesByAddr(uses, host, iface)
+		}
+		a, err := s.IPAM.allocate(pool, IPAMAllocation{Host: host, Interface: iface, Filename: filename}, used)
+		if err != nil {
+			return "", err
+		}
+		allocs = append(allocs, a)
+		reused[a.Address] = true
+		return a.Address, nil
+	}
+	replace := func(sec map[string]interface{}, key string) error {
+		switch v := sec[key].(type) {
+		case string:
+			r, err := resolve(v)
+			sec[key] = r
+			return err
+		case []interface{}:
+			for i, item := range v {
+				if str, ok := item.(string); ok {
+					r, err := resolve(str)
+					if err != nil {
+						return err
+					}
+					v[i] = r
+				}
+			}
+		}
+		return nil
+	}
+
+	network, _ := cfg["Network"].(map[string]interface{})
+	sections := []map[string]interface{}{}
+	if network != nil {
+		sections = append(sections, network)
+	}
+	sections = append(sections, sectionItems(cfg["Address"])...)
+	for _, sec := range sections {
+		if err := replace(sec, "Address"); err != nil {
+			s.ReleaseAllocations(allocs)
+			return nil, err
+		}
+	}
+	if gw, ok := network["Gateway"].(string); ok {
+		if pool, ok := strings.CutPrefix(gw, PoolAddressPrefix); ok {
+			p, err := s.IPAM.GetPool(pool)
+			if err == nil && p.Gateway == "" {
+				err = fmt.Errorf("%w: pool %s has no gateway", ErrInvalidPool, pool)
+			}
+			if err != nil {
+				s.ReleaseAllocations(allocs)
+				return nil, err
+			}
+			network["Gateway"] = p.Gateway
+		}
+	}
+	return allocs, nil
+}
+
+// releaseStaleAllocations runs after changes were written: allocations
+// recorded for a .network file that was deleted, or whose addresses no
+// longer include them, go back to their pools.
+func (s *NetworkdService) releaseStaleAllocations(host string, changes []fileChange) {
+	if s.IPAM == nil {
+		return
+	}
+	for _, ch := range changes {
+		if configType, ok := ConfigTypeForFilename(ch.filename); !ok || configType != "network" {
+			continue
+		}
+		keep := map[netip.Addr]bool{}
+		if !ch.delete {
+			cfg, err := INIToMap(ch.content, s.Schema, "network")
+			if err != nil {
+				continue
+			}
+			network, _ := cfg["Network"].(map[string]interface{})
+			values := configValues(network["Address"])
+			for _, item := range sectionItems(cfg["Address"]) {
+				values = append(values, configValues(item["Address"])...)
+			}
+			for _, v := range values {
+				if pfx, err := netip.ParsePrefix(v); err == nil {
+					keep[pfx.Addr()] = true
+				}
+			}
+		}
+		s.IPAM.releaseFile(normalizeHost(host), ch.filename, keep)
+	}
+}
+
+// ReleaseAllocations undoes ResolvePoolAddresses.
+func (s *NetworkdService) ReleaseAllocations(allocs []IPAMAllocation) {
+	for _, a := range allocs {
+		s.IPAM.Release(a.Pool, a.Address)
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIPAMAllocate(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewIPAMManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddPool(IPAMPool{Name: "lan", Prefix: "10.0.10.0/29", Gateway: "10.0.10.1", Reserved: []string{"10.0.10.3"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddPool(IPAMPool{Name: "other", Prefix: "10.0.10.4/30"}); !errors.Is(err, ErrInvalidPool) {
+		t.Errorf("Expected overlapping pool to be rejected, got %v", err)
+	}
+
+	used := map[netip.Addr][]AddressUse{
+		netip.MustParseAddr("10.0.10.4"): {{Address: "10.0.10.4/29", Host: "local", Interface: "eth0", Source: "runtime"}},
+	}
+	// .1 is the gateway, .3 reserved and .4 in use
+	var got []string
+	for i := 0; i < 3; i++ {
+		a, err := m.allocate("lan", IPAMAllocation{Host: "", Interface: "eth1"}, used)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, a.Address)
+		if a.Pool != "lan" || a.Host != "local" {
+			t.Errorf("Unexpected allocation %+v", a)
+		}
+	}
+	if got[0] != "10.0.10.2/29" || got[1] != "10.0.10.5/29" || got[2] != "10.0.10.6/29" {
+		t.Errorf("Unexpected addresses %v", got)
+	}
+	if _, err := m.allocate("lan", IPAMAllocation{}, used); !errors.Is(err, ErrAddressInUse) {
+		t.Errorf("Expected exhausted pool, got %v", err)
+	}
+	if _, err := m.allocate("lan", IPAMAllocation{Address: "10.0.10.4"}, nil); err != nil {
+		t.Errorf("Explicit address not allocated: %v", err)
+	}
+	if err := m.Release("lan", "10.0.10.5"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Allocations survive a reload
+	m, err = NewIPAMManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := m.GetPool("lan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.Allocations) != 3 || pool.Allocations[1].Address != "10.0.10.4/29" {
+		t.Errorf("Unexpected stored allocations %+v", pool.Allocations)
+	}
+	if err := m.RemovePool("lan", false); !errors.Is(err, ErrAddressInUse) {
+		t.Errorf("Expected pool with allocations to be kept, got %v", err)
+	}
+}
+
+func TestPoolAddressLifecycle(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	svc.IPAM, _ = NewIPAMManager(tmpDir)
+	// Listing local links may fail in a sandbox
+	svc.IPAMIgnoreScanErrors = true
+	if _, err := svc.IPAM.AddPool(IPAMPool{Name: "mgmt", Prefix: "10.0.10.0/24", Gateway: "10.0.10.1"}); err != nil {
+		t.Fatal(err)
+	}
+	write := func(address string) []IPAMAllocation {
+		t.Helper()
+		cfg := map[string]interface{}{
+			"Match":   map[string]interface{}{"Name": "eth0"},
+			"Network": map[string]interface{}{"Address": address},
+		}
+		allocs, err := svc.WriteConfig("local", "10-eth0.network", "network", cfg, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return allocs
+	}
+	allocations := func() []IPAMAllocation {
+		pool, _ := svc.IPAM.GetPool("mgmt")
+		return pool.Allocations
+	}
+
+	if allocs := write("ipam:mgmt"); len(allocs) != 1 || allocs[0].Address != "10.0.10.2/24" {
+		t.Fatalf("Unexpected allocations: %+v", allocs)
+	}
+	// Resubmitting the pool reference keeps the address
+	if allocs := write("ipam:mgmt"); len(allocs) != 0 {
+		t.Errorf("Expected the allocation to be reused, got %+v", allocs)
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "10-eth0.network"))
+	if !strings.Contains(string(content), "10.0.10.2/24") || len(allocations()) != 1 {
+		t.Errorf("Unexpected state after resubmit: %s %+v", content, allocations())
+	}
+	// Replacing the address releases it
+	write("192.168.1.5/24")
+	if len(allocations()) != 0 {
+		t.Errorf("Replaced address not released: %+v", allocations())
+	}
+	write("ipam:mgmt")
+	if err := svc.DeleteConfig("local", "10-eth0.network"); err != nil {
+		t.Fatal(err)
+	}
+	if len(allocations()) != 0 {
+		t.Errorf("Address of deleted file not released: %+v", allocations())
+	}
+
+	// A host that cannot be scanned might use the next address
+	svc.HostManager.AddHost(HostConfig{Name: "down", Host: "127.0.0.1", User: "networkd-api", Port: 1})
+	svc.IPAMIgnoreScanErrors = false
+	if _, err := svc.AllocateAddress("mgmt", IPAMAllocation{Host: "local", Interface: "eth1"}); !errors.Is(err, ErrScanIncomplete) {
+		t.Errorf("Expected incomplete scan to refuse the allocation, got %v", err)
+	}
+	svc.IPAMIgnoreScanErrors = true
+	if _, err := svc.AllocateAddress("mgmt", IPAMAllocation{Host: "local", Interface: "eth1"}); err != nil {
+		t.Errorf("Allocation with IPAMIgnoreScanErrors failed: %v", err)
+	}
+}
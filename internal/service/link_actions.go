@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Runtime actions on a single link, mirroring the networkctl verbs.
+const (
+	LinkActionUp          = "up"
+	LinkActionDown        = "down"
+	LinkActionRenew       = "renew"
+	LinkActionForceRenew  = "forcerenew"
+	LinkActionReconfigure = "reconfigure"
+	LinkActionDelete      = "delete"
+)
+
+var linkActions = map[string]bool{
+	LinkActionUp: true, LinkActionDown: true, LinkActionRenew: true,
+	LinkActionForceRenew: true, LinkActionReconfigure: true, LinkActionDelete: true,
+}
+
+// disruptiveLinkActions take the link out of service.
+var disruptiveLinkActions = map[string]bool{LinkActionDown: true, LinkActionDelete: true}
+
+// ErrProtectedLink is returned when a disruptive action targets the loopback
+// device or the link the API reaches the host through.
+var ErrProtectedLink = errors.New("protected link")
+
+// LinkActionResult records what was run, for the caller and for audit logs.
+type LinkActionResult struct {
+	Host       string    `json:"host"`
+	Interface  string    `json:"interface"`
+	Action     string    `json:"action"`
+	Method     string    `json:"method"` // D-Bus method or command line that was executed
+	Output     string    `json:"output"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// LinkActionOptions controls the safety checks of RunLinkAction.
+type LinkActionOptions struct {
+	// Force allows down/delete on protected links
+	Force bool
+	// ManagementAddrs are additional addresses whose link must not be taken
+	// down, e.g. the local address an API client connected to
+	ManagementAddrs []string
+}
+
+func ValidateLinkAction(action string) error {
+	if !linkActions[action] {
+		return fmt.Errorf("unknown link action: %s", action)
+	}
+	return nil
+}
+
+// managementAddrs returns the addresses the API uses to reach host: the
+// configured host address and, for remote hosts, the address the SSH session
+// actually arrived on.
+func (s *NetworkdService) managementAddrs(host string, extra []string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, a := range extra {
+		if ip := net.ParseIP(a); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	if normalizeHost(host) == "local" || s.HostManager == nil {
+		return ips, nil
+	}
+	if cfg, ok := s.HostManager.GetHost(host); ok {
+		if ip := net.ParseIP(cfg.Host); ip != nil {
+			ips = append(ips, ip)
+		} else if resolved, err := net.LookupIP(cfg.Host); err == nil {
+			ips = append(ips, resolved...)
+		}
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	session, err := c.GetSessionAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine the management address: %w", err)
+	}
+	for _, a := range session {
+		if ip := net.ParseIP(a); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// checkLinkProtected refuses disruptive actions on loopback and on links that
+// carry one of the management addresses.
+func (s *NetworkdService) checkLinkProtected(host, iface string, opts LinkActionOptions) error {
+	if iface == "lo" {
+		return fmt.Errorf("%w: %s is the loopback device", ErrProtectedLink, iface)
+	}
+	mgmt, err := s.managementAddrs(host, opts.ManagementAddrs)
+	if err != nil {
+		return err
+	}
+	if len(mgmt) == 0 {
+		return nil
+	}
+	details, err := s.GetLinkDetails(host, iface)
+	if err != nil {
+		return err
+	}
+	for _, a := range details.Addresses {
+		ip := net.ParseIP(a.Address)
+		for _, m := range mgmt {
+			if ip != nil && ip.Equal(m) {
+				return fmt.Errorf("%w: %s carries the management address %s (use force to override)", ErrProtectedLink, iface, a.Address)
+			}
+		}
+	}
+	return nil
+}
+
+// RunLinkAction runs a networkctl-style action on one link. Down and delete
+// are refused for protected links unless opts.Force is set. Failures of the
+// action itself are reported in the result; the error is reserved for
+// invalid requests and refused actions.
+func (s *NetworkdService) RunLinkAction(host, iface, action string, opts LinkActionOptions) (*LinkActionResult, error) {
+	if err := ValidateInterfaceName(iface); err != nil {
+		return nil, err
+	}
+	if err := ValidateLinkAction(action); err != nil {
+		return nil, err
+	}
+	if disruptiveLinkActions[action] && !opts.Force {
+		if err := s.checkLinkProtected(host, iface, opts); err != nil {
+			return nil, err
+		}
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LinkActionResult{
+		Host:      normalizeHost(host),
+		Interface: iface,
+		Action:    action,
+		StartedAt: time.Now().UTC(),
+	}
+	method, output, err := c.LinkAction(iface, action)
+	result.DurationMS = time.Since(result.StartedAt).Milliseconds()
+	result.Method = method
+	result.Output = output
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
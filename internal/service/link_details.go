@@ -0,0 +1,325 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LinkAddress is an address assigned to a link, with where it came from.
+type LinkAddress struct {
+	Address      string `json:"address"`
+	PrefixLength int    `json:"prefix_length"`
+	ConfigSource string `json:"config_source,omitempty"` // static, DHCPv4, DHCPv6, NDisc, foreign, ...
+	ConfigState  string `json:"config_state,omitempty"`
+}
+
+// DHCPLease describes the DHCPv4 lease a link currently holds.
+type DHCPLease struct {
+	Address    string     `json:"address,omitempty"`
+	Server     string     `json:"server,omitempty"`
+	ObtainedAt *time.Time `json:"obtained_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// LLDPNeighbor is a neighbour seen via LLDP on a link.
+type LLDPNeighbor struct {
+	Interface         string   `json:"interface,omitempty"` // Local interface the neighbour was seen on
+	ChassisID         string   `json:"chassis_id,omitempty"`
+	PortID            string   `json:"port_id,omitempty"`
+	PortDescription   string   `json:"port_description,omitempty"`
+	SystemName        string   `json:"system_name,omitempty"`
+	SystemDescription string   `json:"system_description,omitempty"`
+	Capabilities      []string `json:"capabilities,omitempty"`
+}
+
+// LinkDetails is the full runtime picture of a single link, as shown by
+// `networkctl status <link>`.
+type LinkDetails struct {
+	Index                    int      `json:"index"`
+	Name                     string   `json:"name"`
+	AlternativeNames         []string `json:"alternative_names,omitempty"`
+	Type                     string   `json:"type,omitempty"`
+	Kind                     string   `json:"kind,omitempty"`
+	Driver                   string   `json:"driver,omitempty"`
+	Path                     string   `json:"path,omitempty"`
+	Vendor                   string   `json:"vendor,omitempty"`
+	Model                    string   `json:"model,omitempty"`
+	HardwareAddress          string   `json:"hardware_address,omitempty"`
+	PermanentHardwareAddress string   `json:"permanent_hardware_address,omitempty"`
+	MTU                      int      `json:"mtu,omitempty"`
+	SpeedMbps                int      `json:"speed_mbps,omitempty"`
+	Duplex                   string   `json:"duplex,omitempty"`
+
+	SetupState        string `json:"setup_state"`
+	OperationalState  string `json:"operational_state"`
+	CarrierState      string `json:"carrier_state,omitempty"`
+	AddressState      string `json:"address_state,omitempty"`
+	IPv4AddressState  string `json:"ipv4_address_state,omitempty"`
+	IPv6AddressState  string `json:"ipv6_address_state,omitempty"`
+	OnlineState       string `json:"online_state,omitempty"`
+	RequiredForOnline bool   `json:"required_for_online"`
+
+	NetworkFile        string   `json:"network_file,omitempty"`
+	NetworkFileDropins []string `json:"network_file_dropins,omitempty"`
+	LinkFile           string   `json:"link_file,omitempty"`
+
+	Addresses     []LinkAddress  `json:"addresses"`
+	Gateways      []string       `json:"gateways"`
+	DNS           []string       `json:"dns"`
+	SearchDomains []string       `json:"search_domains"`
+	NTP           []string       `json:"ntp"`
+	DHCPv4Lease   *DHCPLease     `json:"dhcpv4_lease,omitempty"`
+	LLDPNeighbors []LLDPNeighbor `json:"lldp_neighbors"`
+	// Leases handed out by networkd's [DHCPServer] on this link
+	DHCPServerLeases []DHCPServerLease `json:"dhcp_server_leases,omitempty"`
+}
+
+// jsonAddress decodes an address that networkd reports either as an array of
+// bytes (older versions) or as a string.
+type jsonAddress string
+
+func (a *jsonAddress) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*a = jsonAddress(s)
+		return nil
+	}
+	var raw []byte
+	var nums []int
+	if err := json.Unmarshal(data, &nums); err != nil {
+		return nil // Unknown shape, leave empty
+	}
+	for _, n := range nums {
+		raw = append(raw, byte(n))
+	}
+	if len(raw) == net.IPv4len || len(raw) == net.IPv6len {
+		*a = jsonAddress(net.IP(raw).String())
+	}
+	return nil
+}
+
+// linkDescription mirrors the JSON produced by org.freedesktop.network1.Link.Describe
+// and `networkctl --json=short status <link>`.
+type linkDescription struct {
+	Index                    int      `json:"Index"`
+	Name                     string   `json:"Name"`
+	AlternativeNames         []string `json:"AlternativeNames"`
+	Type                     string   `json:"Type"`
+	Kind                     string   `json:"Kind"`
+	Driver                   string   `json:"Driver"`
+	Path                     string   `json:"Path"`
+	Vendor                   string   `json:"Vendor"`
+	Model                    string   `json:"Model"`
+	HardwareAddress          string   `json:"HardwareAddress"`
+	PermanentHardwareAddress string   `json:"PermanentHardwareAddress"`
+	MTU                      int      `json:"MTU"`
+	AdministrativeState      string   `json:"AdministrativeState"`
+	SetupState               string   `json:"SetupState"`
+	OperationalState         string   `json:"OperationalState"`
+	CarrierState             string   `json:"CarrierState"`
+	AddressState             string   `json:"AddressState"`
+	IPv4AddressState         string   `json:"IPv4AddressState"`
+	IPv6AddressState         string   `json:"IPv6AddressState"`
+	OnlineState              string   `json:"OnlineState"`
+	RequiredForOnline        bool     `json:"RequiredForOnline"`
+	NetworkFile              string   `json:"NetworkFile"`
+	NetworkFileDropins       []string `json:"NetworkFileDropins"`
+	LinkFile                 string   `json:"LinkFile"`
+
+	Addresses []struct {
+		Address           jsonAddress `json:"Address"`
+		PrefixLength      int         `json:"PrefixLength"`
+		ConfigSource      string      `json:"ConfigSource"`
+		ConfigState       string      `json:"ConfigState"`
+		ConfigProvider    jsonAddress `json:"ConfigProvider"`
+		ValidLifetimeUSec uint64      `json:"ValidLifetimeUSec"`
+	} `json:"Addresses"`
+	Routes []struct {
+		DestinationPrefixLength int         `json:"DestinationPrefixLength"`
+		Gateway                 jsonAddress `json:"Gateway"`
+	} `json:"Routes"`
+	DNS []struct {
+		Address jsonAddress `json:"Address"`
+	} `json:"DNS"`
+	SearchDomains []struct {
+		Domain string `json:"Domain"`
+	} `json:"SearchDomains"`
+	NTP []struct {
+		Address jsonAddress `json:"Address"`
+		Server  string      `json:"Server"`
+	} `json:"NTP"`
+	DHCPv4Client *struct {
+		Lease *struct {
+			LeaseTimestampUSec uint64 `json:"LeaseTimestampUSec"`
+		} `json:"Lease"`
+	} `json:"DHCPv4Client"`
+	DHCPServer *struct {
+		Leases       []dhcpServerLeaseJSON `json:"Leases"`
+		StaticLeases []dhcpServerLeaseJSON `json:"StaticLeases"`
+	} `json:"DHCPServer"`
+	LLDP []lldpNeighborJSON `json:"LLDP"`
+}
+
+// usecToTime converts a realtime µs timestamp; 0 and USEC_INFINITY mean unset.
+func usecToTime(usec uint64) *time.Time {
+	if usec == 0 || usec == math.MaxUint64 {
+		return nil
+	}
+	t := time.UnixMicro(int64(usec)).UTC()
+	return &t
+}
+
+// boottimeToTime converts a CLOCK_BOOTTIME µs timestamp, which networkd uses
+// for address lifetimes and DHCP lease times, to wall-clock time. Returns nil
+// when unset, infinite, or when the host's boot time is unknown.
+func boottimeToTime(usec uint64, boot time.Time) *time.Time {
+	if boot.IsZero() || usec == 0 || usec == math.MaxUint64 {
+		return nil
+	}
+	t := boot.Add(time.Duration(usec) * time.Microsecond).UTC()
+	return &t
+}
+
+// bootTimeFromUptime derives when a host booted from its /proc/uptime, read
+// at now. /proc/uptime counts on CLOCK_BOOTTIME, so suspend is included.
+func bootTimeFromUptime(uptime string, now time.Time) time.Time {
+	var secs float64
+	if _, err := fmt.Sscanf(uptime, "%f", &secs); err != nil || secs <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-time.Duration(secs * float64(time.Second)))
+}
+
+// parseLinkDescription converts networkd's link JSON into LinkDetails. boot
+// is when the host booted, for boot-time timestamps; zero leaves them unset.
+func parseLinkDescription(data []byte, boot time.Time) (*LinkDetails, error) {
+	var d linkDescription
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("failed to parse link description: %w", err)
+	}
+
+	details := &LinkDetails{
+		Index:                    d.Index,
+		Name:                     d.Name,
+		AlternativeNames:         d.AlternativeNames,
+		Type:                     d.Type,
+		Kind:                     d.Kind,
+		Driver:                   d.Driver,
+		Path:                     d.Path,
+		Vendor:                   d.Vendor,
+		Model:                    d.Model,
+		HardwareAddress:          d.HardwareAddress,
+		PermanentHardwareAddress: d.PermanentHardwareAddress,
+		MTU:                      d.MTU,
+		SetupState:               d.AdministrativeState,
+		OperationalState:         d.OperationalState,
+		CarrierState:             d.CarrierState,
+		AddressState:             d.AddressState,
+		IPv4AddressState:         d.IPv4AddressState,
+		IPv6AddressState:         d.IPv6AddressState,
+		OnlineState:              d.OnlineState,
+		RequiredForOnline:        d.RequiredForOnline,
+		NetworkFile:              d.NetworkFile,
+		NetworkFileDropins:       d.NetworkFileDropins,
+		LinkFile:                 d.LinkFile,
+		Addresses:                []LinkAddress{},
+		Gateways:                 []string{},
+		DNS:                      []string{},
+		SearchDomains:            []string{},
+		NTP:                      []string{},
+		LLDPNeighbors:            []LLDPNeighbor{},
+	}
+	if d.SetupState != "" {
+		details.SetupState = d.SetupState
+	}
+
+	for _, a := range d.Addresses {
+		details.Addresses = append(details.Addresses, LinkAddress{
+			Address:      string(a.Address),
+			PrefixLength: a.PrefixLength,
+			ConfigSource: a.ConfigSource,
+			ConfigState:  a.ConfigState,
+		})
+		if strings.EqualFold(a.ConfigSource, "DHCPv4") && details.DHCPv4Lease == nil {
+			details.DHCPv4Lease = &DHCPLease{
+				Address:   fmt.Sprintf("%s/%d", a.Address, a.PrefixLength),
+				Server:    string(a.ConfigProvider),
+				ExpiresAt: boottimeToTime(a.ValidLifetimeUSec, boot),
+			}
+		}
+	}
+	if d.DHCPv4Client != nil && d.DHCPv4Client.Lease != nil {
+		if details.DHCPv4Lease == nil {
+			details.DHCPv4Lease = &DHCPLease{}
+		}
+		details.DHCPv4Lease.ObtainedAt = boottimeToTime(d.DHCPv4Client.Lease.LeaseTimestampUSec, boot)
+	}
+	for _, r := range d.Routes {
+		if r.DestinationPrefixLength == 0 && r.Gateway != "" && r.Gateway != "0.0.0.0" && r.Gateway != "::" {
+			details.Gateways = append(details.Gateways, string(r.Gateway))
+		}
+	}
+	for _, dns := range d.DNS {
+		if dns.Address != "" {
+			details.DNS = append(details.DNS, string(dns.Address))
+		}
+	}
+	for _, sd := range d.SearchDomains {
+		details.SearchDomains = append(details.SearchDomains, sd.Domain)
+	}
+	for _, ntp := range d.NTP {
+		if ntp.Server != "" {
+			details.NTP = append(details.NTP, ntp.Server)
+		} else if ntp.Address != "" {
+			details.NTP = append(details.NTP, string(ntp.Address))
+		}
+	}
+	if d.DHCPServer != nil {
+		for _, l := range d.DHCPServer.Leases {
+			details.DHCPServerLeases = append(details.DHCPServerLeases, l.toLease(d.Name, false, boot))
+		}
+		for _, l := range d.DHCPServer.StaticLeases {
+			details.DHCPServerLeases = append(details.DHCPServerLeases, l.toLease(d.Name, true, boot))
+		}
+	}
+	for _, n := range d.LLDP {
+		details.LLDPNeighbors = append(details.LLDPNeighbors, n.toNeighbor(d.Name))
+	}
+	return details, nil
+}
+
+// applySysfsSpeed fills speed and duplex from /sys/class/net/<link>/{speed,duplex}
+// contents; networkd does not report them. Virtual links report -1 or fail.
+func applySysfsSpeed(details *LinkDetails, speed, duplex string) {
+	if v, err := strconv.Atoi(strings.TrimSpace(speed)); err == nil && v > 0 {
+		details.SpeedMbps = v
+	}
+	if d := strings.TrimSpace(duplex); d != "" && d != "unknown" {
+		details.Duplex = d
+	}
+}
+
+// ValidateInterfaceName checks a kernel interface name: at most 15 bytes,
+// no '/', whitespace or ':' and not "." or "..".
+func ValidateInterfaceName(name string) error {
+	if name == "" || len(name) > 15 || name == "." || name == ".." || strings.ContainsAny(name, "/: \t\n") {
+		return fmt.Errorf("invalid interface name: %q", name)
+	}
+	return nil
+}
+
+func (s *NetworkdService) GetLinkDetails(host, name string) (*LinkDetails, error) {
+	if err := ValidateInterfaceName(name); err != nil {
+		return nil, err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetLinkDetails(name)
+}
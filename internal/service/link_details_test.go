@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLinkDescription(t *testing.T) {
+	data := []byte(`{
+		"Index": 2, "Name": "eth0", "Type": "ether", "MTU": 1500,
+		"AdministrativeState": "configured", "OperationalState": "routable",
+		"CarrierState": "carrier", "OnlineState": "online",
+		"NetworkFile": "/etc/systemd/network/10-eth0.network",
+		"LinkFile": "/usr/lib/systemd/network/99-default.link",
+		"Addresses": [
+			{"Family": 2, "Address": [192,168,1,5], "PrefixLength": 24, "ConfigSource": "DHCPv4", "ConfigProvider": [192,168,1,1], "ValidLifetimeUSec": 90061000000}
+		],
+		"DHCPv4Client": {"Lease": {"LeaseTimestampUSec": 3661000000}},
+		"Routes": [
+			{"Family": 2, "Destination": [0,0,0,0], "DestinationPrefixLength": 0, "Gateway": [192,168,1,1]},
+			{"Family": 2, "Destination": [192,168,1,0], "DestinationPrefixLength": 24, "Gateway": [0,0,0,0]}
+		],
+		"DNS": [{"Family": 2, "Address": "1.1.1.1"}],
+		"SearchDomains": [{"Domain": "example.net"}],
+		"LLDP": [{"ChassisID": "00:11:22:33:44:55", "PortID": "ge-0/0/1", "SystemName": "sw1"}]
+	}`)
+
+	// Lifetimes and lease times count from boot (CLOCK_BOOTTIME)
+	boot := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	d, err := parseLinkDescription(data, boot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.SetupState != "configured" || d.OnlineState != "online" || d.MTU != 1500 {
+		t.Errorf("Unexpected states: %+v", d)
+	}
+	if len(d.Addresses) != 1 || d.Addresses[0].Address != "192.168.1.5" {
+		t.Errorf("Unexpected addresses: %+v", d.Addresses)
+	}
+	if len(d.Gateways) != 1 || d.Gateways[0] != "192.168.1.1" {
+		t.Errorf("Unexpected gateways: %v", d.Gateways)
+	}
+	if d.DHCPv4Lease == nil || d.DHCPv4Lease.Server != "192.168.1.1" || d.DHCPv4Lease.Address != "192.168.1.5/24" {
+		t.Fatalf("Unexpected lease: %+v", d.DHCPv4Lease)
+	}
+	if d.DHCPv4Lease.ObtainedAt == nil || !d.DHCPv4Lease.ObtainedAt.Equal(time.Date(2026, 10, 18, 1, 1, 1, 0, time.UTC)) {
+		t.Errorf("Unexpected lease start: %v", d.DHCPv4Lease.ObtainedAt)
+	}
+	if d.DHCPv4Lease.ExpiresAt == nil || !d.DHCPv4Lease.ExpiresAt.Equal(time.Date(2026, 10, 19, 1, 1, 1, 0, time.UTC)) {
+		t.Errorf("Unexpected lease expiry: %v", d.DHCPv4Lease.ExpiresAt)
+	}
+	if d, _ := parseLinkDescription(data, time.Time{}); d.DHCPv4Lease.ExpiresAt != nil {
+		t.Errorf("Expected no expiry without a boot time, got %v", d.DHCPv4Lease.ExpiresAt)
+	}
+	if len(d.DNS) != 1 || d.DNS[0] != "1.1.1.1" || len(d.SearchDomains) != 1 {
+		t.Errorf("Unexpected DNS: %v %v", d.DNS, d.SearchDomains)
+	}
+	if len(d.LLDPNeighbors) != 1 || d.LLDPNeighbors[0].SystemName != "sw1" {
+		t.Errorf("Unexpected LLDP neighbours: %+v", d.LLDPNeighbors)
+	}
+}
+
+func TestParseDHCPServerLeases(t *testing.T) {
+	data := []byte(`{
+		"Index": 3, "Name": "mgmt0",
+		"DHCPServer": {
+			"Leases": [
+				{"ClientId": [1,82,84,0,18,52,86], "Address": [10,0,10,50], "Hostname": "pdu1", "ExpirationUSec": 7200000000},
+				{"ClientId": [1,82,84,0,18,52,88], "Address": [10,0,10,51], "ExpirationUSec": 7200000000, "ExpirationRealtimeUSec": 1792292400000000}
+			],
+			"StaticLeases": [{"ClientId": [1,82,84,0,18,52,87], "Address": [10,0,10,5]}]
+		}
+	}`)
+
+	d, err := parseLinkDescription(data, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.DHCPServerLeases) != 3 {
+		t.Fatalf("Expected 3 leases, got %+v", d.DHCPServerLeases)
+	}
+	// ExpirationUSec counts from boot: two hours after booting
+	dyn := d.DHCPServerLeases[0]
+	if dyn.Interface != "mgmt0" || dyn.Address != "10.0.10.50" || dyn.MACAddress != "52:54:00:12:34:56" || dyn.Hostname != "pdu1" || dyn.Static {
+		t.Errorf("Unexpected dynamic lease: %+v", dyn)
+	}
+	if dyn.ExpiresAt == nil || !dyn.ExpiresAt.Equal(time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected expiry: %v", dyn.ExpiresAt)
+	}
+	// The realtime expiration wins where networkd reports it
+	if rt := d.DHCPServerLeases[1]; rt.ExpiresAt == nil || !rt.ExpiresAt.Equal(time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected realtime expiry: %v", rt.ExpiresAt)
+	}
+	if st := d.DHCPServerLeases[2]; !st.Static || st.ExpiresAt != nil || st.Address != "10.0.10.5" {
+		t.Errorf("Unexpected static lease: %+v", st)
+	}
+}
+
+func TestBootTimeFromUptime(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	if boot := bootTimeFromUptime("3600.50 7000.10\n", now); !boot.Equal(now.Add(-3600500 * time.Millisecond)) {
+		t.Errorf("Unexpected boot time %v", boot)
+	}
+	if boot := bootTimeFromUptime("", now); !boot.IsZero() {
+		t.Errorf("Expected zero boot time, got %v", boot)
+	}
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RenamePrediction is the outcome of applying the .link files to one device.
+type RenamePrediction struct {
+	Interface       string `json:"interface"`
+	PredictedName   string `json:"predicted_name"`
+	Changed         bool   `json:"changed"`
+	LinkFile        string `json:"link_file,omitempty"`         // Winning .link file, empty if none of ours match
+	CurrentLinkFile string `json:"current_link_file,omitempty"` // .link file udev applied at boot
+	Source          string `json:"source,omitempty"`            // NamePolicy entry or "Name" that produced the name
+	// MatchedProposal is true when the proposed file wins for this device
+	MatchedProposal bool     `json:"matched_proposal"`
+	Warnings        []string `json:"warnings,omitempty"`
+}
+
+// kernelName matches names assigned by the kernel (eth0, wlan1, ...), which
+// NamePolicy=keep does not preserve.
+var kernelName = regexp.MustCompile(`^(eth|wlan|usb|wwan|ib)\d+$`)
+
+// previewDevice is what the matcher knows about one interface.
+type previewDevice struct {
+	link Link
+	udev *UdevInfo
+}
+
+type previewLinkFile struct {
+	filename string
+	config   map[string]interface{}
+}
+
+// matchValues flattens a [Match] value into its whitespace separated patterns.
+func matchValues(v interface{}) []string {
+	var out []string
+	switch val := v.(type) {
+	case string:
+		out = append(out, strings.Fields(val)...)
+	case []interface{}:
+		for _, item := range val {
+			out = append(out, matchValues(item)...)
+		}
+	}
+	return out
+}
+
+// globMatch implements udev's pattern lists: any pattern matches, and a
+// leading "!" on the first pattern inverts the whole list.
+func globMatch(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	invert := strings.HasPrefix(patterns[0], "!")
+	if invert {
+		patterns = append([]string{strings.TrimPrefix(patterns[0], "!")}, patterns[1:]...)
+	}
+	matched := false
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, value); ok || strings.EqualFold(p, value) {
+			matched = true
+			break
+		}
+	}
+	return matched != invert
+}
+
+// linkFileMatches evaluates the [Match] section of a .link config against a
+// device. Conditions the preview cannot evaluate are reported as warnings and
+// treated as satisfied.
+func linkFileMatches(cfg map[string]interface{}, dev previewDevice) (bool, []string) {
+	match, _ := cfg["Match"].(map[string]interface{})
+	var warnings []string
+	for key, v := range match {
+		patterns := matchValues(v)
+		var ok bool
+		switch key {
+		case "MACAddress", "PermanentMACAddress":
+			// MAC lists are plain addresses, not globs
+			ok = len(patterns) == 0
+			for _, p := range patterns {
+				if strings.EqualFold(p, dev.link.HardwareAddress) {
+					ok = true
+				}
+			}
+		case "OriginalName":
+			ok = globMatch(patterns, dev.link.Name)
+			if !kernelName.MatchString(dev.link.Name) {
+				warnings = append(warnings, "OriginalName= compared against the current name")
+			}
+		case "Path":
+			ok = globMatch(patterns, dev.link.Path)
+		case "Driver":
+			ok = globMatch(patterns, dev.link.Driver)
+		case "Type":
+			ok = globMatch(patterns, dev.link.Type)
+		case "Property":
+			ok = true
+			for _, p := range patterns {
+				k, want, _ := strings.Cut(p, "=")
+				if !globMatch([]string{want}, dev.udev.Properties[k]) {
+					ok = false
+				}
+			}
+		default:
+			warnings = append(warnings, fmt.Sprintf("Match %s= is not evaluated by the preview", key))
+			ok = true
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, warnings
+}
+
+// predictName applies the [Link] naming settings of a .link config the way
+// udev's net_setup_link does on device add.
+func predictName(cfg map[string]interface{}, dev previewDevice) (string, string) {
+	link, _ := cfg["Link"].(map[string]interface{})
+	for _, policy := range matchValues(link["NamePolicy"]) {
+		switch policy {
+		case "keep":
+			if !kernelName.MatchString(dev.link.Name) {
+				return dev.link.Name, "keep"
+			}
+		case "database":
+			if name := dev.udev.Properties["ID_NET_NAME_FROM_DATABASE"]; name != "" {
+				return name, policy
+			}
+		case "onboard", "slot", "path", "mac":
+			if name := dev.udev.Names[policy]; name != "" {
+				return name, policy
+			}
+		}
+	}
+	if name := matchValues(link["Name"]); len(name) > 0 {
+		return name[0], "Name"
+	}
+	return dev.link.Name, ""
+}
+
+// PreviewLinkRename predicts interface names after a reboot if the proposed
+// .link config were written as filename. The proposal is evaluated together
+// with the other .link files in the config directory; udev uses the first
+// matching file in lexical order. Devices no managed file matches keep the
+// name given by the file currently applied to them.
+func (s *NetworkdService) PreviewLinkRename(host, filename string, proposed map[string]interface{}) ([]RenamePrediction, error) {
+	if err := validateFilename(filename); err != nil {
+		return nil, err
+	}
+	if t, _ := ConfigTypeForFilename(filename); t != "link" {
+		return nil, fmt.Errorf("%s is not a .link file", filename)
+	}
+	if err := s.Schema.Validate("link", proposed); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	existing, err := s.ListLinkConfigs(host, nil)
+	if err != nil {
+		return nil, err
+	}
+	files := []previewLinkFile{{filename: filename, config: proposed}}
+	for _, f := range existing {
+		if f.Filename == filename {
+			continue // Replaced by the proposal
+		}
+		content, err := s.ReadNetworkFile(host, f.Filename)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := INIToMap(content, s.Schema, "link")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.Filename, err)
+		}
+		files = append(files, previewLinkFile{filename: f.Filename, config: cfg})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].filename < files[j].filename })
+
+	links, err := s.ListLinks(host)
+	if err != nil {
+		return nil, err
+	}
+	devices := []previewDevice{}
+	for _, l := range links {
+		if l.Name == "lo" {
+			continue
+		}
+		info, err := s.GetUdevInfo(host, l.Name)
+		if err != nil {
+			// Devices without udev data are still matched on the networkd view
+			info = &UdevInfo{Interface: l.Name, Names: map[string]string{}, Properties: map[string]string{}}
+		}
+		devices = append(devices, previewDevice{link: l, udev: info})
+	}
+
+	return predictRenames(files, devices, filename), nil
+}
+
+func predictRenames(files []previewLinkFile, devices []previewDevice, proposal string) []RenamePrediction {
+	predictions := []RenamePrediction{}
+	byName := make(map[string][]int)
+	for _, dev := range devices {
+		p := RenamePrediction{
+			Interface:       dev.link.Name,
+			PredictedName:   dev.link.Name,
+			CurrentLinkFile: dev.udev.LinkFile,
+		}
+		for _, f := range files {
+			ok, warnings := linkFileMatches(f.config, dev)
+			if !ok {
+				continue
+			}
+			p.LinkFile = f.filename
+			p.MatchedProposal = f.filename == proposal
+			p.Warnings = append(p.Warnings, warnings...)
+			p.PredictedName, p.Source = predictName(f.config, dev)
+			break
+		}
+		p.Changed = p.PredictedName != dev.link.Name
+		byName[p.PredictedName] = append(byName[p.PredictedName], len(predictions))
+		predictions = append(predictions, p)
+	}
+
+	for name, idx := range byName {
+		if len(idx) < 2 {
+			continue
+		}
+		for _, i := range idx {
+			predictions[i].Warnings = append(predictions[i].Warnings,
+				fmt.Sprintf("%d devices would be named %s; only one rename can succeed", len(idx), name))
+		}
+	}
+	return predictions
+}
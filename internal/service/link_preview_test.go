@@ -0,0 +1,44 @@
+package service
+
+import "testing"
+
+func TestPredictRenames(t *testing.T) {
+	devices := []previewDevice{
+		{
+			link: Link{Name: "eth0", Type: "ether", Driver: "e1000e", HardwareAddress: "52:54:00:12:34:56", Path: "pci-0000:00:1f.6"},
+			udev: &UdevInfo{Names: map[string]string{"onboard": "eno1", "path": "enp0s31f6"}, Properties: map[string]string{}},
+		},
+		{
+			link: Link{Name: "eth1", Type: "ether", Driver: "ixgbe", HardwareAddress: "52:54:00:ab:cd:ef"},
+			udev: &UdevInfo{Names: map[string]string{"path": "enp3s0f0"}, Properties: map[string]string{}},
+		},
+	}
+	files := []previewLinkFile{
+		{filename: "10-uplink.link", config: map[string]interface{}{
+			"Match": map[string]interface{}{"MACAddress": "52:54:00:AB:CD:EF"},
+			"Link":  map[string]interface{}{"Name": "uplink0"},
+		}},
+		{filename: "99-proposal.link", config: map[string]interface{}{
+			"Match": map[string]interface{}{"Type": "ether"},
+			"Link":  map[string]interface{}{"NamePolicy": "onboard path"},
+		}},
+	}
+
+	predictions := predictRenames(files, devices, "99-proposal.link")
+	if len(predictions) != 2 {
+		t.Fatalf("Expected 2 predictions, got %+v", predictions)
+	}
+	if p := predictions[0]; p.PredictedName != "eno1" || !p.Changed || p.Source != "onboard" || !p.MatchedProposal {
+		t.Errorf("Unexpected eth0 prediction: %+v", p)
+	}
+	if p := predictions[1]; p.PredictedName != "uplink0" || p.LinkFile != "10-uplink.link" || p.MatchedProposal {
+		t.Errorf("Unexpected eth1 prediction: %+v", p)
+	}
+
+	// Two devices forced to the same name
+	files[0].config["Match"] = map[string]interface{}{"Driver": "!nomatch"}
+	predictions = predictRenames(files, devices, "99-proposal.link")
+	if len(predictions[0].Warnings) == 0 || len(predictions[1].Warnings) == 0 {
+		t.Errorf("Expected name collision warnings, got %+v", predictions)
+	}
+}
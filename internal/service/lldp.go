@@ -0,0 +1,270 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// lldpCapabilityNames are the IEEE 802.1AB system capability bits.
+var lldpCapabilityNames = []string{
+	"other", "repeater", "bridge", "wlan-access-point", "router",
+	"telephone", "docsis", "station", "customer-vlan", "service-vlan", "two-port-mac-relay",
+}
+
+// lldpCapabilities decodes capabilities reported either as the raw bitmask
+// (networkd) or as a list of names.
+type lldpCapabilities []string
+
+func (c *lldpCapabilities) UnmarshalJSON(data []byte) error {
+	var names []string
+	if json.Unmarshal(data, &names) == nil {
+		*c = names
+		return nil
+	}
+	var mask uint16
+	if err := json.Unmarshal(data, &mask); err != nil {
+		return nil // Unknown shape, leave empty
+	}
+	names = []string{}
+	for bit, name := range lldpCapabilityNames {
+		if mask&(1<<bit) != 0 {
+			names = append(names, name)
+		}
+	}
+	*c = names
+	return nil
+}
+
+// lldpNeighborJSON is one neighbour as emitted by networkd.
+type lldpNeighborJSON struct {
+	ChassisID           string           `json:"ChassisID"`
+	PortID              string           `json:"PortID"`
+	PortDescription     string           `json:"PortDescription"`
+	SystemName          string           `json:"SystemName"`
+	SystemDescription   string           `json:"SystemDescription"`
+	EnabledCapabilities lldpCapabilities `json:"EnabledCapabilities"`
+}
+
+func (n lldpNeighborJSON) toNeighbor(iface string) LLDPNeighbor {
+	return LLDPNeighbor{
+		Interface:         iface,
+		ChassisID:         n.ChassisID,
+		PortID:            n.PortID,
+		PortDescription:   n.PortDescription,
+		SystemName:        n.SystemName,
+		SystemDescription: n.SystemDescription,
+		Capabilities:      n.EnabledCapabilities,
+	}
+}
+
+// parseLLDPNeighbors parses `networkctl lldp --json=short`.
+func parseLLDPNeighbors(out []byte) ([]LLDPNeighbor, error) {
+	var doc struct {
+		Neighbors []struct {
+			InterfaceName string             `json:"InterfaceName"`
+			Neighbors     []lldpNeighborJSON `json:"Neighbors"`
+		} `json:"Neighbors"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse networkctl lldp output: %w", err)
+	}
+	neighbors := []LLDPNeighbor{}
+	for _, iface := range doc.Neighbors {
+		for _, n := range iface.Neighbors {
+			neighbors = append(neighbors, n.toNeighbor(iface.InterfaceName))
+		}
+	}
+	return neighbors, nil
+}
+
+func (s *NetworkdService) GetLLDPNeighbors(host string) ([]LLDPNeighbor, error) {
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetLLDPNeighbors()
+}
+
+// TopologyNode is a managed host or a device only known through LLDP.
+type TopologyNode struct {
+	ID        string `json:"id"`
+	Label     string `json:"label"`
+	Managed   bool   `json:"managed"`
+	ChassisID string `json:"chassis_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// TopologyLink is a physical connection between two ports.
+type TopologyLink struct {
+	Source     string `json:"source"`
+	SourcePort string `json:"source_port"`
+	Target     string `json:"target"`
+	TargetPort string `json:"target_port"`
+	// Confirmed is true when both ends report each other via LLDP
+	Confirmed bool `json:"confirmed"`
+}
+
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Links []TopologyLink `json:"links"`
+}
+
+type hostLLDP struct {
+	host      string
+	hostname  string
+	neighbors []LLDPNeighbor
+	err       error
+}
+
+// GetTopology queries LLDP neighbours on every managed host and stitches them
+// into one graph. Neighbours whose system name matches a managed host's
+// hostname (or registered name/address) are linked to that host; everything
+// else becomes an unmanaged node identified by chassis ID. Hosts are visited
+// in managedHosts order, so links and their direction are stable between
+// calls and exports can be diffed.
+func (s *NetworkdService) GetTopology() (*Topology, error) {
+	hosts := s.managedHosts()
+
+	results := make([]hostLLDP, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			res := hostLLDP{host: host}
+			c, err := s.GetConnector(host)
+			if err == nil {
+				res.hostname, _ = c.GetHostname()
+				res.neighbors, err = c.GetLLDPNeighbors()
+			}
+			res.err = err
+			results[i] = res
+		}(i, host)
+	}
+	wg.Wait()
+
+	// Names under which a managed host may show up as an LLDP system name
+	aliases := make(map[string]string)
+	for _, r := range results {
+		aliases[strings.ToLower(r.host)] = r.host
+		if r.hostname != "" {
+			aliases[strings.ToLower(r.hostname)] = r.host
+			// Short name, for neighbours that advertise an FQDN or vice versa
+			short, _, _ := strings.Cut(r.hostname, ".")
+			aliases[strings.ToLower(short)] = r.host
+		}
+		if s.HostManager != nil {
+			if cfg, ok := s.HostManager.GetHost(r.host); ok {
+				aliases[strings.ToLower(cfg.Host)] = r.host
+			}
+		}
+	}
+	resolve := func(n LLDPNeighbor) (string, bool) {
+		name := strings.ToLower(n.SystemName)
+		if id, ok := aliases[name]; ok {
+			return id, true
+		}
+		short, _, _ := strings.Cut(name, ".")
+		if id, ok := aliases[short]; ok && short != "" {
+			return id, true
+		}
+		id := n.ChassisID
+		if id == "" {
+			id = n.SystemName
+		}
+		return "chassis:" + id, false
+	}
+
+	topo := &Topology{Nodes: []TopologyNode{}, Links: []TopologyLink{}}
+	nodes := make(map[string]*TopologyNode)
+	for _, r := range results {
+		label := r.host
+		if r.hostname != "" && r.hostname != r.host {
+			label = fmt.Sprintf("%s (%s)", r.host, r.hostname)
+		}
+		node := &TopologyNode{ID: r.host, Label: label, Managed: true}
+		if r.err != nil {
+			node.Error = r.err.Error()
+		}
+		nodes[r.host] = node
+	}
+
+	linkIndex := make(map[string]int)
+	for _, r := range results {
+		for _, n := range r.neighbors {
+			target, managed := resolve(n)
+			if target == r.host {
+				continue
+			}
+			if !managed {
+				if _, ok := nodes[target]; !ok {
+					label := n.SystemName
+					if label == "" {
+						label = n.ChassisID
+					}
+					nodes[target] = &TopologyNode{ID: target, Label: label, ChassisID: n.ChassisID}
+				}
+			}
+			port := n.PortID
+
+			// The reverse direction may already be recorded by the other host
+			reverse := fmt.Sprintf("%s|%s|%s|%s", target, port, r.host, n.Interface)
+			if i, ok := linkIndex[reverse]; ok {
+				topo.Links[i].Confirmed = true
+				continue
+			}
+			key := fmt.Sprintf("%s|%s|%s|%s", r.host, n.Interface, target, port)
+			if _, ok := linkIndex[key]; ok {
+				continue
+			}
+			linkIndex[key] = len(topo.Links)
+			topo.Links = append(topo.Links, TopologyLink{
+				Source:     r.host,
+				SourcePort: n.Interface,
+				Target:     target,
+				TargetPort: port,
+			})
+		}
+	}
+
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		topo.Nodes = append(topo.Nodes, *nodes[id])
+	}
+	return topo, nil
+}
+
+// dotQuote renders a Graphviz string literal.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// DOT renders the topology as a Graphviz graph.
+func (t *Topology) DOT() string {
+	var b strings.Builder
+	b.WriteString("graph topology {\n")
+	for _, n := range t.Nodes {
+		shape := "box"
+		if !n.Managed {
+			shape = "ellipse"
+		}
+		fmt.Fprintf(&b, "  %s [label=%s, shape=%s];\n", dotQuote(n.ID), dotQuote(n.Label), shape)
+	}
+	for _, l := range t.Links {
+		style := "dashed"
+		if l.Confirmed {
+			style = "solid"
+		}
+		fmt.Fprintf(&b, "  %s -- %s [taillabel=%s, headlabel=%s, style=%s];\n",
+			dotQuote(l.Source), dotQuote(l.Target), dotQuote(l.SourcePort), dotQuote(l.TargetPort), style)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLLDPNeighbors(t *testing.T) {
+	data := []byte(`{"Neighbors": [
+		{"InterfaceName": "eth0", "Neighbors": [
+			{"ChassisID": "00:11:22:33:44:55", "PortID": "ge-0/0/1", "SystemName": "sw1", "EnabledCapabilities": 20}
+		]},
+		{"InterfaceName": "eth1", "Neighbors": [
+			{"ChassisID": "aa:bb:cc:dd:ee:ff", "PortID": "eth0", "SystemName": "node2", "EnabledCapabilities": ["station"]}
+		]}
+	]}`)
+
+	neighbors, err := parseLLDPNeighbors(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(neighbors) != 2 {
+		t.Fatalf("Expected 2 neighbours, got %+v", neighbors)
+	}
+	sw := neighbors[0]
+	if sw.Interface != "eth0" || sw.SystemName != "sw1" || strings.Join(sw.Capabilities, ",") != "bridge,router" {
+		t.Errorf("Unexpected neighbour: %+v", sw)
+	}
+	if n := neighbors[1]; n.Interface != "eth1" || len(n.Capabilities) != 1 || n.Capabilities[0] != "station" {
+		t.Errorf("Unexpected neighbour: %+v", n)
+	}
+}
+
+func TestTopologyDOT(t *testing.T) {
+	topo := &Topology{
+		Nodes: []TopologyNode{
+			{ID: "local", Label: "local", Managed: true},
+			{ID: "chassis:00:11", Label: `sw "1"`},
+		},
+		Links: []TopologyLink{{Source: "local", SourcePort: "eth0", Target: "chassis:00:11", TargetPort: "ge-0/0/1"}},
+	}
+	dot := topo.DOT()
+	for _, want := range []string{
+		`"local" [label="local", shape=box];`,
+		`"chassis:00:11" [label="sw \"1\"", shape=ellipse];`,
+		`"local" -- "chassis:00:11" [taillabel="eth0", headlabel="ge-0/0/1", style=dashed];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+}
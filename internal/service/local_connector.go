@@ -1,28 +1,42 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 )
 
+// geteuid is replaced in tests to exercise writes as an unprivileged user.
+var geteuid = os.Geteuid
+
 type LocalConnector struct {
-	ConfigDir string
-	Conn      *dbus.Conn
+	ConfigDir        string
+	GlobalConfigPath string // networkd.conf; drop-ins live in <path>.d
+	Conn             *dbus.Conn
 }
 
-func NewLocalConnector(configDir string, conn *dbus.Conn) *LocalConnector {
+func NewLocalConnector(configDir, globalConfigPath string, conn *dbus.Conn) *LocalConnector {
 	return &LocalConnector{
-		ConfigDir: configDir,
-		Conn:      conn,
+		ConfigDir:        configDir,
+		GlobalConfigPath: globalConfigPath,
+		Conn:             conn,
 	}
 }
 
+func (c *LocalConnector) GetConfigDir() string {
+	return c.ConfigDir
+}
+
 func (c *LocalConnector) ListConfigDir(suffix string) ([]os.DirEntry, error) {
 	entries, err := os.ReadDir(c.ConfigDir)
 	if err != nil {
@@ -41,14 +55,103 @@ func (c *LocalConnector) ReadConfigFile(filename string) ([]byte, error) {
 	return os.ReadFile(filepath.Join(c.ConfigDir, filename))
 }
 
-func (c *LocalConnector) WriteConfigFile(filename string, content []byte) error {
-	return os.WriteFile(filepath.Join(c.ConfigDir, filename), content, 0644)
+func (c *LocalConnector) WriteConfigFile(filename string, content []byte, opts WriteOptions) error {
+	// Drop-ins live in a "<file>.d" directory that may not exist yet
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(filepath.Join(c.ConfigDir, dir), 0755); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(filepath.Join(c.ConfigDir, filename), content, opts)
+}
+
+// writeFileAtomic writes content to a hidden temporary file next to path,
+// fsyncs it, applies mode and ownership, and renames it into place so readers
+// never observe a partially written file. The directory is synced afterwards
+// so the rename survives a crash.
+func writeFileAtomic(path string, content []byte, opts WriteOptions) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(content); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(opts.mode()); err != nil {
+		return err
+	}
+	// Only root can give a file away. Unprivileged, as with a development
+	// config dir, files keep the service user's ownership.
+	if (opts.Owner != "" || opts.Group != "") && geteuid() == 0 {
+		uid, gid, lookupErr := lookupOwnership(opts.Owner, opts.Group)
+		if lookupErr != nil {
+			return lookupErr
+		}
+		if err = tmp.Chown(uid, gid); err != nil {
+			return fmt.Errorf("failed to set ownership: %w", err)
+		}
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	if d, dirErr := os.Open(dir); dirErr == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// lookupOwnership resolves user and group names to numeric IDs.
+// An empty name yields -1, which leaves that ID unchanged on chown.
+func lookupOwnership(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			return 0, 0, fmt.Errorf("unknown owner %q: %w", owner, err)
+		}
+		uid, _ = strconv.Atoi(u.Uid)
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return 0, 0, fmt.Errorf("unknown group %q: %w", group, err)
+		}
+		gid, _ = strconv.Atoi(g.Gid)
+	}
+	return uid, gid, nil
 }
 
 func (c *LocalConnector) DeleteConfigFile(filename string) error {
 	return os.Remove(filepath.Join(c.ConfigDir, filename))
 }
 
+func (c *LocalConnector) GenerateWireGuardKey(keyFile string, opts WriteOptions) (string, error) {
+	privateKey, publicKey, err := GenerateWireGuardKeyPair()
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(filepath.Join(c.ConfigDir, keyFile), []byte(privateKey+"\n"), opts); err != nil {
+		return "", err
+	}
+	return publicKey, nil
+}
+
 func (c *LocalConnector) Reconfigure(devices []string) error {
 	args := []string{"reconfigure"}
 	if len(devices) > 0 {
@@ -62,6 +165,36 @@ func (c *LocalConnector) Reconfigure(devices []string) error {
 	return nil
 }
 
+// linkDBusMethods are the link actions networkd exposes on its Link objects.
+// Up, down and delete are plain rtnetlink operations done by networkctl.
+var linkDBusMethods = map[string]string{
+	LinkActionRenew:       "org.freedesktop.network1.Link.Renew",
+	LinkActionForceRenew:  "org.freedesktop.network1.Link.ForceRenew",
+	LinkActionReconfigure: "org.freedesktop.network1.Link.Reconfigure",
+}
+
+func (c *LocalConnector) LinkAction(name, action string) (string, string, error) {
+	if method, ok := linkDBusMethods[action]; ok && c.Conn != nil {
+		var ifindex int32
+		var path dbus.ObjectPath
+		obj := c.Conn.Object("org.freedesktop.network1", "/org/freedesktop/network1")
+		if err := obj.Call("org.freedesktop.network1.Manager.GetLinkByName", 0, name).Store(&ifindex, &path); err != nil {
+			return method, "", fmt.Errorf("unknown link %s: %w", name, err)
+		}
+		if err := c.Conn.Object("org.freedesktop.network1", path).Call(method, 0).Err; err != nil {
+			return method, "", fmt.Errorf("%s failed: %w", method, err)
+		}
+		return method, "", nil
+	}
+
+	cmd := exec.Command("networkctl", action, name)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return cmd.String(), string(output), fmt.Errorf("networkctl %s failed: %w", action, err)
+	}
+	return cmd.String(), string(output), nil
+}
+
 // enrichLinksWithStatus calls networkctl --json=short status <name> for each link
 // to fill in Type, Driver, HardwareAddress, and Path.
 func enrichLinksWithStatus(links []Link) {
@@ -138,6 +271,148 @@ func (c *LocalConnector) GetLinks() ([]Link, error) {
 	return links, nil
 }
 
+// GetLinkDetails asks networkd over D-Bus (Link.Describe), falling back to
+// networkctl when the bus is unavailable.
+func (c *LocalConnector) GetLinkDetails(name string) (*LinkDetails, error) {
+	var description []byte
+	var path dbus.ObjectPath
+	if c.Conn != nil {
+		var ifindex int32
+		obj := c.Conn.Object("org.freedesktop.network1", "/org/freedesktop/network1")
+		if err := obj.Call("org.freedesktop.network1.Manager.GetLinkByName", 0, name).Store(&ifindex, &path); err != nil {
+			return nil, fmt.Errorf("unknown link %s: %w", name, err)
+		}
+		var js string
+		link := c.Conn.Object("org.freedesktop.network1", path)
+		if err := link.Call("org.freedesktop.network1.Link.Describe", 0).Store(&js); err != nil {
+			return nil, fmt.Errorf("failed to describe link %s: %w", name, err)
+		}
+		description = []byte(js)
+	} else {
+		out, err := exec.Command("networkctl", "--json=short", "status", name).Output()
+		if err != nil {
+			return nil, fmt.Errorf("networkctl status %s failed: %w", name, err)
+		}
+		description = out
+	}
+
+	uptime, _ := os.ReadFile("/proc/uptime")
+	details, err := parseLinkDescription(description, bootTimeFromUptime(string(uptime), time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	speed, _ := os.ReadFile(filepath.Join("/sys/class/net", name, "speed"))
+	duplex, _ := os.ReadFile(filepath.Join("/sys/class/net", name, "duplex"))
+	applySysfsSpeed(details, string(speed), string(duplex))
+
+	if c.Conn != nil && len(details.DHCPServerLeases) == 0 {
+		details.DHCPServerLeases = c.dhcpServerLeasesFromProperty(path, name)
+	}
+	return details, nil
+}
+
+// dhcpServerLeasesFromProperty reads the DHCPServer.Leases D-Bus property,
+// for networkd versions whose JSON description lacks DHCP server leases.
+// Each lease is (family, client id, address, gateway, chaddr, expiration)
+// with the expiration on CLOCK_BOOTTIME.
+func (c *LocalConnector) dhcpServerLeasesFromProperty(path dbus.ObjectPath, name string) []DHCPServerLease {
+	v, err := c.Conn.Object("org.freedesktop.network1", path).GetProperty("org.freedesktop.network1.DHCPServer.Leases")
+	if err != nil {
+		return nil
+	}
+	var raw []struct {
+		Family     uint32
+		ClientID   []byte
+		Address    []byte
+		Gateway    []byte
+		HWAddress  []byte
+		Expiration uint64
+	}
+	if err := dbus.Store([]interface{}{v.Value()}, &raw); err != nil {
+		return nil
+	}
+
+	// Translate boot-time expirations to wall-clock time via /proc/uptime
+	uptime, _ := os.ReadFile("/proc/uptime")
+	boot := bootTimeFromUptime(string(uptime), time.Now())
+
+	var leases []DHCPServerLease
+	for _, l := range raw {
+		lease := DHCPServerLease{
+			Interface:  name,
+			ClientID:   formatHex(l.ClientID),
+			MACAddress: macFromClientID(l.HWAddress, l.ClientID),
+		}
+		if len(l.Address) == net.IPv4len {
+			lease.Address = net.IP(l.Address).String()
+		}
+		lease.ExpiresAt = boottimeToTime(l.Expiration, boot)
+		leases = append(leases, lease)
+	}
+	return leases
+}
+
+func (c *LocalConnector) GetTunnels() ([]TunnelStatus, error) {
+	out, err := exec.Command("ip", "-d", "-j", "link", "show").Output()
+	if err != nil {
+		return nil, fmt.Errorf("ip link failed: %w", err)
+	}
+	tunnels, err := parseTunnelLinks(out)
+	if err != nil {
+		return nil, err
+	}
+	// wg is optional; without it WireGuard tunnels are listed without peers
+	if dump, err := exec.Command("wg", "show", "all", "dump").Output(); err == nil {
+		tunnels = mergeTunnelStatus(tunnels, parseWireGuardDump(string(dump), time.Now()))
+	}
+	return tunnels, nil
+}
+
+func (c *LocalConnector) GetInterfaceStats() ([]InterfaceStats, error) {
+	return readSysfsStats("/sys/class/net")
+}
+
+func (c *LocalConnector) GetHostname() (string, error) {
+	return os.Hostname()
+}
+
+func (c *LocalConnector) GetSessionAddrs() ([]string, error) {
+	return nil, nil
+}
+
+func (c *LocalConnector) GetLLDPNeighbors() ([]LLDPNeighbor, error) {
+	out, err := exec.Command("networkctl", "--json=short", "lldp").Output()
+	if err != nil {
+		return nil, fmt.Errorf("networkctl lldp failed: %w", err)
+	}
+	return parseLLDPNeighbors(out)
+}
+
+func (c *LocalConnector) GetResolvedStatus() (*ResolvedStatus, error) {
+	text, err := exec.Command("resolvectl", "--no-pager", "status").Output()
+	if err != nil {
+		return nil, fmt.Errorf("resolvectl status failed: %w", err)
+	}
+	jsonOut, _ := exec.Command("resolvectl", "--no-pager", "--json=short", "status").Output()
+	return newResolvedStatus(text, jsonOut), nil
+}
+
+func (c *LocalConnector) GetUdevInfo(iface string) (*UdevInfo, error) {
+	out, err := exec.Command("udevadm", "info", "--query=property", "--path=/sys/class/net/"+iface).Output()
+	if err != nil {
+		return nil, fmt.Errorf("udevadm info failed for %s: %w", iface, err)
+	}
+	return parseUdevProperties(iface, string(out)), nil
+}
+
+func (c *LocalConnector) ReadHostFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (c *LocalConnector) GlobHostFiles(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
 func (c *LocalConnector) GetSystemdVersion() string {
 	// Not easily retrievable via simple command without parsing?
 	// Used to be Schema.SystemdVersion.
@@ -145,11 +420,15 @@ func (c *LocalConnector) GetSystemdVersion() string {
 	return ""
 }
 
+func (c *LocalConnector) GetGlobalConfigPath() string {
+	return c.GlobalConfigPath
+}
+
 func (c *LocalConnector) GetGlobalConfig() (string, error) {
-	content, err := os.ReadFile("/etc/systemd/networkd.conf")
+	content, err := os.ReadFile(c.GlobalConfigPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return "# /etc/systemd/networkd.conf\n# No configuration file found.\n", nil
+			return fmt.Sprintf("# %s\n# No configuration file found.\n", c.GlobalConfigPath), nil
 		}
 		return "", err
 	}
@@ -157,7 +436,36 @@ func (c *LocalConnector) GetGlobalConfig() (string, error) {
 }
 
 func (c *LocalConnector) SaveGlobalConfig(content string) error {
-	return os.WriteFile("/etc/systemd/networkd.conf", []byte(content), 0644)
+	return writeFileAtomic(c.GlobalConfigPath, []byte(content), WriteOptions{})
+}
+
+func (c *LocalConnector) ListGlobalDropins() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(c.GlobalConfigPath+".d", "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(paths))
+	for _, p := range paths {
+		names = append(names, filepath.Base(p))
+	}
+	return names, nil
+}
+
+func (c *LocalConnector) ReadGlobalDropin(name string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(c.GlobalConfigPath+".d", name))
+	return string(content), err
+}
+
+func (c *LocalConnector) WriteGlobalDropin(name, content string) error {
+	dir := c.GlobalConfigPath + ".d"
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, name), []byte(content), WriteOptions{})
+}
+
+func (c *LocalConnector) DeleteGlobalDropin(name string) error {
+	return os.Remove(filepath.Join(c.GlobalConfigPath+".d", name))
 }
 
 func (c *LocalConnector) ReloadNetworkd() (string, error) {
@@ -178,8 +486,32 @@ func (c *LocalConnector) GetRules() (string, error) {
 	return string(out), err
 }
 
-func (c *LocalConnector) GetLogs() (string, error) {
-	cmd := exec.Command("journalctl", "-u", "systemd-networkd", "-n", "100", "--no-pager")
-	out, err := cmd.CombinedOutput()
-	return string(out), err
+func (c *LocalConnector) ReadJournal(args []string) ([]byte, error) {
+	out, err := exec.Command("journalctl", args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("journalctl failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("journalctl failed: %w", err)
+	}
+	return out, nil
+}
+
+func (c *LocalConnector) FollowJournal(ctx context.Context, args []string, onLine func([]byte) error) error {
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("journalctl failed: %w", err)
+	}
+	err = scanLines(stdout, onLine)
+	// Killing journalctl is how the stream ends on cancellation
+	cmd.Process.Kill()
+	cmd.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
 }
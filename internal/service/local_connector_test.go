@@ -0,0 +1,42 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomicUnprivileged(t *testing.T) {
+	defer func(orig func() int) { geteuid = orig }(geteuid)
+	path := filepath.Join(t.TempDir(), "30-wg0.WireGuard0.PrivateKey.key")
+	opts := WriteOptions{Mode: 0600, Owner: "no-such-user", Group: "no-such-group"}
+
+	// Without root the file is written with the service user's ownership
+	geteuid = func() int { return 1000 }
+	if err := writeFileAtomic(path, []byte("key\n"), opts); err != nil {
+		t.Fatalf("Unprivileged write failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Unexpected file: %v %v", info, err)
+	}
+
+	geteuid = func() int { return 0 }
+	if err := writeFileAtomic(path, []byte("key\n"), opts); err == nil {
+		t.Error("Expected unknown owner to fail as root")
+	}
+}
+
+func TestWriteOptionsOwnerFromEnv(t *testing.T) {
+	def := WriteOptions{Mode: 0600, Owner: "systemd-network", Group: "systemd-network"}
+	if o := def.withOwnerFromEnv("NETWORKD_TEST_OWNER"); o != def {
+		t.Errorf("Unset variable changed options: %+v", o)
+	}
+	t.Setenv("NETWORKD_TEST_OWNER", "networkd:adm")
+	if o := def.withOwnerFromEnv("NETWORKD_TEST_OWNER"); o.Owner != "networkd" || o.Group != "adm" || o.Mode != 0600 {
+		t.Errorf("Unexpected options: %+v", o)
+	}
+	t.Setenv("NETWORKD_TEST_OWNER", "")
+	if o := def.withOwnerFromEnv("NETWORKD_TEST_OWNER"); o.Owner != "" || o.Group != "" {
+		t.Errorf("Expected ownership to be left unchanged: %+v", o)
+	}
+}
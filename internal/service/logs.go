@@ -0,0 +1,272 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLogLines = 100
+	MaxLogLines     = 1000
+)
+
+// journalPriorities maps syslog priority names accepted by journalctl -p.
+var journalPriorities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3,
+	"warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// LogQuery selects systemd-networkd journal entries.
+type LogQuery struct {
+	Since     string // Anything journalctl --since accepts, e.g. "-1h" or RFC 3339
+	Until     string
+	Priority  string // Maximum priority, 0-7 or a name such as "warning"
+	Interface string // INTERFACE= journal field
+	Lines     int    // Page size, defaults to DefaultLogLines
+	Cursor    string // Return entries older than this cursor (paging)
+	After     string // Follow mode: resume after this cursor
+}
+
+// LogEntry is one structured journal entry.
+type LogEntry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Priority   int       `json:"priority"`
+	Identifier string    `json:"identifier,omitempty"`
+	Interface  string    `json:"interface,omitempty"`
+	Message    string    `json:"message"`
+	Cursor     string    `json:"cursor"`
+}
+
+// LogPage is one page of entries, newest first. NextCursor fetches the next
+// (older) page and is empty when there are no more entries.
+type LogPage struct {
+	Entries    []LogEntry `json:"entries"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// Text renders the page as classic journal lines, oldest first.
+func (p *LogPage) Text() string {
+	var b strings.Builder
+	for i := len(p.Entries) - 1; i >= 0; i-- {
+		e := p.Entries[i]
+		ident := e.Identifier
+		if ident == "" {
+			ident = "systemd-networkd"
+		}
+		fmt.Fprintf(&b, "%s %s: %s\n", e.Timestamp.Local().Format(time.Stamp), ident, e.Message)
+	}
+	return b.String()
+}
+
+// journalString decodes journal fields, which are emitted as strings or, when
+// not valid UTF-8, as byte arrays.
+type journalString string
+
+func (s *journalString) UnmarshalJSON(data []byte) error {
+	var str string
+	if json.Unmarshal(data, &str) == nil {
+		*s = journalString(str)
+		return nil
+	}
+	var b jsonBytes
+	b.UnmarshalJSON(data)
+	*s = journalString(b)
+	return nil
+}
+
+type journalEntryJSON struct {
+	Cursor            journalString `json:"__CURSOR"`
+	RealtimeTimestamp journalString `json:"__REALTIME_TIMESTAMP"`
+	Priority          journalString `json:"PRIORITY"`
+	SyslogIdentifier  journalString `json:"SYSLOG_IDENTIFIER"`
+	Interface         journalString `json:"INTERFACE"`
+	Message           journalString `json:"MESSAGE"`
+}
+
+// parseJournalEntry parses one line of journalctl -o json output.
+func parseJournalEntry(line []byte) (LogEntry, error) {
+	var raw journalEntryJSON
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return LogEntry{}, fmt.Errorf("failed to parse journal entry: %w", err)
+	}
+	entry := LogEntry{
+		Priority:   6,
+		Identifier: string(raw.SyslogIdentifier),
+		Interface:  string(raw.Interface),
+		Message:    string(raw.Message),
+		Cursor:     string(raw.Cursor),
+	}
+	if p, err := strconv.Atoi(string(raw.Priority)); err == nil {
+		entry.Priority = p
+	}
+	if usec, err := strconv.ParseUint(string(raw.RealtimeTimestamp), 10, 64); err == nil {
+		if t := usecToTime(usec); t != nil {
+			entry.Timestamp = *t
+		}
+	}
+	return entry, nil
+}
+
+// parseJournal parses newline-delimited journalctl -o json output.
+func parseJournal(out []byte) ([]LogEntry, error) {
+	entries := []LogEntry{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry, err := parseJournalEntry([]byte(line))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// scanLines passes each non-empty line of r to onLine. Journal entries can be
+// large, so the scanner buffer is raised well above the default.
+func scanLines(r io.Reader, onLine func([]byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := onLine(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func validJournalArg(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateLogQuery checks a query before it is turned into journalctl arguments.
+func ValidateLogQuery(q LogQuery) error {
+	_, err := journalArgs(q, false)
+	return err
+}
+
+// journalArgs builds the journalctl arguments for q. Queries return the newest
+// entries first; follow mode streams in chronological order.
+func journalArgs(q LogQuery, follow bool) ([]string, error) {
+	args := []string{"-u", "systemd-networkd", "-o", "json", "--no-pager"}
+
+	for _, v := range []string{q.Since, q.Until, q.Cursor, q.After} {
+		if !validJournalArg(v) {
+			return nil, fmt.Errorf("invalid log query parameter")
+		}
+	}
+	if q.Since != "" {
+		args = append(args, "--since="+q.Since)
+	}
+	if q.Until != "" {
+		args = append(args, "--until="+q.Until)
+	}
+	if q.Priority != "" {
+		p := strings.ToLower(q.Priority)
+		if n, err := strconv.Atoi(p); err != nil || n < 0 || n > 7 {
+			if _, ok := journalPriorities[p]; !ok {
+				return nil, fmt.Errorf("invalid priority: %s", q.Priority)
+			}
+		}
+		args = append(args, "--priority="+p)
+	}
+	if q.Lines < 0 || q.Lines > MaxLogLines {
+		return nil, fmt.Errorf("lines must be between 0 and %d", MaxLogLines)
+	}
+	lines := q.Lines
+	if lines == 0 && !follow {
+		lines = DefaultLogLines
+	}
+
+	if follow {
+		args = append(args, "--follow")
+		if q.After != "" {
+			args = append(args, "--after-cursor="+q.After)
+		} else {
+			args = append(args, "--lines="+strconv.Itoa(lines))
+		}
+	} else {
+		// In reverse mode --after-cursor continues towards older entries
+		args = append(args, "--reverse", "--lines="+strconv.Itoa(lines))
+		if q.Cursor != "" {
+			args = append(args, "--after-cursor="+q.Cursor)
+		}
+	}
+
+	// Field matches must come last
+	if q.Interface != "" {
+		if err := ValidateInterfaceName(q.Interface); err != nil {
+			return nil, err
+		}
+		args = append(args, "INTERFACE="+q.Interface)
+	}
+	return args, nil
+}
+
+// GetLogs returns one page of systemd-networkd journal entries.
+func (s *NetworkdService) GetLogs(host string, q LogQuery) (*LogPage, error) {
+	args, err := journalArgs(q, false)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.ReadJournal(args)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseJournal(out)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := q.Lines
+	if lines == 0 {
+		lines = DefaultLogLines
+	}
+	page := &LogPage{Entries: entries}
+	if len(entries) > lines {
+		page.Entries = entries[:lines]
+	}
+	if len(page.Entries) == lines {
+		page.NextCursor = page.Entries[lines-1].Cursor
+	}
+	return page, nil
+}
+
+// FollowLogs streams new journal entries to fn until ctx is cancelled, fn
+// returns an error or the journal stream ends.
+func (s *NetworkdService) FollowLogs(ctx context.Context, host string, q LogQuery, fn func(LogEntry) error) error {
+	args, err := journalArgs(q, true)
+	if err != nil {
+		return err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return err
+	}
+	return c.FollowJournal(ctx, args, func(line []byte) error {
+		entry, err := parseJournalEntry(line)
+		if err != nil {
+			return err
+		}
+		return fn(entry)
+	})
+}
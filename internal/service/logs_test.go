@@ -0,0 +1,57 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJournal(t *testing.T) {
+	out := []byte(`{"__CURSOR":"s=1;i=10","__REALTIME_TIMESTAMP":"1700000000000000","PRIORITY":"6","SYSLOG_IDENTIFIER":"systemd-networkd","INTERFACE":"eth0","MESSAGE":"eth0: Gained carrier"}
+{"__CURSOR":"s=1;i=9","__REALTIME_TIMESTAMP":"1699999999000000","PRIORITY":"3","SYSLOG_IDENTIFIER":"systemd-networkd","MESSAGE":[104,105]}
+`)
+	entries, err := parseJournal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Interface != "eth0" || e.Priority != 6 || e.Cursor != "s=1;i=10" || e.Timestamp.Unix() != 1700000000 {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+	if entries[1].Message != "hi" || entries[1].Priority != 3 {
+		t.Errorf("Unexpected binary message entry: %+v", entries[1])
+	}
+}
+
+func TestJournalArgs(t *testing.T) {
+	args, err := journalArgs(LogQuery{Priority: "warning", Interface: "eth0", Cursor: "s=1;i=9", Lines: 50}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(args, " ")
+	for _, want := range []string{"-o json", "--priority=warning", "--reverse", "--lines=50", "--after-cursor=s=1;i=9"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Missing %q in %v", want, args)
+		}
+	}
+	if args[len(args)-1] != "INTERFACE=eth0" {
+		t.Errorf("Expected interface match last, got %v", args)
+	}
+
+	args, err = journalArgs(LogQuery{After: "s=1;i=10"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined = strings.Join(args, " ")
+	if !strings.Contains(joined, "--follow") || !strings.Contains(joined, "--after-cursor=s=1;i=10") || strings.Contains(joined, "--reverse") {
+		t.Errorf("Unexpected follow args: %v", args)
+	}
+
+	for _, q := range []LogQuery{{Priority: "loud"}, {Lines: 5000}, {Interface: "../eth0"}, {Since: "today\n--merge"}} {
+		if _, err := journalArgs(q, false); err == nil {
+			t.Errorf("Expected %+v to be rejected", q)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest identification, checked on apply.
+const (
+	ManifestAPIVersion = "networkd-api/v1"
+	ManifestKind       = "NetworkdManifest"
+)
+
+// Manifest is a host's configuration as one declarative document: every
+// config file and drop-in parsed with INIToMap, keyed by filename or
+// drop-in path ("10-eth0.network.d/mtu.conf").
+type Manifest struct {
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string `json:"kind" yaml:"kind"`
+	// Host the manifest belongs to; apply refuses other targets when set
+	Host  string                            `json:"host,omitempty" yaml:"host,omitempty"`
+	Files map[string]map[string]interface{} `json:"files" yaml:"files"`
+}
+
+// BuildManifest parses every config file and drop-in of host into a
+// manifest. Secrets are redacted unless includeSecrets is set; applying the
+// manifest back keeps the stored ones.
+func (s *NetworkdService) BuildManifest(host string, includeSecrets bool) (*Manifest, error) {
+	files, err := s.listConfigPaths(host)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		APIVersion: ManifestAPIVersion,
+		Kind:       ManifestKind,
+		Host:       normalizeHost(host),
+		Files:      make(map[string]map[string]interface{}, len(files)),
+	}
+	for _, filename := range files {
+		content, err := s.readConfigPath(host, filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		configType, _ := configTypeForPath(filename)
+		cfg, err := INIToMap(content, s.Schema, configType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		if !includeSecrets {
+			RedactSecrets(cfg, s.Schema, configType)
+		}
+		m.Files[filename] = cfg
+	}
+	return m, nil
+}
+
+// listConfigPaths returns the host's config files and their drop-ins
+// ("10-eth0.network.d/mtu.conf"), sorted.
+func (s *NetworkdService) listConfigPaths(host string) ([]string, error) {
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := c.ListConfigDir("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config dir: %w", err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() {
+			if validateFilename(name) == nil {
+				paths = append(paths, name)
+			}
+			continue
+		}
+		if parent, ok := strings.CutSuffix(name, ".d"); !ok || validateFilename(parent) != nil {
+			continue
+		}
+		dropins, err := c.GlobHostFiles(filepath.Join(c.GetConfigDir(), name, "*.conf"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", name, err)
+		}
+		for _, d := range dropins {
+			paths = append(paths, name+"/"+filepath.Base(d))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// listConfigFilenames returns the host's config files (all types), sorted.
+func (s *NetworkdService) listConfigFilenames(host string) ([]string, error) {
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := c.ListConfigDir("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config dir: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && validateFilename(entry.Name()) == nil {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ParseManifest decodes a manifest in YAML or JSON (JSON is valid YAML).
+// Values are normalised to what encoding/json produces, so a manifest
+// behaves the same as the equivalent API request body.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := decodeDocument(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+	}
+	if m.APIVersion != ManifestAPIVersion || m.Kind != ManifestKind {
+		return nil, fmt.Errorf("%w: expected apiVersion %s and kind %s", ErrInvalidBatch, ManifestAPIVersion, ManifestKind)
+	}
+	return &m, nil
+}
+
+// decodeDocument decodes YAML or JSON into v through encoding/json, so
+// json tags and JSON value types apply to both. Unknown fields are errors.
+func decodeDocument(data []byte, v interface{}) error {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(normalized))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// YAML renders the manifest with files in filename order.
+func (m *Manifest) YAML() ([]byte, error) {
+	return yaml.Marshal(m)
+}
+
+// ManifestOptions control how a manifest is applied.
+type ManifestOptions struct {
+	// Prune deletes host files that are not in the manifest
+	Prune  bool
+	DryRun bool
+	Reload bool
+}
+
+// ManifestPlan is the difference between a manifest and a host.
+type ManifestPlan struct {
+	Changes   []BatchOperationResult `json:"changes"`
+	Unchanged []string               `json:"unchanged"`
+	// Unmanaged lists host files missing from the manifest that were kept
+	// because prune was not requested
+	Unmanaged []string     `json:"unmanaged"`
+	Applied   bool         `json:"applied"`
+	Result    *BatchResult `json:"result,omitempty"`
+}
+
+// ApplyManifest computes create/update/delete operations that bring host in
+// line with the manifest and, unless DryRun, executes them as one batch.
+func (s *NetworkdService) ApplyManifest(host string, m *Manifest, opts ManifestOptions) (*ManifestPlan, error) {
+	if m.Host != "" && m.Host != normalizeHost(host) {
+		return nil, fmt.Errorf("%w: manifest is for host %q, not %q", ErrInvalidBatch, m.Host, normalizeHost(host))
+	}
+	existing, err := s.listConfigPaths(host)
+	if err != nil {
+		return nil, err
+	}
+	onHost := make(map[string]bool, len(existing))
+	for _, f := range existing {
+		onHost[f] = true
+	}
+
+	plan := &ManifestPlan{Changes: []BatchOperationResult{}, Unchanged: []string{}, Unmanaged: []string{}}
+	var ops []BatchOperation
+	for _, filename := range sortedKeys(m.Files) {
+		cfg := m.Files[filename]
+		configType, ok := configTypeForPath(filename)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not a config file or drop-in", ErrInvalidBatch, filename)
+		}
+		if cfg == nil {
+			return nil, fmt.Errorf("%w: %s has no config", ErrInvalidBatch, filename)
+		}
+		op := BatchCreate
+		if onHost[filename] {
+			op = BatchUpdate
+			same, err := s.sameAsHost(host, filename, configType, cfg)
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				plan.Unchanged = append(plan.Unchanged, filename)
+				continue
+			}
+		}
+		ops = append(ops, BatchOperation{Op: op, Filename: filename, Config: cfg})
+	}
+	for _, filename := range existing {
+		if _, ok := m.Files[filename]; ok {
+			continue
+		}
+		if opts.Prune {
+			ops = append(ops, BatchOperation{Op: BatchDelete, Filename: filename})
+		} else {
+			plan.Unmanaged = append(plan.Unmanaged, filename)
+		}
+	}
+
+	if len(ops) == 0 {
+		return plan, nil
+	}
+	// Validate everything even for a dry run, so the plan is one that applies
+	changes, results, allocs, err := s.prepareBatch(host, ops)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+	}
+	plan.Changes = results
+	if opts.DryRun {
+		s.ReleaseAllocations(allocs)
+		return plan, nil
+	}
+	result, err := s.applyBatch(host, BatchRequest{Operations: ops, Reload: opts.Reload}, changes, results, allocs)
+	if err != nil {
+		return nil, err
+	}
+	plan.Applied = true
+	plan.Result = result
+	return plan, nil
+}
+
+// sameAsHost reports whether cfg renders to the same file as the host's
+// current one, with redacted secrets standing for the stored values.
+func (s *NetworkdService) sameAsHost(host, filename, configType string, cfg map[string]interface{}) (bool, error) {
+	content, err := s.readConfigPath(host, filename)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	stored, err := INIToMap(content, s.Schema, configType)
+	if err != nil {
+		return false, nil
+	}
+	current, err := MapToINI(stored, s.Schema, configType)
+	if err != nil {
+		return false, nil
+	}
+
+	// Work on a copy: PreserveSecrets fills in values in place
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return false, err
+	}
+	var desired map[string]interface{}
+	json.Unmarshal(data, &desired)
+	PreserveSecrets(desired, stored, s.Schema, configType)
+	rendered, err := MapToINI(desired, s.Schema, configType)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %v", ErrInvalidBatch, filename, err)
+	}
+	return rendered == current, nil
+}
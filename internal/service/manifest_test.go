@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest([]byte(`apiVersion: networkd-api/v1
+kind: NetworkdManifest
+files:
+  10-eth0.network:
+    Match:
+      Name: eth0
+    Network:
+      DHCP: yes
+      IPv6AcceptRA: true
+      Address: [10.0.0.1/24, 10.0.0.2/24]
+    Link:
+      MTUBytes: 9000
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	network, _ := m.Files["10-eth0.network"]["Network"].(map[string]interface{})
+	link, _ := m.Files["10-eth0.network"]["Link"].(map[string]interface{})
+	// Values must come out as encoding/json would decode them; YAML 1.2
+	// keeps "yes" a string
+	if network["DHCP"] != "yes" || network["IPv6AcceptRA"] != true || link["MTUBytes"] != float64(9000) {
+		t.Errorf("Unexpected values %#v %#v", network, link)
+	}
+	if addrs, ok := network["Address"].([]interface{}); !ok || len(addrs) != 2 {
+		t.Errorf("Unexpected Address %#v", network["Address"])
+	}
+
+	if _, err := ParseManifest([]byte(`{"apiVersion": "v2", "kind": "NetworkdManifest", "files": {}}`)); !errors.Is(err, ErrInvalidBatch) {
+		t.Errorf("Expected wrong apiVersion to be rejected, got %v", err)
+	}
+	if _, err := ParseManifest([]byte("files: [")); !errors.Is(err, ErrInvalidBatch) {
+		t.Errorf("Expected malformed YAML to be rejected, got %v", err)
+	}
+}
+
+func TestManifestApplyRefusesRedactedSecrets(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	wg := func() map[string]interface{} {
+		return map[string]interface{}{
+			"NetDev":    map[string]interface{}{"Name": "wg0", "Kind": "wireguard"},
+			"WireGuard": map[string]interface{}{"PrivateKey": RedactedValue, "ListenPort": "51820"},
+		}
+	}
+	m := &Manifest{APIVersion: ManifestAPIVersion, Kind: ManifestKind, Files: map[string]map[string]interface{}{"50-wg0.netdev": wg()}}
+
+	// A redacted export applied where the file does not exist has no key
+	// to keep
+	if _, err := svc.ApplyManifest("local", m, ManifestOptions{}); err == nil || !strings.Contains(err.Error(), "redacted") {
+		t.Fatalf("Expected redacted secret to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "50-wg0.netdev")); err == nil {
+		t.Error("Placeholder written as the private key")
+	}
+	if _, err := svc.WriteConfig("local", "50-wg0.netdev", "netdev", wg(), false); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig for a single write, got %v", err)
+	}
+
+	// With the file in place the stored key is kept
+	os.WriteFile(filepath.Join(tmpDir, "50-wg0.netdev"), []byte("[NetDev]\nName=wg0\nKind=wireguard\n\n[WireGuard]\nPrivateKey=c2VjcmV0\nListenPort=51821\n"), 0600)
+	if _, err := svc.ApplyManifest("local", m, ManifestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "50-wg0.netdev")); !strings.Contains(string(content), "PrivateKey = c2VjcmV0") || !strings.Contains(string(content), "51820") {
+		t.Errorf("Stored key not kept: %s", content)
+	}
+}
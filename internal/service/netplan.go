@@ -0,0 +1,651 @@
+package service
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlBool accepts the YAML 1.1 booleans netplan allows (yes/no/on/off).
+type yamlBool bool
+
+func (b *yamlBool) UnmarshalYAML(value *yaml.Node) error {
+	switch strings.ToLower(value.Value) {
+	case "true", "yes", "on", "y":
+		*b = true
+	case "false", "no", "off", "n":
+		*b = false
+	default:
+		return fmt.Errorf("line %d: invalid boolean %q", value.Line, value.Value)
+	}
+	return nil
+}
+
+type netplanMatch struct {
+	Name       string      `yaml:"name"`
+	MACAddress string      `yaml:"macaddress"`
+	Driver     interface{} `yaml:"driver"` // String or list
+}
+
+type netplanRoute struct {
+	To     string   `yaml:"to"`
+	Via    string   `yaml:"via"`
+	From   string   `yaml:"from"`
+	Metric *int     `yaml:"metric"`
+	Table  *int     `yaml:"table"`
+	OnLink yamlBool `yaml:"on-link"`
+	Scope  string   `yaml:"scope"`
+	Type   string   `yaml:"type"`
+	MTU    int      `yaml:"mtu"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+type netplanRoutingPolicy struct {
+	From          string `yaml:"from"`
+	To            string `yaml:"to"`
+	Table         *int   `yaml:"table"`
+	Priority      *int   `yaml:"priority"`
+	Mark          *int   `yaml:"mark"`
+	TypeOfService *int   `yaml:"type-of-service"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+type netplanWireGuardPeer struct {
+	Keys struct {
+		Public string `yaml:"public"`
+		Shared string `yaml:"shared"`
+	} `yaml:"keys"`
+	AllowedIPs []string `yaml:"allowed-ips"`
+	Endpoint   string   `yaml:"endpoint"`
+	Keepalive  int      `yaml:"keepalive"`
+}
+
+type netplanDevice struct {
+	Match     *netplanMatch `yaml:"match"`
+	SetName   string        `yaml:"set-name"`
+	DHCP4     yamlBool      `yaml:"dhcp4"`
+	DHCP6     yamlBool      `yaml:"dhcp6"`
+	Addresses []yaml.Node   `yaml:"addresses"`
+	Gateway4  string        `yaml:"gateway4"`
+	Gateway6  string        `yaml:"gateway6"`
+
+	Nameservers struct {
+		Addresses []string `yaml:"addresses"`
+		Search    []string `yaml:"search"`
+	} `yaml:"nameservers"`
+
+	MTU           int                    `yaml:"mtu"`
+	MACAddress    string                 `yaml:"macaddress"`
+	Optional      yamlBool               `yaml:"optional"`
+	LinkLocal     []string               `yaml:"link-local"`
+	AcceptRA      *yamlBool              `yaml:"accept-ra"`
+	Routes        []netplanRoute         `yaml:"routes"`
+	RoutingPolicy []netplanRoutingPolicy `yaml:"routing-policy"`
+
+	// Bonds and bridges
+	Interfaces []string               `yaml:"interfaces"`
+	Parameters map[string]interface{} `yaml:"parameters"`
+
+	// VLANs and VXLAN tunnels
+	ID   int    `yaml:"id"`
+	Link string `yaml:"link"`
+
+	// Tunnels
+	Mode   string      `yaml:"mode"`
+	Local  string      `yaml:"local"`
+	Remote string      `yaml:"remote"`
+	TTL    int         `yaml:"ttl"`
+	Key    interface{} `yaml:"key"`
+	Keys   struct {
+		Private string `yaml:"private"`
+	} `yaml:"keys"`
+	Port  int                    `yaml:"port"`
+	Peers []netplanWireGuardPeer `yaml:"peers"`
+
+	// Wifis
+	AccessPoints map[string]interface{} `yaml:"access-points"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+type netplanNetwork struct {
+	Version   int                      `yaml:"version"`
+	Renderer  string                   `yaml:"renderer"`
+	Ethernets map[string]netplanDevice `yaml:"ethernets"`
+	Bonds     map[string]netplanDevice `yaml:"bonds"`
+	Bridges   map[string]netplanDevice `yaml:"bridges"`
+	VLANs     map[string]netplanDevice `yaml:"vlans"`
+	Tunnels   map[string]netplanDevice `yaml:"tunnels"`
+	Wifis     map[string]netplanDevice `yaml:"wifis"`
+
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+// mergeYAML merges netplan documents the way netplan does across files in
+// /etc/netplan: mappings are merged recursively, anything else is replaced
+// by the later file.
+func mergeYAML(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]interface{}); ok {
+			if dm, ok := dst[k].(map[string]interface{}); ok {
+				mergeYAML(dm, sm)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// parseNetplan merges netplan YAML files in filename order.
+func parseNetplan(files map[string]string) (*netplanNetwork, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged := map[string]interface{}{}
+	for _, name := range names {
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal([]byte(files[name]), &doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		mergeYAML(merged, doc)
+	}
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Network netplanNetwork         `yaml:"network"`
+		Extra   map[string]interface{} `yaml:",inline"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Network.Version != 0 && doc.Network.Version != 2 {
+		return nil, fmt.Errorf("unsupported netplan version %d", doc.Network.Version)
+	}
+	return &doc.Network, nil
+}
+
+func (b *importBuilder) warnExtra(where string, extra map[string]interface{}) {
+	for _, k := range sortedKeys(extra) {
+		b.warn("%s: %q is not supported", where, k)
+	}
+}
+
+// ConvertNetplan converts netplan YAML files (file name -> content) into
+// networkd files. Nothing is validated or written.
+func ConvertNetplan(files map[string]string, prefix string) (*ImportResult, error) {
+	np, err := parseNetplan(files)
+	if err != nil {
+		return nil, err
+	}
+	b := newImportBuilder(prefix)
+	if np.Renderer != "" && np.Renderer != "networkd" {
+		b.warn("renderer %q: the generated files are for systemd-networkd", np.Renderer)
+	}
+	b.warnExtra("network", np.Extra)
+
+	for _, id := range sortedKeys(np.Ethernets) {
+		b.netplanEthernet(id, np.Ethernets[id])
+	}
+	for _, id := range sortedKeys(np.Wifis) {
+		dev := np.Wifis[id]
+		if len(dev.AccessPoints) > 0 {
+			b.warn("wifis.%s: access points are not managed by networkd; configure wpa_supplicant or iwd separately", id)
+		}
+		b.netplanEthernet(id, dev)
+	}
+	for _, id := range sortedKeys(np.Bonds) {
+		b.netplanBond(id, np.Bonds[id])
+	}
+	for _, id := range sortedKeys(np.Bridges) {
+		b.netplanBridge(id, np.Bridges[id])
+	}
+	for _, id := range sortedKeys(np.VLANs) {
+		b.netplanVLAN(id, np.VLANs[id])
+	}
+	for _, id := range sortedKeys(np.Tunnels) {
+		b.netplanTunnel(id, np.Tunnels[id])
+	}
+
+	for id := range b.network {
+		if err := ValidateInterfaceName(id); err != nil {
+			return nil, fmt.Errorf("netplan id %q cannot be used as a file name: %w", id, err)
+		}
+	}
+	return b.result(), nil
+}
+
+// netplanEthernet handles physical devices, which may match on properties
+// other than the name and be renamed through a generated .link file.
+func (b *importBuilder) netplanEthernet(id string, dev netplanDevice) {
+	where := "ethernets." + id
+	name := id
+	if dev.Match != nil {
+		match := map[string]interface{}{}
+		if dev.Match.Name != "" {
+			match["Name"] = dev.Match.Name
+		}
+		if dev.Match.MACAddress != "" {
+			match["MACAddress"] = dev.Match.MACAddress
+		}
+		switch drv := dev.Match.Driver.(type) {
+		case string:
+			match["Driver"] = drv
+		case []interface{}:
+			var names []string
+			for _, d := range drv {
+				names = append(names, fmt.Sprint(d))
+			}
+			match["Driver"] = strings.Join(names, " ")
+		}
+		if dev.SetName != "" {
+			link := b.file(id, "link")
+			link.Config["Match"] = match
+			link.Config["Link"] = map[string]interface{}{"Name": dev.SetName}
+			name = dev.SetName
+			match = map[string]interface{}{"Name": dev.SetName}
+		}
+		f := b.networkFile(id)
+		f.Config["Match"] = match
+		if name != id {
+			b.network[name] = f
+		}
+	} else if dev.SetName != "" {
+		b.warn("%s: set-name requires a match block and was ignored", where)
+	}
+	b.netplanCommon(id, where, dev)
+}
+
+// netplanCommon converts the settings shared by all device types into the
+// device's .network file.
+func (b *importBuilder) netplanCommon(id, where string, dev netplanDevice) {
+	cfg := b.networkFile(id).Config
+	network := section(cfg, "Network")
+
+	switch dhcp4, dhcp6 := bool(dev.DHCP4), bool(dev.DHCP6); {
+	case dhcp4 && dhcp6:
+		network["DHCP"] = "yes"
+	case dhcp4:
+		network["DHCP"] = "ipv4"
+	case dhcp6:
+		network["DHCP"] = "ipv6"
+	}
+	for _, node := range dev.Addresses {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			addValue(network, "Address", node.Value)
+		case yaml.MappingNode:
+			// {"10.0.0.1/24": {lifetime: 0, label: ...}}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				addValue(network, "Address", node.Content[i].Value)
+				b.warn("%s: address options for %s (lifetime, label) are not converted", where, node.Content[i].Value)
+			}
+		}
+	}
+	for _, gw := range []string{dev.Gateway4, dev.Gateway6} {
+		if gw != "" {
+			addValue(network, "Gateway", gw)
+		}
+	}
+	if len(dev.Nameservers.Addresses) > 0 {
+		addValue(network, "DNS", dev.Nameservers.Addresses...)
+	}
+	if len(dev.Nameservers.Search) > 0 {
+		network["Domains"] = strings.Join(dev.Nameservers.Search, " ")
+	}
+	if dev.LinkLocal != nil {
+		v4, v6 := false, false
+		for _, ll := range dev.LinkLocal {
+			v4 = v4 || ll == "ipv4"
+			v6 = v6 || ll == "ipv6"
+		}
+		switch {
+		case v4 && v6:
+			network["LinkLocalAddressing"] = "yes"
+		case v4:
+			network["LinkLocalAddressing"] = "ipv4"
+		case v6:
+			network["LinkLocalAddressing"] = "ipv6"
+		default:
+			network["LinkLocalAddressing"] = "no"
+		}
+	}
+	if dev.AcceptRA != nil {
+		network["IPv6AcceptRA"] = boolString(bool(*dev.AcceptRA))
+	}
+	if len(network) == 0 {
+		delete(cfg, "Network")
+	}
+
+	if dev.MTU > 0 || dev.MACAddress != "" || dev.Optional {
+		link := section(cfg, "Link")
+		if dev.MTU > 0 {
+			link["MTUBytes"] = strconv.Itoa(dev.MTU)
+		}
+		if dev.MACAddress != "" {
+			link["MACAddress"] = dev.MACAddress
+		}
+		if dev.Optional {
+			link["RequiredForOnline"] = "no"
+		}
+	}
+
+	for i, r := range dev.Routes {
+		route := map[string]interface{}{}
+		to := r.To
+		if to == "default" {
+			to = "0.0.0.0/0"
+			if addr, err := netip.ParseAddr(r.Via); err == nil && addr.Is6() {
+				to = "::/0"
+			}
+		}
+		if to != "" {
+			route["Destination"] = to
+		}
+		if r.Via != "" {
+			route["Gateway"] = r.Via
+		}
+		if r.From != "" {
+			route["PreferredSource"] = r.From
+		}
+		if r.Metric != nil {
+			route["Metric"] = strconv.Itoa(*r.Metric)
+		}
+		if r.Table != nil {
+			route["Table"] = strconv.Itoa(*r.Table)
+		}
+		if r.OnLink {
+			route["GatewayOnLink"] = "yes"
+		}
+		if r.Scope != "" {
+			route["Scope"] = r.Scope
+		}
+		if r.Type != "" {
+			route["Type"] = r.Type
+		}
+		if r.MTU > 0 {
+			route["MTUBytes"] = strconv.Itoa(r.MTU)
+		}
+		b.warnExtra(fmt.Sprintf("%s.routes[%d]", where, i), r.Extra)
+		appendSectionItem(cfg, "Route", route)
+	}
+	for i, p := range dev.RoutingPolicy {
+		rule := map[string]interface{}{}
+		if p.From != "" {
+			rule["From"] = p.From
+		}
+		if p.To != "" {
+			rule["To"] = p.To
+		}
+		if p.Table != nil {
+			rule["Table"] = strconv.Itoa(*p.Table)
+		}
+		if p.Priority != nil {
+			rule["Priority"] = strconv.Itoa(*p.Priority)
+		}
+		if p.Mark != nil {
+			rule["FirewallMark"] = strconv.Itoa(*p.Mark)
+		}
+		if p.TypeOfService != nil {
+			rule["TypeOfService"] = strconv.Itoa(*p.TypeOfService)
+		}
+		b.warnExtra(fmt.Sprintf("%s.routing-policy[%d]", where, i), p.Extra)
+		appendSectionItem(cfg, "RoutingPolicyRule", rule)
+	}
+	b.warnExtra(where, dev.Extra)
+}
+
+// netplanBondParameters and netplanBridgeParameters map netplan parameters
+// to networkd keys.
+var netplanBondParameters = map[string]string{
+	"mode":                    "Mode",
+	"lacp-rate":               "LACPTransmitRate",
+	"mii-monitor-interval":    "MIIMonitorSec",
+	"transmit-hash-policy":    "TransmitHashPolicy",
+	"ad-select":               "AdSelect",
+	"up-delay":                "UpDelaySec",
+	"down-delay":              "DownDelaySec",
+	"arp-interval":            "ARPIntervalSec",
+	"arp-validate":            "ARPValidate",
+	"arp-all-targets":         "ARPAllTargets",
+	"fail-over-mac-policy":    "FailOverMACPolicy",
+	"primary-reselect-policy": "PrimaryReselectPolicy",
+	"min-links":               "MinLinks",
+	"gratuitous-arp":          "GratuitousARP",
+	"all-members-active":      "AllSlavesActive",
+	"learn-packet-interval":   "LearnPacketIntervalSec",
+}
+
+// netplanBondMillis are the bond intervals that netplan reads as
+// milliseconds when no time suffix is given; networkd would read seconds.
+var netplanBondMillis = map[string]bool{
+	"mii-monitor-interval": true, "up-delay": true, "down-delay": true, "arp-interval": true,
+}
+
+var netplanBridgeParameters = map[string]string{
+	"stp":           "STP",
+	"priority":      "Priority",
+	"forward-delay": "ForwardDelaySec",
+	"hello-time":    "HelloTimeSec",
+	"max-age":       "MaxAgeSec",
+	"ageing-time":   "AgeingTimeSec",
+	"aging-time":    "AgeingTimeSec",
+}
+
+func paramString(v interface{}) string {
+	switch val := v.(type) {
+	case bool:
+		return boolString(val)
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, p := range val {
+			parts[i] = fmt.Sprint(p)
+		}
+		return strings.Join(parts, " ")
+	}
+	return fmt.Sprint(v)
+}
+
+func (b *importBuilder) netplanBond(id string, dev netplanDevice) {
+	where := "bonds." + id
+	cfg := b.netdev(id, "bond")
+	bond := map[string]interface{}{}
+	for _, k := range sortedKeys(dev.Parameters) {
+		switch key, ok := netplanBondParameters[k]; {
+		case ok:
+			v := paramString(dev.Parameters[k])
+			if _, err := strconv.Atoi(v); err == nil && netplanBondMillis[k] {
+				v += "ms"
+			}
+			bond[key] = v
+		case k == "primary":
+			member := paramString(dev.Parameters[k])
+			section(b.networkFile(member).Config, "Network")["PrimarySlave"] = "yes"
+		case k == "arp-ip-targets":
+			bond["ARPIPTargets"] = paramString(dev.Parameters[k])
+		default:
+			b.warn("%s.parameters: %q is not supported", where, k)
+		}
+	}
+	if len(bond) > 0 {
+		cfg["Bond"] = bond
+	}
+	for _, m := range dev.Interfaces {
+		section(b.networkFile(m).Config, "Network")["Bond"] = id
+	}
+	b.netplanCommon(id, where, dev)
+}
+
+func (b *importBuilder) netplanBridge(id string, dev netplanDevice) {
+	where := "bridges." + id
+	cfg := b.netdev(id, "bridge")
+	bridge := map[string]interface{}{}
+	for _, k := range sortedKeys(dev.Parameters) {
+		if key, ok := netplanBridgeParameters[k]; ok {
+			bridge[key] = paramString(dev.Parameters[k])
+			continue
+		}
+		// path-cost and port-priority are per port: [Bridge] in the port's .network
+		if k == "path-cost" || k == "port-priority" {
+			key := map[string]string{"path-cost": "Cost", "port-priority": "Priority"}[k]
+			if ports, ok := dev.Parameters[k].(map[string]interface{}); ok {
+				for port, v := range ports {
+					section(b.networkFile(port).Config, "Bridge")[key] = paramString(v)
+				}
+				continue
+			}
+		}
+		b.warn("%s.parameters: %q is not supported", where, k)
+	}
+	if len(bridge) > 0 {
+		cfg["Bridge"] = bridge
+	}
+	for _, m := range dev.Interfaces {
+		section(b.networkFile(m).Config, "Network")["Bridge"] = id
+	}
+	b.netplanCommon(id, where, dev)
+}
+
+func (b *importBuilder) netplanVLAN(id string, dev netplanDevice) {
+	where := "vlans." + id
+	if dev.Link == "" {
+		b.warn("%s: no link given, VLAN skipped", where)
+		return
+	}
+	cfg := b.netdev(id, "vlan")
+	cfg["VLAN"] = map[string]interface{}{"Id": strconv.Itoa(dev.ID)}
+	addValue(section(b.networkFile(dev.Link).Config, "Network"), "VLAN", id)
+	b.netplanCommon(id, where, dev)
+}
+
+var netplanTunnelKinds = map[string]string{
+	"gre": "gre", "gretap": "gretap", "ip6gre": "ip6gre", "ip6gretap": "ip6gretap",
+	"ipip": "ipip", "ipip6": "ip6tnl", "ip6ip6": "ip6tnl", "sit": "sit",
+	"vti": "vti", "vti6": "vti6", "isatap": "sit",
+}
+
+func (b *importBuilder) netplanTunnel(id string, dev netplanDevice) {
+	where := "tunnels." + id
+	switch {
+	case dev.Mode == "wireguard":
+		cfg := b.netdev(id, "wireguard")
+		wg := map[string]interface{}{}
+		key := dev.Keys.Private
+		if k, ok := dev.Key.(string); ok && key == "" {
+			key = k
+		}
+		if strings.HasPrefix(key, "/") {
+			wg["PrivateKeyFile"] = key
+		} else if key != "" {
+			wg["PrivateKey"] = key
+		}
+		if dev.Port > 0 {
+			wg["ListenPort"] = strconv.Itoa(dev.Port)
+		}
+		cfg["WireGuard"] = wg
+		for _, p := range dev.Peers {
+			peer := map[string]interface{}{"PublicKey": p.Keys.Public}
+			if p.Keys.Shared != "" {
+				if strings.HasPrefix(p.Keys.Shared, "/") {
+					peer["PresharedKeyFile"] = p.Keys.Shared
+				} else {
+					peer["PresharedKey"] = p.Keys.Shared
+				}
+			}
+			if len(p.AllowedIPs) > 0 {
+				peer["AllowedIPs"] = strings.Join(p.AllowedIPs, ",")
+			}
+			if p.Endpoint != "" {
+				peer["Endpoint"] = p.Endpoint
+			}
+			if p.Keepalive > 0 {
+				peer["PersistentKeepalive"] = strconv.Itoa(p.Keepalive)
+			}
+			appendSectionItem(cfg, "WireGuardPeer", peer)
+		}
+	case dev.Mode == "vxlan":
+		cfg := b.netdev(id, "vxlan")
+		vxlan := map[string]interface{}{"VNI": strconv.Itoa(dev.ID)}
+		if dev.Remote != "" {
+			vxlan["Remote"] = dev.Remote
+		}
+		if dev.Local != "" {
+			vxlan["Local"] = dev.Local
+		}
+		if dev.TTL > 0 {
+			vxlan["TTL"] = strconv.Itoa(dev.TTL)
+		}
+		if dev.Port > 0 {
+			vxlan["DestinationPort"] = strconv.Itoa(dev.Port)
+		}
+		cfg["VXLAN"] = vxlan
+		if dev.Link != "" {
+			addValue(section(b.networkFile(dev.Link).Config, "Network"), "VXLAN", id)
+		} else {
+			b.warn("%s: no link given; add VXLAN=%s to the underlay's .network", where, id)
+		}
+	case netplanTunnelKinds[dev.Mode] != "":
+		cfg := b.netdev(id, netplanTunnelKinds[dev.Mode])
+		tunnel := map[string]interface{}{}
+		if dev.Local != "" {
+			tunnel["Local"] = dev.Local
+		}
+		if dev.Remote != "" {
+			tunnel["Remote"] = dev.Remote
+		}
+		if dev.TTL > 0 {
+			tunnel["TTL"] = strconv.Itoa(dev.TTL)
+		}
+		if k, ok := dev.Key.(string); ok && k != "" {
+			tunnel["Key"] = k
+		}
+		if dev.Mode == "isatap" {
+			tunnel["ISATAP"] = "yes"
+		}
+		cfg["Tunnel"] = tunnel
+		b.warn("%s: attach the tunnel to its underlay with Tunnel=%s in that link's .network", where, id)
+	default:
+		b.warn("%s: tunnel mode %q is not supported", where, dev.Mode)
+		return
+	}
+	b.netplanCommon(id, where, dev)
+}
+
+// NetplanPattern is where netplan configuration is read from on a host.
+const NetplanPattern = "/etc/netplan/*.yaml"
+
+// ImportNetplan converts netplan YAML for host, validates the result and,
+// with apply, writes it. Without uploaded files, the host's /etc/netplan is
+// read.
+func (s *NetworkdService) ImportNetplan(host string, files map[string]string, opts ImportOptions) (*ImportResult, error) {
+	if len(files) == 0 {
+		src, err := s.hostImportSource(host)
+		if err != nil {
+			return nil, err
+		}
+		if files, err = src.readAll(NetplanPattern); err != nil {
+			return nil, fmt.Errorf("failed to read netplan configuration: %w", err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("%w: no files match %s", ErrInvalidImport, NetplanPattern)
+		}
+	}
+	res, err := ConvertNetplan(files, opts.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if err := s.finishImport(host, res, opts); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
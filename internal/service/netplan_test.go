@@ -0,0 +1,142 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func findFile(res *ImportResult, filename string) map[string]interface{} {
+	for _, f := range res.Files {
+		if f.Filename == filename {
+			return f.Config
+		}
+	}
+	return nil
+}
+
+func TestConvertNetplan(t *testing.T) {
+	files := map[string]string{
+		"01-base.yaml": `
+network:
+  version: 2
+  renderer: networkd
+  ethernets:
+    eno1:
+      dhcp4: no
+    eno2: {}
+    lan:
+      match:
+        macaddress: "00:11:22:33:44:55"
+      set-name: lan0
+      addresses: [192.168.1.10/24]
+      routes:
+        - to: default
+          via: 192.168.1.1
+          metric: 100
+      routing-policy:
+        - from: 192.168.1.0/24
+          table: 10
+      nameservers:
+        addresses: [1.1.1.1, 9.9.9.9]
+        search: [example.com]
+      optional: true
+      wakeonlan: true
+  bonds:
+    bond0:
+      interfaces: [eno1, eno2]
+      parameters:
+        mode: 802.3ad
+        lacp-rate: fast
+        mii-monitor-interval: 100
+        up-delay: 2s
+      dhcp4: yes
+  vlans:
+    vlan10:
+      id: 10
+      link: bond0
+      addresses: [10.0.10.2/24]
+`,
+		// Later files override earlier ones
+		"02-override.yaml": `
+network:
+  bonds:
+    bond0:
+      dhcp6: true
+`,
+	}
+
+	res, err := ConvertNetplan(files, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := findFile(res, "10-lan.link")
+	if link == nil || link["Link"].(map[string]interface{})["Name"] != "lan0" ||
+		link["Match"].(map[string]interface{})["MACAddress"] != "00:11:22:33:44:55" {
+		t.Fatalf("Unexpected .link: %+v", link)
+	}
+	lan := findFile(res, "10-lan.network")
+	if lan == nil {
+		t.Fatalf("Missing 10-lan.network in %+v", res.Files)
+	}
+	if lan["Match"].(map[string]interface{})["Name"] != "lan0" {
+		t.Errorf("Expected renamed link to match on lan0, got %+v", lan["Match"])
+	}
+	network := lan["Network"].(map[string]interface{})
+	if network["Address"] != "192.168.1.10/24" || network["Domains"] != "example.com" {
+		t.Errorf("Unexpected [Network]: %+v", network)
+	}
+	if dns, _ := network["DNS"].([]interface{}); len(dns) != 2 {
+		t.Errorf("Expected two DNS servers, got %+v", network["DNS"])
+	}
+	route := lan["Route"].([]interface{})[0].(map[string]interface{})
+	if route["Destination"] != "0.0.0.0/0" || route["Gateway"] != "192.168.1.1" || route["Metric"] != "100" {
+		t.Errorf("Unexpected route: %+v", route)
+	}
+	rule := lan["RoutingPolicyRule"].([]interface{})[0].(map[string]interface{})
+	if rule["From"] != "192.168.1.0/24" || rule["Table"] != "10" {
+		t.Errorf("Unexpected rule: %+v", rule)
+	}
+	if lan["Link"].(map[string]interface{})["RequiredForOnline"] != "no" {
+		t.Errorf("Expected optional to map to RequiredForOnline=no")
+	}
+
+	bond := findFile(res, "10-bond0.netdev")
+	if bond == nil || bond["Bond"].(map[string]interface{})["LACPTransmitRate"] != "fast" {
+		t.Fatalf("Unexpected bond netdev: %+v", bond)
+	}
+	// Intervals without a unit are milliseconds in netplan, seconds in networkd
+	if params := bond["Bond"].(map[string]interface{}); params["MIIMonitorSec"] != "100ms" || params["UpDelaySec"] != "2s" {
+		t.Errorf("Unexpected bond intervals: %+v", params)
+	}
+	if dhcp := findFile(res, "10-bond0.network")["Network"].(map[string]interface{})["DHCP"]; dhcp != "yes" {
+		t.Errorf("Expected merged DHCP=yes on bond0, got %v", dhcp)
+	}
+	for _, member := range []string{"eno1", "eno2"} {
+		if findFile(res, "10-"+member+".network")["Network"].(map[string]interface{})["Bond"] != "bond0" {
+			t.Errorf("%s not enslaved to bond0", member)
+		}
+	}
+	if findFile(res, "10-bond0.network")["Network"].(map[string]interface{})["VLAN"] != "vlan10" {
+		t.Errorf("Expected VLAN=vlan10 on the parent")
+	}
+	if vlan := findFile(res, "10-vlan10.netdev"); vlan["VLAN"].(map[string]interface{})["Id"] != "10" {
+		t.Errorf("Unexpected VLAN netdev: %+v", vlan)
+	}
+
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "wakeonlan") {
+		t.Errorf("Expected a warning for wakeonlan, got %v", res.Warnings)
+	}
+}
+
+func TestConvertNetplanErrors(t *testing.T) {
+	if _, err := ConvertNetplan(map[string]string{"a.yaml": "network: ["}, ""); err == nil {
+		t.Error("Expected a YAML error")
+	}
+	if _, err := ConvertNetplan(map[string]string{"a.yaml": "network:\n  version: 1\n"}, ""); err == nil {
+		t.Error("Expected version 1 to be rejected")
+	}
+	if _, err := ConvertNetplan(map[string]string{"a.yaml": "network:\n  ethernets:\n    eth0:\n      dhcp4: maybe\n"}, ""); err == nil {
+		t.Error("Expected an invalid boolean to be rejected")
+	}
+}
@@ -1,13 +1,17 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/santhosh-tekuri/jsonschema/v6"
@@ -31,13 +35,34 @@ type NetworkdService struct {
 	DataDir          string
 	Schema           *SchemaService
 
-	LocalConnector   *LocalConnector
-	HostManager      *HostManager
-	RemoteConnectors map[string]*SSHConnector
-	connsMu          sync.Mutex
+	// Permissions and ownership for written config files. Files carrying
+	// key material (WireGuard/MACsec keys) use SecretWriteOptions so that
+	// only root and systemd-networkd can read them.
+	WriteOptions       WriteOptions
+	SecretWriteOptions WriteOptions
+	// Key files referenced via PrivateKeyFile= and friends. networkd reads
+	// them as the systemd-network user, so that user owns them with 0600.
+	KeyFileWriteOptions WriteOptions
+
+	LocalConnector *LocalConnector
+	HostManager    *HostManager
+	IPAM           *IPAMManager
+	// IPAMIgnoreScanErrors lets pool allocations proceed when some managed
+	// hosts cannot be scanned, at the risk of handing out an address used
+	// there (NETWORKD_IPAM_IGNORE_SCAN_ERRORS=true)
+	IPAMIgnoreScanErrors bool
+	Stats                *StatsSampler
+	RemoteConnectors     map[string]*SSHConnector
+	connsMu              sync.Mutex
 }
 
 func NewNetworkdService(configDir, dataDir string) *NetworkdService {
+	return NewNetworkdServiceWithLog(configDir, dataDir, os.Stdout)
+}
+
+// NewNetworkdServiceWithLog is NewNetworkdService with its startup messages
+// written to logOut instead of stdout.
+func NewNetworkdServiceWithLog(configDir, dataDir string, logOut io.Writer) *NetworkdService {
 	// Default values
 	if configDir == "" {
 		configDir = "/etc/systemd/network"
@@ -48,12 +73,12 @@ func NewNetworkdService(configDir, dataDir string) *NetworkdService {
 
 	// Ensure DataDir exists
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		fmt.Printf("Warning: Failed to create DataDir %s: %v\n", dataDir, err)
+		fmt.Fprintf(logOut, "Warning: Failed to create DataDir %s: %v\n", dataDir, err)
 	}
 
 	// Ensure SSH Key
 	if err := ensureSSHKey(dataDir); err != nil {
-		fmt.Printf("Warning: Failed to generate SSH key: %v\n", err)
+		fmt.Fprintf(logOut, "Warning: Failed to generate SSH key: %v\n", err)
 	}
 
 	globalConfigPath := "/etc/systemd/networkd.conf"
@@ -68,7 +93,7 @@ func NewNetworkdService(configDir, dataDir string) *NetworkdService {
 	if runtime.GOOS == "linux" {
 		conn, err = dbus.SystemBus()
 		if err != nil {
-			fmt.Printf("Failed to connect to SystemBus: %v\n", err)
+			fmt.Fprintf(logOut, "Failed to connect to SystemBus: %v\n", err)
 		}
 	}
 
@@ -84,9 +109,9 @@ func NewNetworkdService(configDir, dataDir string) *NetworkdService {
 		schemaBase = filepath.Join(homeDir, "networkd-schema", "schemas")
 	}
 
-	sService, err := NewSchemaService(schemaBase)
+	sService, err := NewSchemaServiceWithLog(schemaBase, logOut)
 	if err != nil {
-		fmt.Printf("Warning: Failed to initialize SchemaService: %v. Validation will be limited.\n", err)
+		fmt.Fprintf(logOut, "Warning: Failed to initialize SchemaService: %v. Validation will be limited.\n", err)
 		// We can still proceed but maybe with empty schemas?
 		sService = &SchemaService{
 			Schemas:            make(map[string]map[string]interface{}),
@@ -95,21 +120,42 @@ func NewNetworkdService(configDir, dataDir string) *NetworkdService {
 			Validators:         make(map[string]*jsonschema.Schema),
 		}
 	} else {
-		fmt.Printf("Initialized SchemaService: Systemd=%s, Schema=%s\n", sService.RealVersion, sService.LoadedVersion)
+		fmt.Fprintf(logOut, "Initialized SchemaService: Systemd=%s, Schema=%s\n", sService.RealVersion, sService.LoadedVersion)
 	}
 
-	localConnector := NewLocalConnector(configDir, conn)
+	localConnector := NewLocalConnector(configDir, globalConfigPath, conn)
 	hostManager, _ := NewHostManager(dataDir) // Ignore error? Log it?
+	ipam, err := NewIPAMManager(dataDir)
+	if err != nil {
+		fmt.Fprintf(logOut, "Warning: Failed to load IPAM pools: %v\n", err)
+	}
 
-	return &NetworkdService{
+	s := &NetworkdService{
 		ConfigDir:        configDir,
 		GlobalConfigPath: globalConfigPath,
 		DataDir:          dataDir,
 		Schema:           sService,
-		LocalConnector:   localConnector,
-		HostManager:      hostManager,
-		RemoteConnectors: make(map[string]*SSHConnector),
-	}
+		WriteOptions:     WriteOptions{Mode: 0644},
+		SecretWriteOptions: WriteOptions{
+			Mode:  0640,
+			Owner: "root",
+			Group: "systemd-network",
+		}.withOwnerFromEnv("NETWORKD_SECRET_OWNER"),
+		KeyFileWriteOptions: WriteOptions{
+			Mode:  0600,
+			Owner: "systemd-network",
+			Group: "systemd-network",
+		}.withOwnerFromEnv("NETWORKD_KEY_FILE_OWNER"),
+		LocalConnector:       localConnector,
+		HostManager:          hostManager,
+		IPAM:                 ipam,
+		IPAMIgnoreScanErrors: os.Getenv("NETWORKD_IPAM_IGNORE_SCAN_ERRORS") == "true",
+		RemoteConnectors:     make(map[string]*SSHConnector),
+	}
+	// Sampling is started by the server, not here, so tests and one-off
+	// uses of the service do not spawn background work.
+	s.Stats = NewStatsSampler(s, 10*time.Second, time.Hour)
+	return s
 }
 
 func (s *NetworkdService) GetConnector(host string) (Connector, error) {
@@ -129,13 +175,27 @@ func (s *NetworkdService) GetConnector(host string) (Connector, error) {
 	if !ok {
 		return nil, fmt.Errorf("unknown host: %s", host)
 	}
+	// hosts.json may predate the key directory or have been edited by hand
+	if err := s.HostManager.CheckKeyFile(cfg); err != nil {
+		return nil, err
+	}
 
-	keyFile := filepath.Join(s.DataDir, "id_rsa")
-	conn := NewSSHConnector(cfg.Host, cfg.Port, cfg.User, keyFile)
+	conn := NewSSHConnector(cfg, s.DataDir)
 	s.RemoteConnectors[host] = conn
 	return conn, nil
 }
 
+// DropConnector closes and forgets the cached connector of a host, so the
+// next request reconnects with its current settings.
+func (s *NetworkdService) DropConnector(host string) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	if conn, ok := s.RemoteConnectors[host]; ok {
+		conn.Close()
+		delete(s.RemoteConnectors, host)
+	}
+}
+
 // ListLinks retrieves runtime links
 func (s *NetworkdService) ListLinks(host string) ([]Link, error) {
 	c, err := s.GetConnector(host)
@@ -230,6 +290,30 @@ func (c *MatchCriteria) matches(matchName interface{}, matchMAC interface{}, mat
 	return true
 }
 
+// configSuffixes maps each networkd config type to its file suffix.
+var configSuffixes = map[string]string{
+	"network": ".network",
+	"netdev":  ".netdev",
+	"link":    ".link",
+}
+
+// ConfigSuffix returns the file suffix for a config type (e.g. "netdev" -> ".netdev").
+func ConfigSuffix(configType string) (string, bool) {
+	suffix, ok := configSuffixes[configType]
+	return suffix, ok
+}
+
+// ConfigTypeForFilename infers the config type from a filename's suffix.
+// Files that are not .network, .netdev or .link are not networkd configs.
+func ConfigTypeForFilename(filename string) (string, bool) {
+	for configType, suffix := range configSuffixes {
+		if strings.HasSuffix(filename, suffix) && len(filename) > len(suffix) {
+			return configType, true
+		}
+	}
+	return "", false
+}
+
 // List methods
 func (s *NetworkdService) ListNetDevs(host string) ([]FileInfo, error) {
 	return s.listFiles(host, ".netdev", nil)
@@ -254,16 +338,18 @@ func (s *NetworkdService) listFiles(host, suffix string, criteria *MatchCriteria
 		return nil, fmt.Errorf("failed to read config dir: %w", err)
 	}
 
-	configType := "network"
-	if suffix == ".netdev" {
-		configType = "netdev"
+	configType := ""
+	for t, sfx := range configSuffixes {
+		if sfx == suffix {
+			configType = t
+		}
 	}
-	if suffix == ".link" {
-		configType = "link"
+	if configType == "" {
+		return nil, fmt.Errorf("unsupported config suffix: %s", suffix)
 	}
 
 	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), suffix) {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), suffix) && len(entry.Name()) > len(suffix) {
 			info := FileInfo{Filename: entry.Name(), Type: configType}
 			content, err := s.ReadNetworkFile(host, entry.Name())
 			if err == nil {
@@ -323,7 +409,9 @@ func (s *NetworkdService) listFiles(host, suffix string, criteria *MatchCriteria
 	return files, nil
 }
 
-// validateFilename ensures the filename is a flat name with no path components.
+// validateFilename ensures the filename is a flat name with no path components
+// and carries a networkd suffix, so that other files in the config directory
+// (editor backups, .conf files, ...) cannot be reached through the API.
 func validateFilename(filename string) error {
 	if filename == "" {
 		return fmt.Errorf("empty filename")
@@ -332,6 +420,119 @@ func validateFilename(filename string) error {
 	if cleaned != filename || filepath.IsAbs(filename) || strings.ContainsAny(filename, "/\\") {
 		return fmt.Errorf("invalid filename: %q", filename)
 	}
+	if _, ok := ConfigTypeForFilename(filename); !ok {
+		return fmt.Errorf("not a networkd config file: %q", filename)
+	}
+	return nil
+}
+
+// validateDropinPath checks a drop-in path of the form
+// "10-eth0.network.d/50-mtu.conf" and returns the config type it extends.
+func validateDropinPath(path string) (string, error) {
+	dir, name, ok := strings.Cut(path, "/")
+	parent, isDropinDir := strings.CutSuffix(dir, ".d")
+	if !ok || !isDropinDir || validateFilename(parent) != nil {
+		return "", fmt.Errorf("invalid drop-in path: %q", path)
+	}
+	if name == "" || strings.ContainsAny(name, "/\\") || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".conf") {
+		return "", fmt.Errorf("invalid drop-in path: %q", path)
+	}
+	configType, _ := ConfigTypeForFilename(parent)
+	return configType, nil
+}
+
+// readConfigPath, writeConfigPath and deleteConfigPath accept config file
+// names, drop-in paths and key files.
+func (s *NetworkdService) readConfigPath(host, path string) (string, error) {
+	if isKeyFilename(path) {
+		if err := validateKeyFilename(path); err != nil {
+			return "", err
+		}
+	} else if !strings.Contains(path, "/") {
+		return s.ReadNetworkFile(host, path)
+	} else if _, err := validateDropinPath(path); err != nil {
+		return "", err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return "", err
+	}
+	content, err := c.ReadConfigFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (s *NetworkdService) writeConfigPath(host, path, content string) error {
+	if isKeyFilename(path) {
+		return s.WriteKeyFile(host, path, content)
+	}
+	if !strings.Contains(path, "/") {
+		return s.WriteNetworkFile(host, path, content)
+	}
+	configType, err := validateDropinPath(path)
+	if err != nil {
+		return err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return err
+	}
+	opts := s.WriteOptions
+	if cfg, err := INIToMap(content, s.Schema, configType); err == nil && HasSecrets(cfg, s.Schema, configType) {
+		opts = s.SecretWriteOptions
+	}
+	return c.WriteConfigFile(path, []byte(content), opts)
+}
+
+func (s *NetworkdService) deleteConfigPath(host, path string) error {
+	if isKeyFilename(path) {
+		if err := validateKeyFilename(path); err != nil {
+			return err
+		}
+	} else if !strings.Contains(path, "/") {
+		return s.DeleteNetworkFile(host, path)
+	} else if _, err := validateDropinPath(path); err != nil {
+		return err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return err
+	}
+	return c.DeleteConfigFile(path)
+}
+
+// configSnapshot records a config path's content before a multi-step change,
+// so the change can be rolled back.
+type configSnapshot struct {
+	path    string
+	content string
+	existed bool
+}
+
+// snapshotConfigPath reads path for a later restoreConfigPath. Only a missing
+// file counts as absent; other read errors are returned, because restoring
+// over a file that could not be read would lose it.
+func (s *NetworkdService) snapshotConfigPath(host, path string) (configSnapshot, error) {
+	content, err := s.readConfigPath(host, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return configSnapshot{path: path}, nil
+	}
+	if err != nil {
+		return configSnapshot{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return configSnapshot{path: path, content: content, existed: true}, nil
+}
+
+// restoreConfigPath puts a snapshot back, removing files that did not exist.
+func (s *NetworkdService) restoreConfigPath(host string, snap configSnapshot) error {
+	if snap.existed {
+		return s.writeConfigPath(host, snap.path, snap.content)
+	}
+	if err := s.deleteConfigPath(host, snap.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
 	return nil
 }
 
@@ -358,7 +559,57 @@ func (s *NetworkdService) WriteNetworkFile(host, filename string, content string
 	if err != nil {
 		return err
 	}
-	return c.WriteConfigFile(filename, []byte(content))
+	opts := s.WriteOptions
+	if configType, ok := ConfigTypeForFilename(filename); ok {
+		if cfg, err := INIToMap(content, s.Schema, configType); err == nil && HasSecrets(cfg, s.Schema, configType) {
+			opts = s.SecretWriteOptions
+		}
+	}
+	return c.WriteConfigFile(filename, []byte(content), opts)
+}
+
+func isKeyFilename(filename string) bool {
+	return strings.HasSuffix(filename, ".key")
+}
+
+func validateKeyFilename(filename string) error {
+	if filename == "" || filepath.Base(filename) != filename || strings.HasPrefix(filename, ".") || !isKeyFilename(filename) {
+		return fmt.Errorf("invalid key filename: %q", filename)
+	}
+	return nil
+}
+
+// GetConfigDir returns the config directory of the given host.
+func (s *NetworkdService) GetConfigDir(host string) (string, error) {
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return "", err
+	}
+	return c.GetConfigDir(), nil
+}
+
+// WriteKeyFile writes a secret referenced by PrivateKeyFile= (or similar)
+// into the config directory. Key files must end in ".key" so they can never
+// be mistaken for, or overwrite, a networkd config.
+func (s *NetworkdService) WriteKeyFile(host, filename, content string) error {
+	if err := validateKeyFilename(filename); err != nil {
+		return err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return err
+	}
+	return c.WriteConfigFile(filename, []byte(content), s.KeyFileWriteOptions)
+}
+
+// DeleteConfig removes a config file and releases the pool addresses
+// allocated for it.
+func (s *NetworkdService) DeleteConfig(host, filename string) error {
+	if err := s.DeleteNetworkFile(host, filename); err != nil {
+		return err
+	}
+	s.releaseStaleAllocations(host, []fileChange{{filename: filename, delete: true}})
+	return nil
 }
 
 func (s *NetworkdService) DeleteNetworkFile(host, filename string) error {
@@ -460,11 +711,3 @@ func (s *NetworkdService) GetRules(host string) (string, error) {
 	}
 	return c.GetRules()
 }
-
-func (s *NetworkdService) GetLogs(host string) (string, error) {
-	c, err := s.GetConnector(host)
-	if err != nil {
-		return "", err
-	}
-	return c.GetLogs()
-}
@@ -0,0 +1,592 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// NetworkManagerPattern is where NetworkManager keeps its keyfile profiles.
+const NetworkManagerPattern = "/etc/NetworkManager/system-connections/*.nmconnection"
+
+// nmConnection is one parsed keyfile profile.
+type nmConnection struct {
+	path  string
+	file  *ini.File
+	id    string
+	uuid  string
+	typ   string
+	iface string
+}
+
+func (c *nmConnection) get(section, key string) string {
+	if !c.file.HasSection(section) {
+		return ""
+	}
+	return strings.TrimSpace(c.file.Section(section).Key(key).String())
+}
+
+// nmTypes maps the long setting names older keyfiles use to the short ones.
+var nmTypes = map[string]string{
+	"802-3-ethernet":  "ethernet",
+	"802-11-wireless": "wifi",
+}
+
+func parseNMConnection(path, content string) (*nmConnection, error) {
+	f, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, []byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c := &nmConnection{path: path, file: f}
+	c.id = c.get("connection", "id")
+	c.uuid = c.get("connection", "uuid")
+	c.typ = c.get("connection", "type")
+	if t, ok := nmTypes[c.typ]; ok {
+		c.typ = t
+	}
+	c.iface = c.get("connection", "interface-name")
+	if c.typ == "" {
+		return nil, fmt.Errorf("%s: connection.type is missing", path)
+	}
+	return c, nil
+}
+
+// nmList splits NetworkManager's semicolon-separated lists.
+func nmList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func nmBool(v string) bool {
+	return v == "true" || v == "yes" || v == "1"
+}
+
+var nonFileNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+type nmNumberedValue struct {
+	n     int
+	value string
+}
+
+// nmNumbered returns the values of key1, key2, ... in numeric order.
+func nmNumbered(sec *ini.Section, prefix string) []nmNumberedValue {
+	var values []nmNumberedValue
+	for _, k := range sec.Keys() {
+		n, err := strconv.Atoi(strings.TrimPrefix(k.Name(), prefix))
+		if err != nil || !strings.HasPrefix(k.Name(), prefix) {
+			continue
+		}
+		values = append(values, nmNumberedValue{n, strings.TrimSpace(k.String())})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].n < values[j].n })
+	return values
+}
+
+// ConvertNetworkManager converts NetworkManager keyfiles (path -> content)
+// into networkd files.
+func ConvertNetworkManager(files map[string]string, prefix string) (*ImportResult, error) {
+	b := newImportBuilder(prefix)
+
+	var conns []*nmConnection
+	for _, path := range sortedKeys(files) {
+		c, err := parseNMConnection(path, files[path])
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, c)
+	}
+
+	// Controllers and VLAN parents may be referenced by UUID, profile name
+	// or interface name
+	names := map[string]string{}
+	for _, c := range conns {
+		if c.iface != "" {
+			names[c.uuid] = c.iface
+			names[c.id] = c.iface
+			names[c.iface] = c.iface
+		}
+	}
+	resolve := func(ref string) string {
+		if name, ok := names[ref]; ok {
+			return name
+		}
+		return ref
+	}
+
+	for _, c := range conns {
+		where := fmt.Sprintf("%s (%s)", filepath.Base(c.path), c.id)
+		switch c.typ {
+		case "loopback":
+			continue
+		case "ethernet", "wifi", "vlan", "bond", "bridge", "wireguard":
+		default:
+			b.warn("%s: connection type %q is not supported", where, c.typ)
+			continue
+		}
+
+		name := c.iface
+		if name == "" && c.typ == "vlan" {
+			if parent := resolve(c.get("vlan", "parent")); parent != "" && c.get("vlan", "id") != "" {
+				name = parent + "." + c.get("vlan", "id")
+			}
+		}
+		var match map[string]interface{}
+		if name == "" && (c.typ == "ethernet" || c.typ == "wifi") && c.get(c.typ, "mac-address") != "" {
+			// Bound to a device by MAC only: name the files after the profile
+			name = strings.Trim(nonFileNameChars.ReplaceAllString(c.id, "-"), "-")
+			match = map[string]interface{}{"MACAddress": c.get(c.typ, "mac-address")}
+		}
+		if name == "" {
+			b.warn("%s: no interface-name or mac-address; profiles matching any device are not converted", where)
+			continue
+		}
+		if err := ValidateInterfaceName(name); err != nil {
+			return nil, fmt.Errorf("%s: interface %q cannot be used as a file name: %w", where, name, err)
+		}
+
+		b.nmDevice(c, name, where, resolve)
+		cfg := b.networkFile(name).Config
+		if match != nil {
+			cfg["Match"] = match
+		}
+		b.nmController(c, name, where, resolve)
+		b.nmIP(c, name, where, "ipv4")
+		b.nmIP(c, name, where, "ipv6")
+		if c.get("connection", "autoconnect") == "false" {
+			section(cfg, "Link")["ActivationPolicy"] = "manual"
+		}
+		for _, key := range []string{"zone", "permissions"} {
+			if c.get("connection", key) != "" {
+				b.warn("%s: connection.%s is not supported", where, key)
+			}
+		}
+		if len(section(cfg, "Network")) == 0 {
+			delete(cfg, "Network")
+		}
+		for _, sec := range c.file.Sections() {
+			if !nmHandledSection(sec.Name()) && len(sec.Keys()) > 0 {
+				b.warn("%s: [%s] settings are not supported", where, sec.Name())
+			}
+		}
+	}
+	return b.result(), nil
+}
+
+func nmHandledSection(name string) bool {
+	switch name {
+	case ini.DefaultSection, "connection", "ethernet", "wifi", "wifi-security", "vlan", "bond", "bridge",
+		"bridge-port", "wireguard", "ipv4", "ipv6", "proxy":
+		return true
+	}
+	return strings.HasPrefix(name, "wireguard-peer.")
+}
+
+var nmBondOptions = map[string]string{
+	"mode":              "Mode",
+	"miimon":            "MIIMonitorSec",
+	"updelay":           "UpDelaySec",
+	"downdelay":         "DownDelaySec",
+	"lacp_rate":         "LACPTransmitRate",
+	"xmit_hash_policy":  "TransmitHashPolicy",
+	"ad_select":         "AdSelect",
+	"min_links":         "MinLinks",
+	"arp_interval":      "ARPIntervalSec",
+	"arp_ip_target":     "ARPIPTargets",
+	"arp_validate":      "ARPValidate",
+	"arp_all_targets":   "ARPAllTargets",
+	"fail_over_mac":     "FailOverMACPolicy",
+	"primary_reselect":  "PrimaryReselectPolicy",
+	"num_grat_arp":      "GratuitousARP",
+	"all_slaves_active": "AllSlavesActive",
+}
+
+var nmBridgeOptions = map[string]string{
+	"stp":                "STP",
+	"priority":           "Priority",
+	"forward-delay":      "ForwardDelaySec",
+	"hello-time":         "HelloTimeSec",
+	"max-age":            "MaxAgeSec",
+	"ageing-time":        "AgeingTimeSec",
+	"vlan-filtering":     "VLANFiltering",
+	"multicast-snooping": "MulticastSnooping",
+	"mac-address":        "MACAddress",
+}
+
+// nmDevice converts the type-specific settings.
+func (b *importBuilder) nmDevice(c *nmConnection, name, where string, resolve func(string) string) {
+	switch c.typ {
+	case "ethernet", "wifi":
+		if c.typ == "wifi" {
+			b.warn("%s: wireless settings are not managed by networkd; configure wpa_supplicant or iwd separately", where)
+		}
+		cfg := b.networkFile(name).Config
+		for _, k := range c.file.Section(c.typ).Keys() {
+			switch v := strings.TrimSpace(k.String()); k.Name() {
+			case "mtu":
+				if v != "0" && v != "auto" {
+					section(cfg, "Link")["MTUBytes"] = v
+				}
+			case "cloned-mac-address":
+				if _, err := net.ParseMAC(v); err == nil {
+					section(cfg, "Link")["MACAddress"] = v
+				} else {
+					b.warn("%s: %s.cloned-mac-address=%s has no networkd equivalent in .network; use MACAddressPolicy= in a .link file", where, c.typ, v)
+				}
+			case "mac-address", "ssid", "mode":
+			default:
+				if c.typ == "ethernet" {
+					b.warn("%s: ethernet.%s is not supported (use a .link file)", where, k.Name())
+				}
+			}
+		}
+	case "vlan":
+		cfg := b.netdev(name, "vlan")
+		cfg["VLAN"] = map[string]interface{}{"Id": c.get("vlan", "id")}
+		parent := resolve(c.get("vlan", "parent"))
+		if parent == "" {
+			b.warn("%s: VLAN has no parent; add VLAN=%s to the parent's .network", where, name)
+		} else {
+			addValue(section(b.networkFile(parent).Config, "Network"), "VLAN", name)
+		}
+		for _, k := range []string{"flags", "ingress-priority-map", "egress-priority-map"} {
+			if c.get("vlan", k) != "" {
+				b.warn("%s: vlan.%s is not supported", where, k)
+			}
+		}
+	case "bond":
+		cfg := b.netdev(name, "bond")
+		bond := map[string]interface{}{}
+		for _, k := range c.file.Section("bond").Keys() {
+			v := strings.TrimSpace(k.String())
+			switch key, ok := nmBondOptions[k.Name()]; {
+			case ok:
+				switch k.Name() {
+				case "miimon", "updelay", "downdelay", "arp_interval":
+					v += "ms"
+				case "arp_ip_target":
+					v = strings.Join(strings.Split(v, ","), " ")
+				case "lacp_rate":
+					if v == "0" || v == "1" {
+						v = map[string]string{"0": "slow", "1": "fast"}[v]
+					}
+				}
+				bond[key] = v
+			case k.Name() == "primary":
+				section(b.networkFile(resolve(v)).Config, "Network")["PrimarySlave"] = "yes"
+			default:
+				b.warn("%s: bond option %q is not supported", where, k.Name())
+			}
+		}
+		if len(bond) > 0 {
+			cfg["Bond"] = bond
+		}
+	case "bridge":
+		cfg := b.netdev(name, "bridge")
+		bridge := map[string]interface{}{}
+		for _, k := range c.file.Section("bridge").Keys() {
+			if key, ok := nmBridgeOptions[k.Name()]; ok {
+				bridge[key] = strings.TrimSpace(k.String())
+			} else {
+				b.warn("%s: bridge.%s is not supported", where, k.Name())
+			}
+		}
+		if mac, ok := bridge["MACAddress"]; ok {
+			delete(bridge, "MACAddress")
+			section(cfg, "NetDev")["MACAddress"] = mac
+		}
+		if len(bridge) > 0 {
+			cfg["Bridge"] = bridge
+		}
+	case "wireguard":
+		cfg := b.netdev(name, "wireguard")
+		wg := map[string]interface{}{}
+		if key := c.get("wireguard", "private-key"); key != "" {
+			wg["PrivateKey"] = key
+		} else {
+			b.warn("%s: the private key is not stored in the profile (agent-owned secret); set PrivateKey= or PrivateKeyFile= before applying", where)
+		}
+		if port := c.get("wireguard", "listen-port"); port != "" && port != "0" {
+			wg["ListenPort"] = port
+		}
+		if mark := c.get("wireguard", "fwmark"); mark != "" && mark != "0" {
+			wg["FirewallMark"] = mark
+		}
+		if mtu := c.get("wireguard", "mtu"); mtu != "" && mtu != "0" {
+			section(cfg, "NetDev")["MTUBytes"] = mtu
+		}
+		cfg["WireGuard"] = wg
+		for _, sec := range c.file.Sections() {
+			publicKey, ok := strings.CutPrefix(sec.Name(), "wireguard-peer.")
+			if !ok {
+				continue
+			}
+			peer := map[string]interface{}{"PublicKey": publicKey}
+			for _, k := range sec.Keys() {
+				v := strings.TrimSpace(k.String())
+				switch k.Name() {
+				case "endpoint":
+					peer["Endpoint"] = v
+				case "allowed-ips":
+					peer["AllowedIPs"] = strings.Join(nmList(v), ",")
+				case "persistent-keepalive":
+					peer["PersistentKeepalive"] = v
+				case "preshared-key":
+					peer["PresharedKey"] = v
+				case "preshared-key-flags":
+				default:
+					b.warn("%s: wireguard peer %s: %q is not supported", where, publicKey, k.Name())
+				}
+			}
+			appendSectionItem(cfg, "WireGuardPeer", peer)
+		}
+	}
+}
+
+// nmController attaches a port to its bond or bridge.
+func (b *importBuilder) nmController(c *nmConnection, name, where string, resolve func(string) string) {
+	controller := c.get("connection", "controller")
+	if controller == "" {
+		controller = c.get("connection", "master")
+	}
+	if controller == "" {
+		return
+	}
+	portType := c.get("connection", "port-type")
+	if portType == "" {
+		portType = c.get("connection", "slave-type")
+	}
+	network := section(b.networkFile(name).Config, "Network")
+	switch portType {
+	case "bond":
+		network["Bond"] = resolve(controller)
+	case "bridge":
+		network["Bridge"] = resolve(controller)
+		port := map[string]interface{}{}
+		if v := c.get("bridge-port", "priority"); v != "" {
+			port["Priority"] = v
+		}
+		if v := c.get("bridge-port", "path-cost"); v != "" {
+			port["Cost"] = v
+		}
+		if v := c.get("bridge-port", "hairpin-mode"); v != "" {
+			port["HairPin"] = boolString(nmBool(v))
+		}
+		if len(port) > 0 {
+			b.networkFile(name).Config["Bridge"] = port
+		}
+	default:
+		b.warn("%s: port type %q is not supported", where, portType)
+	}
+}
+
+// nmRouteOptions maps keys of routeN_options to [Route] keys.
+var nmRouteOptions = map[string]string{
+	"table":  "Table",
+	"onlink": "GatewayOnLink",
+	"src":    "PreferredSource",
+	"mtu":    "MTUBytes",
+	"scope":  "Scope",
+	"type":   "Type",
+}
+
+// nmIP converts the [ipv4] or [ipv6] setting.
+func (b *importBuilder) nmIP(c *nmConnection, name, where, family string) {
+	if !c.file.HasSection(family) {
+		return
+	}
+	cfg := b.networkFile(name).Config
+	network := section(cfg, "Network")
+	sec := c.file.Section(family)
+	v6 := family == "ipv6"
+	dhcpSection := "DHCPv4"
+	if v6 {
+		dhcpSection = "DHCPv6"
+	}
+
+	switch method := c.get(family, "method"); method {
+	case "auto":
+		if v6 {
+			network["IPv6AcceptRA"] = "yes"
+		} else {
+			addDHCP(network, "ipv4")
+		}
+	case "dhcp":
+		addDHCP(network, family)
+	case "manual", "", "disabled", "ignore":
+		if method == "disabled" && v6 {
+			network["LinkLocalAddressing"] = "ipv4"
+			network["IPv6AcceptRA"] = "no"
+		}
+	case "link-local":
+		if !v6 {
+			network["LinkLocalAddressing"] = "ipv4"
+		}
+	case "shared":
+		if v6 {
+			network["IPv6SendRA"] = "yes"
+		} else {
+			network["DHCPServer"] = "yes"
+		}
+		network["IPMasquerade"] = family
+		b.warn("%s: %s.method=shared approximated with a DHCP server/RA and masquerading", where, family)
+	default:
+		b.warn("%s: %s.method=%s is not supported", where, family, method)
+	}
+
+	var addresses []string
+	for _, a := range nmNumbered(sec, "address") {
+		addresses = append(addresses, a.value)
+	}
+	if legacy := c.get(family, "addresses"); legacy != "" {
+		addresses = append(addresses, nmList(legacy)...)
+	}
+	for _, a := range addresses {
+		addr, gw, _ := strings.Cut(a, ",")
+		addValue(network, "Address", addr)
+		if gw != "" {
+			addValue(network, "Gateway", gw)
+		}
+	}
+	if gw := c.get(family, "gateway"); gw != "" {
+		addValue(network, "Gateway", gw)
+	}
+	if dns := nmList(c.get(family, "dns")); len(dns) > 0 {
+		addValue(network, "DNS", dns...)
+	}
+	if search := nmList(c.get(family, "dns-search")); len(search) > 0 {
+		domains := strings.Join(search, " ")
+		if cur, ok := network["Domains"].(string); ok && cur != domains {
+			domains = cur + " " + domains
+		}
+		network["Domains"] = domains
+	}
+	if nmBool(c.get(family, "ignore-auto-dns")) {
+		section(cfg, dhcpSection)["UseDNS"] = "no"
+	}
+	if nmBool(c.get(family, "never-default")) {
+		if v6 {
+			section(cfg, "IPv6AcceptRA")["UseGateway"] = "no"
+		} else {
+			section(cfg, dhcpSection)["UseGateway"] = "no"
+		}
+	}
+	if metric := c.get(family, "route-metric"); metric != "" && metric != "-1" {
+		section(cfg, dhcpSection)["RouteMetric"] = metric
+	}
+
+	if table := c.get(family, "route-table"); table != "" && table != "0" {
+		if v6 {
+			section(cfg, "IPv6AcceptRA")["RouteTable"] = table
+		} else {
+			section(cfg, dhcpSection)["RouteTable"] = table
+		}
+	}
+
+	for _, r := range nmNumbered(sec, "route") {
+		parts := strings.Split(r.value, ",")
+		route := map[string]interface{}{"Destination": parts[0]}
+		if len(parts) > 1 && parts[1] != "" {
+			if addr, err := netip.ParseAddr(parts[1]); err == nil && !addr.IsUnspecified() {
+				route["Gateway"] = parts[1]
+			}
+		}
+		if len(parts) > 2 && parts[2] != "" {
+			route["Metric"] = parts[2]
+		}
+		if opts := c.get(family, fmt.Sprintf("route%d_options", r.n)); opts != "" {
+			for _, opt := range strings.Split(opts, ",") {
+				k, v, _ := strings.Cut(opt, "=")
+				if key, ok := nmRouteOptions[k]; ok {
+					if key == "GatewayOnLink" {
+						v = boolString(nmBool(v))
+					}
+					route[key] = v
+				} else {
+					b.warn("%s: %s route option %q is not supported", where, family, k)
+				}
+			}
+		}
+		appendSectionItem(cfg, "Route", route)
+	}
+	for _, r := range nmNumbered(sec, "routing-rule") {
+		rule := r.value
+		fields := strings.Fields(rule)
+		if len(fields) > 0 && fields[0] == "not" {
+			b.warn("%s: negated routing rule %q is not supported", where, rule)
+			continue
+		}
+		item := map[string]interface{}{}
+		ok := true
+		for j := 0; j+1 < len(fields) && ok; j += 2 {
+			key, known := ipRuleKeys[fields[j]]
+			if !known {
+				ok = false
+				break
+			}
+			item[key] = fields[j+1]
+		}
+		if !ok || len(fields)%2 != 0 {
+			b.warn("%s: routing rule %q not converted", where, rule)
+			continue
+		}
+		appendSectionItem(cfg, "RoutingPolicyRule", item)
+	}
+
+	for _, k := range sec.Keys() {
+		key := k.Name()
+		switch {
+		case strings.HasPrefix(key, "address"), strings.HasPrefix(key, "route"), strings.HasPrefix(key, "routing-rule"):
+		case key == "method" || key == "gateway" || key == "dns" || key == "dns-search" || key == "ignore-auto-dns" ||
+			key == "never-default" || key == "may-fail" || key == "dns-priority" || key == "addr-gen-mode":
+		default:
+			b.warn("%s: %s.%s is not supported", where, family, key)
+		}
+	}
+}
+
+// addDHCP enables DHCP for family on top of what is already enabled.
+func addDHCP(network map[string]interface{}, family string) {
+	cur, _ := network["DHCP"].(string)
+	if (cur == "ipv4" && family == "ipv6") || (cur == "ipv6" && family == "ipv4") || cur == "yes" {
+		network["DHCP"] = "yes"
+	} else {
+		network["DHCP"] = family
+	}
+}
+
+// ImportNetworkManager converts a host's NetworkManager keyfiles (or the
+// uploaded ones), validates the result and, with apply, writes it.
+func (s *NetworkdService) ImportNetworkManager(host string, files map[string]string, opts ImportOptions) (*ImportResult, error) {
+	if len(files) == 0 {
+		src, err := s.hostImportSource(host)
+		if err != nil {
+			return nil, err
+		}
+		if files, err = src.readAll(NetworkManagerPattern); err != nil {
+			return nil, fmt.Errorf("failed to read NetworkManager profiles: %w", err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("%w: no files match %s", ErrInvalidImport, NetworkManagerPattern)
+		}
+	}
+	res, err := ConvertNetworkManager(files, opts.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if err := s.finishImport(host, res, opts); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertNetworkManager(t *testing.T) {
+	dir := "/etc/NetworkManager/system-connections/"
+	files := map[string]string{
+		dir + "bond0.nmconnection": `[connection]
+id=bond0
+uuid=11111111-1111-1111-1111-111111111111
+type=bond
+interface-name=bond0
+
+[bond]
+mode=802.3ad
+miimon=100
+lacp_rate=1
+
+[ipv4]
+method=manual
+address1=192.168.1.10/24,192.168.1.1
+dns=1.1.1.1;9.9.9.9;
+dns-search=example.com;
+route1=10.0.0.0/8,192.168.1.254,50
+route1_options=table=10
+routing-rule1=priority 100 from 192.168.1.0/24 table 10
+
+[ipv6]
+method=auto
+`,
+		dir + "Wired connection 1.nmconnection": `[connection]
+id=Wired connection 1
+type=802-3-ethernet
+interface-name=eno1
+master=11111111-1111-1111-1111-111111111111
+slave-type=bond
+
+[ethernet]
+mtu=9000
+`,
+		dir + "vlan20.nmconnection": `[connection]
+id=vlan20
+type=vlan
+autoconnect=false
+
+[vlan]
+id=20
+parent=bond0
+
+[ipv4]
+method=auto
+ignore-auto-dns=true
+`,
+		dir + "wg0.nmconnection": `[connection]
+id=wg0
+type=wireguard
+interface-name=wg0
+
+[wireguard]
+private-key=cHJpdmF0ZWtleXByaXZhdGVrZXlwcml2YXRla2V5MDA=
+listen-port=51820
+
+[wireguard-peer.cHVibGlja2V5cHVibGlja2V5cHVibGlja2V5cHViMDA=]
+endpoint=198.51.100.1:51820
+allowed-ips=10.99.0.0/24;fd00::/64;
+
+[ipv4]
+method=manual
+address1=10.99.0.2/24
+`,
+		dir + "office.nmconnection": `[connection]
+id=office
+type=vpn
+
+[vpn]
+service-type=org.freedesktop.NetworkManager.openvpn
+`,
+	}
+
+	res, err := ConvertNetworkManager(files, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bond := findFile(res, "10-bond0.netdev")["Bond"].(map[string]interface{})
+	if bond["Mode"] != "802.3ad" || bond["MIIMonitorSec"] != "100ms" || bond["LACPTransmitRate"] != "fast" {
+		t.Errorf("Unexpected [Bond]: %+v", bond)
+	}
+	eno1 := findFile(res, "10-eno1.network")
+	if eno1["Network"].(map[string]interface{})["Bond"] != "bond0" || eno1["Link"].(map[string]interface{})["MTUBytes"] != "9000" {
+		t.Errorf("Unexpected member: %+v", eno1)
+	}
+
+	net := findFile(res, "10-bond0.network")
+	network := net["Network"].(map[string]interface{})
+	if network["Address"] != "192.168.1.10/24" || network["Gateway"] != "192.168.1.1" || network["IPv6AcceptRA"] != "yes" {
+		t.Errorf("Unexpected [Network]: %+v", network)
+	}
+	if network["Domains"] != "example.com" || network["VLAN"] != "bond0.20" {
+		t.Errorf("Unexpected [Network]: %+v", network)
+	}
+	route := net["Route"].([]interface{})[0].(map[string]interface{})
+	if route["Destination"] != "10.0.0.0/8" || route["Table"] != "10" || route["Metric"] != "50" {
+		t.Errorf("Unexpected route: %+v", route)
+	}
+	if rule := net["RoutingPolicyRule"].([]interface{})[0].(map[string]interface{}); rule["Priority"] != "100" {
+		t.Errorf("Unexpected rule: %+v", rule)
+	}
+
+	vlan := findFile(res, "10-bond0.20.network")
+	if vlan["Network"].(map[string]interface{})["DHCP"] != "ipv4" || vlan["DHCPv4"].(map[string]interface{})["UseDNS"] != "no" {
+		t.Errorf("Unexpected VLAN network: %+v", vlan)
+	}
+	if vlan["Link"].(map[string]interface{})["ActivationPolicy"] != "manual" {
+		t.Error("autoconnect=false should map to ActivationPolicy=manual")
+	}
+
+	wg := findFile(res, "10-wg0.netdev")
+	peer := wg["WireGuardPeer"].([]interface{})[0].(map[string]interface{})
+	if peer["AllowedIPs"] != "10.99.0.0/24,fd00::/64" || wg["WireGuard"].(map[string]interface{})["ListenPort"] != "51820" {
+		t.Errorf("Unexpected WireGuard netdev: %+v", wg)
+	}
+
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], `"vpn"`) {
+		t.Errorf("Expected only the VPN profile to be reported, got %v", res.Warnings)
+	}
+}
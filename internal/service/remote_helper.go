@@ -0,0 +1,12 @@
+package service
+
+import _ "embed"
+
+// RemoteHelperPath is where scripts/setup-remote-host.sh installs the helper.
+const RemoteHelperPath = "/usr/local/libexec/networkd-api-helper"
+
+// RemoteHelperScript performs privileged file access on remote hosts. It is
+// served at /api/system/remote-helper for the setup script to install.
+//
+//go:embed remote_helper.sh
+var RemoteHelperScript string
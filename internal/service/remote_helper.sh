@@ -0,0 +1,158 @@
+#!/bin/sh
+# networkd-api-helper: file access on behalf of networkd-api.
+#
+# This is the only file command the API user may run through sudo/doas.
+# Paths are canonicalised and must lie inside the networkd configuration, so
+# a caller cannot reach other files with "..", symlinks or extra arguments.
+# The configuration of ifupdown, NetworkManager and netplan may also be read
+# and listed, for importing it.
+#
+# Usage:
+#   networkd-api-helper read PATH
+#   networkd-api-helper write PATH MODE OWNER|- SHA256|-   (content on stdin)
+#   networkd-api-helper remove PATH [-f]
+#   networkd-api-helper glob DIR PATTERN
+#   networkd-api-helper mkdir DIR
+#
+# read and remove exit with status 2 when PATH does not exist. glob prints the
+# regular files in DIR whose name matches PATTERN, nothing if DIR is missing.
+# mkdir only creates drop-in directories (*.d), world-readable like networkd's.
+set -eu
+umask 077
+
+NETWORK_DIR=/etc/systemd/network
+GLOBAL_CONFIG=/etc/systemd/networkd.conf
+# Hosts with a custom config_dir or global_config_path set these here
+if [ -r /etc/networkd-api/helper.conf ]; then
+	. /etc/networkd-api/helper.conf
+fi
+# Without sudo/doas the caller already has these permissions, so
+# networkd-api may pass the host's paths in the environment.
+if [ -z "${SUDO_USER:-}${DOAS_USER:-}" ]; then
+	NETWORK_DIR=${HELPER_NETWORK_DIR:-$NETWORK_DIR}
+	GLOBAL_CONFIG=${HELPER_GLOBAL_CONFIG:-$GLOBAL_CONFIG}
+fi
+
+die() {
+	echo "networkd-api-helper: $*" >&2
+	exit 1
+}
+
+missing() {
+	echo "networkd-api-helper: no such file: $1" >&2
+	exit 2
+}
+
+canon() {
+	realpath -m -- "$1"
+}
+
+# writable prints the canonical PATH if networkd-api may write it.
+writable() {
+	p=$(canon "$1")
+	net=$(canon "$NETWORK_DIR")
+	global=$(canon "$GLOBAL_CONFIG")
+	case "$p" in
+	"$net"/* | "$global" | "$global.d"/*) printf '%s\n' "$p" ;;
+	*) die "refusing path outside the networkd configuration: $1" ;;
+	esac
+}
+
+# readable prints the canonical PATH if networkd-api may read it.
+readable() {
+	p=$(canon "$1")
+	case "$p" in
+	/etc/network/interfaces | /etc/network/interfaces.d/* | \
+		/etc/NetworkManager/system-connections/* | /etc/netplan/*)
+		printf '%s\n' "$p"
+		;;
+	*) writable "$1" ;;
+	esac
+}
+
+[ $# -ge 2 ] || die "usage: $0 read|write|remove|glob|mkdir PATH ..."
+op=$1
+path=$2
+
+case "$op" in
+read)
+	[ $# -eq 2 ] || die "usage: $0 read PATH"
+	p=$(readable "$path")
+	[ -e "$p" ] || missing "$path"
+	exec cat -- "$p"
+	;;
+write)
+	[ $# -eq 5 ] || die "usage: $0 write PATH MODE OWNER|- SHA256|-"
+	p=$(writable "$path")
+	mode=$3 owner=$4 sum=$5
+	# Config and key files need neither execute nor setuid/setgid/sticky
+	# bits; as root, allowing them would hand out a setuid-root binary
+	case "$mode" in
+	[0246][0246][0246] | 0[0246][0246][0246]) ;;
+	*) die "invalid mode: $mode" ;;
+	esac
+	case "$owner" in
+	-) ;;
+	"" | -* | *[!A-Za-z0-9_.:-]*) die "invalid owner: $owner" ;;
+	esac
+	dir=$(dirname -- "$p")
+	# mktemp creates the file 0600, so content is never readable by others
+	tmp=$(mktemp -- "$dir/.$(basename -- "$p").tmp-XXXXXXXX")
+	trap 'rm -f -- "$tmp"' EXIT
+	dd of="$tmp" conv=fsync status=none
+	chmod "$mode" "$tmp"
+	if [ "$owner" != - ]; then
+		chown "$owner" "$tmp"
+	fi
+	if [ "$sum" != - ]; then
+		actual=$(sha256sum -- "$tmp")
+		[ "${actual%% *}" = "$sum" ] || die "checksum mismatch for $p"
+	fi
+	mv -f -- "$tmp" "$p"
+	trap - EXIT
+	# Syncing the directory makes the rename durable; older coreutils
+	# cannot sync individual paths, which is not worth failing over.
+	sync -- "$dir" 2>/dev/null || true
+	;;
+remove)
+	p=$(writable "$path")
+	if [ "${3:-}" = -f ]; then
+		rm -f -- "$p"
+	else
+		[ -e "$p" ] || missing "$path"
+		rm -- "$p"
+	fi
+	;;
+glob)
+	[ $# -eq 3 ] || die "usage: $0 glob DIR PATTERN"
+	d=$(canon "$path")
+	case "$d" in
+	/etc/network | /etc/network/interfaces.d | /etc/NetworkManager/system-connections | /etc/netplan) ;;
+	*) die "refusing to list $path" ;;
+	esac
+	case "$3" in
+	"" | *[!A-Za-z0-9_.*?-]*) die "invalid pattern: $3" ;;
+	esac
+	[ -d "$d" ] || exit 0
+	for f in "$d"/$3; do
+		if [ -f "$f" ]; then
+			printf '%s\n' "$f"
+		fi
+	done
+	;;
+mkdir)
+	[ $# -eq 2 ] || die "usage: $0 mkdir DIR"
+	p=$(canon "$path")
+	if [ "$p" != "$(canon "$GLOBAL_CONFIG").d" ]; then
+		p=$(writable "$path")
+	fi
+	case "$p" in
+	*.d) ;;
+	*) die "refusing to create $path: not a drop-in directory" ;;
+	esac
+	[ -d "$p" ] || mkdir -m 0755 -- "$p"
+	;;
+*)
+	die "unknown operation: $op"
+	;;
+esac
@@ -3,6 +3,7 @@ package service
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -19,6 +20,9 @@ type TypeInfo struct {
 	IsBool  bool
 	IsInt   bool
 	IsMap   bool
+	// IsSecret marks key material, annotated in the schema with
+	// "writeOnly": true or "x-secret": true
+	IsSecret bool
 }
 
 type SchemaService struct {
@@ -40,6 +44,12 @@ type SchemaService struct {
 }
 
 func NewSchemaService(baseSchemaDir string) (*SchemaService, error) {
+	return NewSchemaServiceWithLog(baseSchemaDir, os.Stdout)
+}
+
+// NewSchemaServiceWithLog is NewSchemaService with its version and warning
+// messages written to logOut.
+func NewSchemaServiceWithLog(baseSchemaDir string, logOut io.Writer) (*SchemaService, error) {
 	// 1. Detect Real Version
 	realVersionStr := "257" // Default fallback
 	cmd := exec.Command("networkctl", "--version")
@@ -53,7 +63,7 @@ func NewSchemaService(baseSchemaDir string) (*SchemaService, error) {
 		}
 	} else {
 		// Attempt fallback if networkctl fails? Or just log
-		fmt.Println("networkctl checks failed, defaulting to", realVersionStr)
+		fmt.Fprintln(logOut, "networkctl checks failed, defaulting to", realVersionStr)
 	}
 
 	realVersion, _ := strconv.Atoi(realVersionStr)
@@ -109,7 +119,7 @@ func NewSchemaService(baseSchemaDir string) (*SchemaService, error) {
 		Validators:         make(map[string]*jsonschema.Schema),
 	}
 
-	fmt.Printf("Systemd Version: %s, Selected Schema: %s\n", realVersionStr, selectedVersionStr)
+	fmt.Fprintf(logOut, "Systemd Version: %s, Selected Schema: %s\n", realVersionStr, selectedVersionStr)
 
 	schemaFiles := map[string]string{
 		"systemd.network.schema.json":       "network",
@@ -122,7 +132,7 @@ func NewSchemaService(baseSchemaDir string) (*SchemaService, error) {
 		schemaPath := filepath.Join(s.SchemaDir, file)
 		content, err := os.ReadFile(schemaPath)
 		if err != nil {
-			fmt.Printf("Warning: Failed to load schema %s: %v\n", schemaPath, err)
+			fmt.Fprintf(logOut, "Warning: Failed to load schema %s: %v\n", schemaPath, err)
 			continue
 		}
 
@@ -137,12 +147,12 @@ func NewSchemaService(baseSchemaDir string) (*SchemaService, error) {
 		// Compile JSON Schema validator
 		c := jsonschema.NewCompiler()
 		if err := c.AddResource(file, schemaMap); err != nil {
-			fmt.Printf("Warning: Failed to add schema resource %s: %v\n", file, err)
+			fmt.Fprintf(logOut, "Warning: Failed to add schema resource %s: %v\n", file, err)
 			continue
 		}
 		compiled, err := c.Compile(file)
 		if err != nil {
-			fmt.Printf("Warning: Failed to compile schema %s: %v\n", file, err)
+			fmt.Fprintf(logOut, "Warning: Failed to compile schema %s: %v\n", file, err)
 			continue
 		}
 		s.Validators[configType] = compiled
@@ -239,6 +249,10 @@ func (s *SchemaService) resolveType(propDef map[string]interface{}, definitions
 			return
 		}
 
+		if p["writeOnly"] == true || p["x-secret"] == true {
+			info.IsSecret = true
+		}
+
 		if t, ok := p["type"].(string); ok {
 			if t == "boolean" {
 				info.IsBool = true
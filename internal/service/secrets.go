@@ -0,0 +1,191 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// RedactedValue replaces secret values in read responses. Sending it back in
+// an update is treated the same as omitting the key: the stored secret is kept.
+const RedactedValue = "**redacted**"
+
+// builtinSecretKeys lists secret-bearing keys per config type and section, for
+// schemas that do not annotate them with "writeOnly" or "x-secret".
+var builtinSecretKeys = map[string]map[string][]string{
+	"netdev": {
+		"WireGuard":                 {"PrivateKey"},
+		"WireGuardPeer":             {"PresharedKey"},
+		"MACsecTransmitAssociation": {"Key"},
+		"MACsecReceiveAssociation":  {"Key"},
+	},
+}
+
+// secretFileKeys maps a secret key to the key that references the same
+// secret stored in a separate file.
+var secretFileKeys = map[string]string{
+	"PrivateKey":   "PrivateKeyFile",
+	"PresharedKey": "PresharedKeyFile",
+	"Key":          "KeyFile",
+}
+
+// IsSecretKey reports whether a key holds key material, either because the
+// schema marks it as such or because it is on the built-in list.
+func (s *SchemaService) IsSecretKey(configType, section, key string) bool {
+	if s.GetTypeInfo(configType, section, key).IsSecret {
+		return true
+	}
+	for _, k := range builtinSecretKeys[configType][section] {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionItems returns the maps making up a section, whether it is a single
+// object or a repeated section (list of objects).
+func sectionItems(val interface{}) []map[string]interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}
+	case []interface{}:
+		var items []map[string]interface{}
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				items = append(items, m)
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+// HasSecrets reports whether a parsed config contains any secret values.
+func HasSecrets(cfg map[string]interface{}, schema *SchemaService, configType string) bool {
+	for section, val := range cfg {
+		for _, item := range sectionItems(val) {
+			for key, v := range item {
+				if v != nil && schema.IsSecretKey(configType, section, key) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// RedactSecrets replaces every secret value in cfg with RedactedValue, in place.
+func RedactSecrets(cfg map[string]interface{}, schema *SchemaService, configType string) {
+	for section, val := range cfg {
+		for _, item := range sectionItems(val) {
+			for key, v := range item {
+				if v == nil || !schema.IsSecretKey(configType, section, key) {
+					continue
+				}
+				if list, ok := v.([]string); ok {
+					redacted := make([]string, len(list))
+					for i := range redacted {
+						redacted[i] = RedactedValue
+					}
+					item[key] = redacted
+				} else {
+					item[key] = RedactedValue
+				}
+			}
+		}
+	}
+}
+
+// PreserveSecrets copies secrets from the stored config into an update that
+// omits them (or sends RedactedValue back), so clients that only ever saw the
+// redacted view do not wipe keys on save. Items of repeated sections are
+// paired by PublicKey when both sides have one, otherwise by position.
+// A key is not restored when the update switches to its *File counterpart.
+func PreserveSecrets(updated, stored map[string]interface{}, schema *SchemaService, configType string) {
+	for section, val := range updated {
+		newItems := sectionItems(val)
+		oldItems := sectionItems(stored[section])
+		for i, item := range newItems {
+			old := matchSectionItem(item, oldItems, i)
+			if old == nil {
+				continue
+			}
+			for key, oldVal := range old {
+				if !schema.IsSecretKey(configType, section, key) {
+					continue
+				}
+				if fileKey, ok := secretFileKeys[key]; ok && item[fileKey] != nil {
+					continue
+				}
+				if cur, ok := item[key]; !ok || cur == nil || cur == RedactedValue {
+					item[key] = oldVal
+				}
+			}
+			// Drop any placeholder that had nothing to restore
+			for key, v := range item {
+				if v == RedactedValue {
+					delete(item, key)
+				}
+			}
+		}
+	}
+}
+
+func matchSectionItem(item map[string]interface{}, candidates []map[string]interface{}, index int) map[string]interface{} {
+	if pk, ok := item["PublicKey"].(string); ok && pk != "" {
+		for _, c := range candidates {
+			if c["PublicKey"] == pk {
+				return c
+			}
+		}
+		return nil
+	}
+	if index < len(candidates) {
+		return candidates[index]
+	}
+	return nil
+}
+
+// KeyFile is a secret moved out of a config into its own file.
+type KeyFile struct {
+	Filename string
+	Content  string
+}
+
+// ExtractSecretsToFiles moves inline secrets that have a *File counterpart
+// (PrivateKey -> PrivateKeyFile, ...) out of cfg. Each secret is assigned a
+// key file named after the config file, and cfg is rewritten to reference it
+// by absolute path under configDir. The returned files must be written
+// before the config itself.
+func ExtractSecretsToFiles(cfg map[string]interface{}, schema *SchemaService, configType, filename, configDir string) []KeyFile {
+	var files []KeyFile
+	for section, val := range cfg {
+		for i, item := range sectionItems(val) {
+			for key, v := range item {
+				secret, ok := v.(string)
+				if !ok || secret == "" || secret == RedactedValue || !schema.IsSecretKey(configType, section, key) {
+					continue
+				}
+				fileKey, ok := secretFileKeys[key]
+				if !ok {
+					continue
+				}
+				// Peers are identified by their public key so that
+				// reordering them does not reshuffle key files.
+				id := fmt.Sprintf("%s%d", section, i)
+				if pk, ok := item["PublicKey"].(string); ok && pk != "" {
+					sum := sha256.Sum256([]byte(pk))
+					id = section + "-" + hex.EncodeToString(sum[:4])
+				}
+				name := keyFileName(filename, id, key)
+				files = append(files, KeyFile{Filename: name, Content: strings.TrimSpace(secret) + "\n"})
+				delete(item, key)
+				item[fileKey] = filepath.Join(configDir, name)
+			}
+		}
+	}
+	return files
+}
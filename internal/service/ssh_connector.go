@@ -2,12 +2,20 @@ package service
 
 import (
 	"bytes" // Added for bytes.NewReader
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/sftp"
@@ -22,33 +30,201 @@ type SSHConnector struct {
 	Client    *ssh.Client
 	SFTP      *sftp.Client
 	ConfigDir string // Remote config dir, e.g. /etc/systemd/network
+	// GlobalConfigPath is the remote networkd.conf; drop-ins live in <path>.d
+	GlobalConfigPath string
+	Privilege        string // one of the Privilege* methods
+	ConnectTimeout   time.Duration
+	CommandTimeout   time.Duration // 0 disables the limit
+
+	// mu serialises connect and Close; the stats sampler and request
+	// handlers share connectors
+	mu sync.Mutex
+
+	// runner executes file helper and key generation commands; nil means
+	// a session on Client. Tests substitute a fake host.
+	runner commandRunner
 }
 
+// commandRunner runs one remote command. A non-zero exit is reported as an
+// error with an ExitStatus() int method, like *ssh.ExitError.
+type commandRunner interface {
+	Run(cmd string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// sshRunner runs each command in its own session of the connector's client.
+type sshRunner struct {
+	c *SSHConnector
+}
+
+func (r sshRunner) Run(cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if err := r.c.ensureConnected(); err != nil {
+		return err
+	}
+	session, err := r.c.newSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+	return session.Run(cmd)
+}
+
+func (c *SSHConnector) run(cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if c.runner != nil {
+		return c.runner.Run(cmd, stdin, stdout, stderr)
+	}
+	return sshRunner{c}.Run(cmd, stdin, stdout, stderr)
+}
+
+// ErrSudoPasswordRequired is returned in sudo-n and doas mode when sudo or
+// doas would have prompted for a password.
+var ErrSudoPasswordRequired = errors.New("sudo/doas requires a password on the remote host; configure NOPASSWD (sudo) or nopass (doas) for the SSH user")
+
 // shellQuote wraps a string in single quotes for safe use in shell commands,
 // escaping any embedded single quotes.
 func shellQuote(s string) string {
 	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
 }
 
-func NewSSHConnector(host string, port int, user, keyFile string) *SSHConnector {
-	return &SSHConnector{
-		Host:      host,
-		Port:      port,
-		User:      user,
-		KeyFile:   keyFile,
-		ConfigDir: "/etc/systemd/network",
+// NewSSHConnector builds a connector from a host's settings, filling in
+// defaults for everything left empty. Relative key files are resolved
+// against dataDir.
+func NewSSHConnector(cfg HostConfig, dataDir string) *SSHConnector {
+	c := &SSHConnector{
+		Host:             cfg.Host,
+		Port:             cfg.Port,
+		User:             cfg.User,
+		KeyFile:          filepath.Join(dataDir, "id_rsa"),
+		ConfigDir:        "/etc/systemd/network",
+		GlobalConfigPath: "/etc/systemd/networkd.conf",
+		Privilege:        cfg.Privilege,
+		ConnectTimeout:   DefaultConnectTimeout,
+		CommandTimeout:   DefaultCommandTimeout,
+	}
+	if cfg.KeyFile != "" {
+		c.KeyFile = cfg.KeyFile
+		if !filepath.IsAbs(c.KeyFile) {
+			c.KeyFile = filepath.Join(dataDir, c.KeyFile)
+		}
 	}
+	if cfg.ConfigDir != "" {
+		c.ConfigDir = cfg.ConfigDir
+	}
+	if cfg.GlobalConfigPath != "" {
+		c.GlobalConfigPath = cfg.GlobalConfigPath
+	}
+	if cfg.ConnectTimeout > 0 {
+		c.ConnectTimeout = time.Duration(cfg.ConnectTimeout) * time.Second
+	}
+	if cfg.CommandTimeout > 0 {
+		c.CommandTimeout = time.Duration(cfg.CommandTimeout) * time.Second
+	}
+	return c
 }
 
-// sudoPrefix returns "sudo " when the SSH user is not root, empty string otherwise.
+// sudoPrefix returns the privilege escalation prefix for commands that need
+// root: none for root in auto mode, "sudo " otherwise, or the configured method.
 func (c *SSHConnector) sudoPrefix() string {
+	switch c.Privilege {
+	case PrivilegeNone:
+		return ""
+	case PrivilegeSudo:
+		return "sudo "
+	case PrivilegeSudoN:
+		return "sudo -n "
+	case PrivilegeDoas:
+		// -n fails instead of prompting, like sudo-n
+		return "doas -n "
+	}
 	if c.User == "root" {
 		return ""
 	}
 	return "sudo "
 }
 
+// sshSession is an ssh.Session that is closed once the connector's command
+// timeout expires, and whose errors name the timeout or a sudo password
+// prompt instead of a bare exit status.
+type sshSession struct {
+	*ssh.Session
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut atomic.Bool
+}
+
+// newSession opens a session for a single command. Long-running sessions
+// such as journal follow use c.Client.NewSession directly.
+func (c *SSHConnector) newSession() (*sshSession, error) {
+	session, err := c.Client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	s := &sshSession{Session: session, timeout: c.CommandTimeout}
+	if s.timeout > 0 {
+		s.timer = time.AfterFunc(s.timeout, func() {
+			s.timedOut.Store(true)
+			session.Signal(ssh.SIGKILL)
+			session.Close()
+		})
+	}
+	return s, nil
+}
+
+func (s *sshSession) Close() error {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	return s.Session.Close()
+}
+
+func (s *sshSession) wrapErr(err error, stderr []byte) error {
+	if err == nil {
+		return nil
+	}
+	if s.timedOut.Load() {
+		return fmt.Errorf("remote command timed out after %s", s.timeout)
+	}
+	// sudo -n and doas -n (which says "Authentication" or, in older
+	// versions, "Authorization required")
+	if bytes.Contains(stderr, []byte("a password is required")) || bytes.Contains(stderr, []byte("doas: Authentication required")) || bytes.Contains(stderr, []byte("doas: Authorization required")) {
+		return ErrSudoPasswordRequired
+	}
+	return err
+}
+
+// captureStderr tees stderr into a buffer for wrapErr, keeping any writer
+// the caller installed.
+func (s *sshSession) captureStderr() *bytes.Buffer {
+	var buf bytes.Buffer
+	if s.Stderr != nil {
+		s.Stderr = io.MultiWriter(s.Stderr, &buf)
+	} else {
+		s.Stderr = &buf
+	}
+	return &buf
+}
+
+func (s *sshSession) Run(cmd string) error {
+	stderr := s.captureStderr()
+	return s.wrapErr(s.Session.Run(cmd), stderr.Bytes())
+}
+
+func (s *sshSession) Output(cmd string) ([]byte, error) {
+	stderr := s.captureStderr()
+	out, err := s.Session.Output(cmd)
+	return out, s.wrapErr(err, stderr.Bytes())
+}
+
+func (s *sshSession) CombinedOutput(cmd string) ([]byte, error) {
+	out, err := s.Session.CombinedOutput(cmd)
+	return out, s.wrapErr(err, out)
+}
+
 func (c *SSHConnector) connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.Client != nil && c.SFTP != nil {
 		return nil // Already connected (todo: check liveness)
 	}
@@ -69,7 +245,7 @@ func (c *SSHConnector) connect() error {
 			ssh.PublicKeys(signer),
 		},
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // TODO: manage known_hosts
-		Timeout:         5 * time.Second,
+		Timeout:         c.ConnectTimeout,
 	}
 
 	addr := fmt.Sprintf("%s:%d", c.Host, c.Port)
@@ -90,6 +266,8 @@ func (c *SSHConnector) connect() error {
 }
 
 func (c *SSHConnector) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.SFTP != nil {
 		c.SFTP.Close()
 	}
@@ -103,6 +281,10 @@ func (c *SSHConnector) ensureConnected() error {
 	return c.connect()
 }
 
+func (c *SSHConnector) GetConfigDir() string {
+	return c.ConfigDir
+}
+
 func (c *SSHConnector) ListConfigDir(suffix string) ([]os.DirEntry, error) {
 	if err := c.ensureConnected(); err != nil {
 		return nil, err
@@ -123,65 +305,110 @@ func (c *SSHConnector) ListConfigDir(suffix string) ([]os.DirEntry, error) {
 }
 
 func (c *SSHConnector) ReadConfigFile(filename string) ([]byte, error) {
-	if err := c.ensureConnected(); err != nil {
-		return nil, err
-	}
-	session, err := c.Client.NewSession()
-	if err != nil {
-		return nil, err
-	}
-	defer session.Close()
+	return c.runHelper(nil, "read", filepath.Join(c.ConfigDir, filename))
+}
 
-	remotePath := filepath.Join(c.ConfigDir, filename)
-	cmd := fmt.Sprintf("%scat %s", c.sudoPrefix(), shellQuote(remotePath))
-	return session.Output(cmd)
+func (c *SSHConnector) WriteConfigFile(filename string, content []byte, opts WriteOptions) error {
+	return c.writeFileAtomic(filepath.Join(c.ConfigDir, filename), content, opts)
 }
 
-func (c *SSHConnector) WriteConfigFile(filename string, content []byte) error {
-	if err := c.ensureConnected(); err != nil {
-		return err
+// helperCommand renders an invocation of the remote helper (see
+// remote_helper.sh). With sudo/doas it runs the copy installed by
+// scripts/setup-remote-host.sh, which is the only file command the sudoers
+// entry allows; otherwise the embedded copy runs inline with the host's paths.
+func (c *SSHConnector) helperCommand(op string, args ...string) string {
+	quoted := []string{shellQuote(op)}
+	for _, a := range args {
+		quoted = append(quoted, shellQuote(a))
 	}
-	session, err := c.Client.NewSession()
-	if err != nil {
-		return err
+	if prefix := c.sudoPrefix(); prefix != "" {
+		return prefix + RemoteHelperPath + " " + strings.Join(quoted, " ")
 	}
-	defer session.Close()
+	return fmt.Sprintf("HELPER_NETWORK_DIR=%s HELPER_GLOBAL_CONFIG=%s sh -c %s networkd-api-helper %s",
+		shellQuote(c.ConfigDir), shellQuote(c.GlobalConfigPath), shellQuote(RemoteHelperScript), strings.Join(quoted, " "))
+}
 
-	var stderr bytes.Buffer
-	session.Stdin = bytes.NewReader(content)
-	session.Stderr = &stderr
-	remotePath := filepath.Join(c.ConfigDir, filename)
-	cmd := fmt.Sprintf("%stee %s > /dev/null", c.sudoPrefix(), shellQuote(remotePath))
-	if err := session.Run(cmd); err != nil {
-		errMsg := strings.TrimSpace(stderr.String())
-		if errMsg != "" {
-			return fmt.Errorf("failed to write file: %s", errMsg)
+// runHelper runs one helper operation and returns its stdout. Failures carry
+// the helper's stderr; missing files wrap fs.ErrNotExist.
+func (c *SSHConnector) runHelper(stdin io.Reader, op string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	if err := c.run(c.helperCommand(op, args...), stdin, &stdout, &stderr); err != nil {
+		var exitErr interface{ ExitStatus() int }
+		if errors.As(err, &exitErr) && exitErr.ExitStatus() == 2 {
+			return nil, fmt.Errorf("%s: %w", args[0], fs.ErrNotExist)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" && !errors.Is(err, ErrSudoPasswordRequired) {
+			return nil, fmt.Errorf("%s %s: %s", op, args[0], msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// writeFileAtomic streams content into a private temporary file next to
+// remotePath (dd conv=fsync flushes it to disk), applies mode and ownership,
+// and lets the helper compare its SHA-256 with ours before renaming the file
+// into place. If the session drops before the rename, the target is untouched.
+// Drop-in directories are created first; any other directory must exist.
+func (c *SSHConnector) writeFileAtomic(remotePath string, content []byte, opts WriteOptions) error {
+	if dir := filepath.Dir(remotePath); strings.HasSuffix(dir, ".d") {
+		if _, err := c.runHelper(nil, "mkdir", dir); err != nil {
+			return fmt.Errorf("failed to create %s: %v", dir, err)
 		}
+	}
+	owner := opts.chownSpec()
+	if owner == "" {
+		owner = "-"
+	}
+	sum := sha256.Sum256(content)
+	_, err := c.runHelper(bytes.NewReader(content), "write", remotePath,
+		fmt.Sprintf("%04o", opts.mode().Perm()), owner, hex.EncodeToString(sum[:]))
+	if err != nil {
 		return fmt.Errorf("failed to write file: %v", err)
 	}
 	return nil
 }
 
 func (c *SSHConnector) DeleteConfigFile(filename string) error {
-	if err := c.ensureConnected(); err != nil {
-		return err
+	_, err := c.runHelper(nil, "remove", filepath.Join(c.ConfigDir, filename))
+	return err
+}
+
+// GenerateWireGuardKey runs `wg genkey` on the remote host. The private key
+// only lives in a shell variable there and in the key file, which the helper
+// writes the same way as writeFileAtomic does.
+func (c *SSHConnector) GenerateWireGuardKey(keyFile string, opts WriteOptions) (string, error) {
+	owner := opts.chownSpec()
+	if owner == "" {
+		owner = "-"
 	}
-	session, err := c.Client.NewSession()
-	if err != nil {
-		return err
+	write := c.helperCommand("write", filepath.Join(c.ConfigDir, keyFile), fmt.Sprintf("%04o", opts.mode().Perm()), owner, "-")
+	steps := []string{
+		"key=$(wg genkey)",
+		"printf '%s\\n' \"$key\" | " + write,
+		"printf '%s\\n' \"$key\" | wg pubkey",
 	}
-	defer session.Close()
 
-	remotePath := filepath.Join(c.ConfigDir, filename)
-	cmd := fmt.Sprintf("%srm %s", c.sudoPrefix(), shellQuote(remotePath))
-	return session.Run(cmd)
+	var stdout, stderr bytes.Buffer
+	if err := c.run("umask 077 && "+strings.Join(steps, " && "), nil, &stdout, &stderr); err != nil {
+		errMsg := strings.TrimSpace(stderr.String())
+		if errMsg != "" {
+			return "", fmt.Errorf("remote key generation failed: %s", errMsg)
+		}
+		return "", fmt.Errorf("remote key generation failed: %v", err)
+	}
+	publicKey := strings.TrimSpace(stdout.String())
+	if !validWireGuardKey(publicKey) {
+		return "", fmt.Errorf("remote key generation returned an invalid public key")
+	}
+	return publicKey, nil
 }
 
 func (c *SSHConnector) Reconfigure(devices []string) error {
 	if err := c.ensureConnected(); err != nil {
 		return err
 	}
-	session, err := c.Client.NewSession()
+	session, err := c.newSession()
 	if err != nil {
 		return err
 	}
@@ -201,6 +428,24 @@ func (c *SSHConnector) Reconfigure(devices []string) error {
 	return nil
 }
 
+func (c *SSHConnector) LinkAction(name, action string) (string, string, error) {
+	cmd := c.sudoPrefix() + "networkctl " + shellQuote(action) + " " + shellQuote(name)
+	if err := c.ensureConnected(); err != nil {
+		return cmd, "", err
+	}
+	session, err := c.newSession()
+	if err != nil {
+		return cmd, "", err
+	}
+	defer session.Close()
+
+	output, err := session.CombinedOutput(cmd)
+	if err != nil {
+		return cmd, string(output), fmt.Errorf("remote networkctl %s failed: %w", action, err)
+	}
+	return cmd, string(output), nil
+}
+
 type networkctlLink struct {
 	Index            int    `json:"Index"`
 	Name             string `json:"Name"`
@@ -231,7 +476,7 @@ func (c *SSHConnector) GetLinks() ([]Link, error) {
 	var links []Link
 	useJSON := true
 
-	session, err := c.Client.NewSession()
+	session, err := c.newSession()
 	if err != nil {
 		return nil, err
 	}
@@ -259,7 +504,7 @@ func (c *SSHConnector) GetLinks() ([]Link, error) {
 
 	if !useJSON {
 		// Fallback to text parsing
-		session, err = c.Client.NewSession()
+		session, err = c.newSession()
 		if err != nil {
 			return nil, err
 		}
@@ -291,7 +536,7 @@ func (c *SSHConnector) GetLinks() ([]Link, error) {
 	}
 
 	// 2. Fetch Addresses via ip -j addr
-	session, err = c.Client.NewSession()
+	session, err = c.newSession()
 	if err == nil {
 		ipOut, err := session.Output("ip -j addr")
 		session.Close()
@@ -315,7 +560,7 @@ func (c *SSHConnector) GetLinks() ([]Link, error) {
 
 	// 3. Enrich with networkctl status per interface (MAC, type, driver, path)
 	for i := range links {
-		session, err = c.Client.NewSession()
+		session, err = c.newSession()
 		if err != nil {
 			continue
 		}
@@ -344,69 +589,281 @@ func (c *SSHConnector) GetLinks() ([]Link, error) {
 	return links, nil
 }
 
-func (c *SSHConnector) GetSystemdVersion() string {
+func (c *SSHConnector) GetLinkDetails(name string) (*LinkDetails, error) {
 	if err := c.ensureConnected(); err != nil {
-		return ""
+		return nil, err
 	}
-	session, err := c.Client.NewSession()
+	session, err := c.newSession()
 	if err != nil {
-		return ""
+		return nil, err
+	}
+	// The uptime converts networkd's boot-time timestamps to wall-clock time
+	out, err := session.Output("cat /proc/uptime && networkctl --json=short status " + shellQuote(name))
+	session.Close()
+	if err != nil {
+		return nil, fmt.Errorf("remote networkctl status %s failed: %v", name, err)
+	}
+	uptime, description, _ := bytes.Cut(out, []byte("\n"))
+	details, err := parseLinkDescription(description, bootTimeFromUptime(string(uptime), time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	// Speed and duplex are not part of networkd's JSON; read them from sysfs
+	session, err = c.newSession()
+	if err != nil {
+		return details, nil
 	}
 	defer session.Close()
+	sysfs := "/sys/class/net/" + name
+	cmd := fmt.Sprintf("cat %s 2>/dev/null; echo; cat %s 2>/dev/null", shellQuote(sysfs+"/speed"), shellQuote(sysfs+"/duplex"))
+	if out, err := session.Output(cmd); err == nil {
+		speed, duplex, _ := strings.Cut(string(out), "\n")
+		applySysfsSpeed(details, speed, duplex)
+	}
+	return details, nil
+}
 
-	out, err := session.Output("networkctl --version | head -n1 | awk '{print $2}'")
+func (c *SSHConnector) GetTunnels() ([]TunnelStatus, error) {
+	if err := c.ensureConnected(); err != nil {
+		return nil, err
+	}
+	session, err := c.newSession()
 	if err != nil {
-		return ""
+		return nil, err
 	}
-	return strings.TrimSpace(string(out))
+	out, err := session.Output("ip -d -j link show")
+	session.Close()
+	if err != nil {
+		return nil, fmt.Errorf("remote ip link failed: %v", err)
+	}
+	tunnels, err := parseTunnelLinks(out)
+	if err != nil {
+		return nil, err
+	}
+
+	// wg is optional; without it WireGuard tunnels are listed without peers
+	session, err = c.newSession()
+	if err != nil {
+		return tunnels, nil
+	}
+	defer session.Close()
+	if dump, err := session.Output(c.sudoPrefix() + "wg show all dump"); err == nil {
+		tunnels = mergeTunnelStatus(tunnels, parseWireGuardDump(string(dump), time.Now()))
+	}
+	return tunnels, nil
 }
 
-func (c *SSHConnector) GetGlobalConfig() (string, error) {
+func (c *SSHConnector) GetInterfaceStats() ([]InterfaceStats, error) {
+	if err := c.ensureConnected(); err != nil {
+		return nil, err
+	}
+	session, err := c.newSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	out, err := session.Output("ip -j -s link show")
+	if err != nil {
+		return nil, fmt.Errorf("remote ip link failed: %v", err)
+	}
+	return parseIPLinkStats(out)
+}
+
+func (c *SSHConnector) GetHostname() (string, error) {
 	if err := c.ensureConnected(); err != nil {
 		return "", err
 	}
-	session, err := c.Client.NewSession()
+	session, err := c.newSession()
 	if err != nil {
 		return "", err
 	}
 	defer session.Close()
 
-	out, err := session.Output(c.sudoPrefix() + "cat /etc/systemd/networkd.conf")
+	out, err := session.Output("hostname")
 	if err != nil {
 		return "", err
 	}
-	return string(out), nil
+	return strings.TrimSpace(string(out)), nil
 }
 
-func (c *SSHConnector) SaveGlobalConfig(content string) error {
+// GetSessionAddrs returns the server side of $SSH_CONNECTION, which is the
+// address the session arrived on even when Host is a name or behind NAT.
+func (c *SSHConnector) GetSessionAddrs() ([]string, error) {
+	var stdout, stderr bytes.Buffer
+	if err := c.run(`printf '%s' "$SSH_CONNECTION"`, nil, &stdout, &stderr); err != nil {
+		return nil, fmt.Errorf("reading SSH_CONNECTION failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	// client-address client-port server-address server-port
+	fields := strings.Fields(stdout.String())
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("unexpected SSH_CONNECTION: %q", stdout.String())
+	}
+	addr, _, _ := strings.Cut(fields[2], "%")
+	return []string{addr}, nil
+}
+
+func (c *SSHConnector) GetLLDPNeighbors() ([]LLDPNeighbor, error) {
 	if err := c.ensureConnected(); err != nil {
-		return err
+		return nil, err
 	}
-	session, err := c.Client.NewSession()
+	session, err := c.newSession()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer session.Close()
 
-	session.Stdin = strings.NewReader(content)
-	// sudo tee
-	if err := session.Run("sudo tee /etc/systemd/networkd.conf > /dev/null"); err != nil {
+	out, err := session.Output("networkctl --json=short lldp")
+	if err != nil {
+		return nil, fmt.Errorf("remote networkctl lldp failed: %v", err)
+	}
+	return parseLLDPNeighbors(out)
+}
+
+func (c *SSHConnector) GetResolvedStatus() (*ResolvedStatus, error) {
+	if err := c.ensureConnected(); err != nil {
+		return nil, err
+	}
+	session, err := c.newSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	text, err := session.Output("resolvectl --no-pager status")
+	if err != nil {
+		return nil, fmt.Errorf("remote resolvectl status failed: %v", err)
+	}
+
+	var jsonOut []byte
+	if jsonSession, err := c.newSession(); err == nil {
+		jsonOut, _ = jsonSession.Output("resolvectl --no-pager --json=short status")
+		jsonSession.Close()
+	}
+	return newResolvedStatus(text, jsonOut), nil
+}
+
+func (c *SSHConnector) GetUdevInfo(iface string) (*UdevInfo, error) {
+	if err := c.ensureConnected(); err != nil {
+		return nil, err
+	}
+	session, err := c.newSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	out, err := session.Output("udevadm info --query=property --path=" + shellQuote("/sys/class/net/"+iface))
+	if err != nil {
+		return nil, fmt.Errorf("remote udevadm info failed for %s: %v", iface, err)
+	}
+	return parseUdevProperties(iface, string(out)), nil
+}
+
+// ReadHostFile reads path through the helper, which only allows the networkd
+// configuration and the import sources (see remote_helper.sh).
+func (c *SSHConnector) ReadHostFile(path string) ([]byte, error) {
+	return c.runHelper(nil, "read", path)
+}
+
+// GlobHostFiles lists the matches of pattern through the helper; root-only
+// directories such as NetworkManager's system-connections need its sudo
+// entry.
+func (c *SSHConnector) GlobHostFiles(pattern string) ([]string, error) {
+	out, err := c.runHelper(nil, "glob", filepath.Dir(pattern), filepath.Base(pattern))
+	if err != nil {
+		return nil, fmt.Errorf("remote glob %s failed: %v", pattern, err)
+	}
+	var paths []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line != "" {
+			paths = append(paths, line)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (c *SSHConnector) GetSystemdVersion() string {
+	if err := c.ensureConnected(); err != nil {
+		return ""
+	}
+	session, err := c.newSession()
+	if err != nil {
+		return ""
+	}
+	defer session.Close()
+
+	out, err := session.Output("networkctl --version | head -n1 | awk '{print $2}'")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (c *SSHConnector) GetGlobalConfig() (string, error) {
+	out, err := c.runHelper(nil, "read", c.GlobalConfigPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Sprintf("# %s\n# No configuration file found.\n", c.GlobalConfigPath), nil
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+func (c *SSHConnector) GetGlobalConfigPath() string {
+	return c.GlobalConfigPath
+}
+
+func (c *SSHConnector) SaveGlobalConfig(content string) error {
+	if err := c.writeFileAtomic(c.GlobalConfigPath, []byte(content), WriteOptions{}); err != nil {
 		return fmt.Errorf("failed to write global config: %v", err)
 	}
 	return nil
 }
 
+func (c *SSHConnector) ListGlobalDropins() ([]string, error) {
+	paths, err := c.GlobHostFiles(filepath.Join(c.GlobalConfigPath+".d", "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(paths))
+	for _, p := range paths {
+		names = append(names, filepath.Base(p))
+	}
+	return names, nil
+}
+
+func (c *SSHConnector) ReadGlobalDropin(name string) (string, error) {
+	out, err := c.runHelper(nil, "read", filepath.Join(c.GlobalConfigPath+".d", name))
+	return string(out), err
+}
+
+func (c *SSHConnector) WriteGlobalDropin(name, content string) error {
+	if err := c.writeFileAtomic(filepath.Join(c.GlobalConfigPath+".d", name), []byte(content), WriteOptions{}); err != nil {
+		return fmt.Errorf("failed to write global config drop-in: %v", err)
+	}
+	return nil
+}
+
+func (c *SSHConnector) DeleteGlobalDropin(name string) error {
+	_, err := c.runHelper(nil, "remove", filepath.Join(c.GlobalConfigPath+".d", name))
+	return err
+}
+
 func (c *SSHConnector) ReloadNetworkd() (string, error) {
 	if err := c.ensureConnected(); err != nil {
 		return "", err
 	}
-	session, err := c.Client.NewSession()
+	session, err := c.newSession()
 	if err != nil {
 		return "", err
 	}
 	defer session.Close()
 
-	out, err := session.CombinedOutput("sudo networkctl reload")
+	out, err := session.CombinedOutput(c.sudoPrefix() + "networkctl reload")
 	return string(out), err
 }
 
@@ -414,7 +871,7 @@ func (c *SSHConnector) GetRoutes() (string, error) {
 	if err := c.ensureConnected(); err != nil {
 		return "", err
 	}
-	session, err := c.Client.NewSession()
+	session, err := c.newSession()
 	if err != nil {
 		return "", err
 	}
@@ -428,7 +885,7 @@ func (c *SSHConnector) GetRules() (string, error) {
 	if err := c.ensureConnected(); err != nil {
 		return "", err
 	}
-	session, err := c.Client.NewSession()
+	session, err := c.newSession()
 	if err != nil {
 		return "", err
 	}
@@ -438,16 +895,66 @@ func (c *SSHConnector) GetRules() (string, error) {
 	return string(out), err
 }
 
-func (c *SSHConnector) GetLogs() (string, error) {
+func journalCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return "journalctl " + strings.Join(quoted, " ")
+}
+
+func (c *SSHConnector) ReadJournal(args []string) ([]byte, error) {
 	if err := c.ensureConnected(); err != nil {
-		return "", err
+		return nil, err
+	}
+	session, err := c.newSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	out, err := session.Output(journalCommand(args))
+	if err != nil {
+		return nil, fmt.Errorf("remote journalctl failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func (c *SSHConnector) FollowJournal(ctx context.Context, args []string, onLine func([]byte) error) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
 	}
 	session, err := c.Client.NewSession()
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer session.Close()
 
-	out, err := session.Output("journalctl -u systemd-networkd -n 100 --no-pager")
-	return string(out), err
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.Start(journalCommand(args)); err != nil {
+		return fmt.Errorf("remote journalctl failed: %v", err)
+	}
+
+	// Closing the session unblocks the reader when the client goes away
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Signal(ssh.SIGTERM)
+			session.Close()
+		case <-done:
+		}
+	}()
+
+	err = scanLines(stdout, onLine)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
 }
@@ -0,0 +1,481 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestNewSSHConnectorSettings(t *testing.T) {
+	c := NewSSHConnector(HostConfig{Host: "192.0.2.10", Port: 22, User: "root"}, "/var/lib/networkd-api")
+	if c.KeyFile != "/var/lib/networkd-api/id_rsa" || c.ConfigDir != "/etc/systemd/network" || c.GlobalConfigPath != "/etc/systemd/networkd.conf" {
+		t.Errorf("Unexpected defaults: %+v", c)
+	}
+	if c.ConnectTimeout != DefaultConnectTimeout || c.CommandTimeout != DefaultCommandTimeout {
+		t.Errorf("Unexpected default timeouts: %s %s", c.ConnectTimeout, c.CommandTimeout)
+	}
+	if p := c.sudoPrefix(); p != "" {
+		t.Errorf("Expected no prefix for root, got %q", p)
+	}
+
+	c = NewSSHConnector(HostConfig{
+		Host:             "192.0.2.11",
+		User:             "admin",
+		ConfigDir:        "/run/systemd/network",
+		GlobalConfigPath: "/run/systemd/networkd.conf",
+		Privilege:        PrivilegeSudoN,
+		KeyFile:          "keys/edge.pem",
+		ConnectTimeout:   2,
+		CommandTimeout:   30,
+	}, "/var/lib/networkd-api")
+	if c.KeyFile != "/var/lib/networkd-api/keys/edge.pem" || c.ConfigDir != "/run/systemd/network" || c.GlobalConfigPath != "/run/systemd/networkd.conf" {
+		t.Errorf("Settings not applied: %+v", c)
+	}
+	if c.ConnectTimeout != 2*time.Second || c.CommandTimeout != 30*time.Second {
+		t.Errorf("Unexpected timeouts: %s %s", c.ConnectTimeout, c.CommandTimeout)
+	}
+
+	for privilege, want := range map[string]string{
+		PrivilegeAuto:  "sudo ",
+		PrivilegeNone:  "",
+		PrivilegeSudo:  "sudo ",
+		PrivilegeSudoN: "sudo -n ",
+		PrivilegeDoas:  "doas -n ",
+	} {
+		c.Privilege = privilege
+		if got := c.sudoPrefix(); got != want {
+			t.Errorf("Privilege %q: expected %q, got %q", privilege, want, got)
+		}
+	}
+}
+
+func TestHostConfigValidate(t *testing.T) {
+	valid := HostConfig{Name: "edge", Host: "192.0.2.10", Privilege: PrivilegeDoas, ConfigDir: "/etc/systemd/network", KeyFile: "edge.pem"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, h := range []HostConfig{
+		{Privilege: "su"},
+		{ConfigDir: "etc/systemd/network"},
+		{KeyFile: "../id_rsa"},
+		{CommandTimeout: -1},
+	} {
+		if err := h.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", h)
+		}
+	}
+}
+
+func TestHostManagerDefaultsAndKeyFile(t *testing.T) {
+	dataDir := t.TempDir()
+	hm, err := NewHostManager(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hm.AddHost(HostConfig{Name: "edge", Host: "192.0.2.10", KeyFile: "keys/edge.pem"}); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := hm.GetHost("edge"); h.User != "networkd-api" || h.Port != 22 {
+		t.Errorf("Defaults not filled in: %+v", h)
+	}
+	if err := hm.UpdateHost(HostConfig{Name: "edge", Host: "192.0.2.10", KeyFile: filepath.Join(dataDir, "edge.pem")}); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := hm.GetHost("edge"); h.User != "networkd-api" || h.Port != 22 {
+		t.Errorf("Defaults not filled in on update: %+v", h)
+	}
+
+	for _, h := range []HostConfig{
+		{Name: "local", Host: "192.0.2.1"},
+		{Name: "edge"},
+		{Name: "edge", Host: "192.0.2.10", KeyFile: "/etc/shadow"},
+		{Name: "edge", Host: "192.0.2.10", KeyFile: dataDir + "-other/id_rsa"},
+		{Name: "edge", Host: "192.0.2.10", Privilege: "su"},
+	} {
+		if err := hm.AddHost(h); !errors.Is(err, ErrInvalidHost) {
+			t.Errorf("Expected %+v to be rejected, got %v", h, err)
+		}
+	}
+
+	// With a separate key directory only keys inside it are accepted
+	hm.KeyDir = filepath.Join(t.TempDir(), "keys")
+	if err := hm.CheckKeyFile(HostConfig{KeyFile: filepath.Join(hm.KeyDir, "edge.pem")}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := hm.CheckKeyFile(HostConfig{KeyFile: "edge.pem"}); !errors.Is(err, ErrInvalidHost) {
+		t.Errorf("Expected key in the data dir to be rejected, got %v", err)
+	}
+}
+
+func TestRemoteHelper(t *testing.T) {
+	tmpDir := t.TempDir()
+	netDir := filepath.Join(tmpDir, "network")
+	os.Mkdir(netDir, 0755)
+	os.WriteFile(filepath.Join(tmpDir, "secret"), []byte("secret"), 0600)
+	helper := func(stdin string, args ...string) (string, error) {
+		cmd := exec.Command("sh", append([]string{"-c", RemoteHelperScript, "networkd-api-helper"}, args...)...)
+		cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HELPER_NETWORK_DIR=" + netDir, "HELPER_GLOBAL_CONFIG=" + filepath.Join(tmpDir, "networkd.conf")}
+		cmd.Stdin = strings.NewReader(stdin)
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	content := "[Match]\nName=eth0\n"
+	sum := sha256.Sum256([]byte(content))
+	if out, err := helper(content, "write", filepath.Join(netDir, "10-eth0.network"), "0600", "-", hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("write failed: %v %s", err, out)
+	}
+	info, err := os.Stat(filepath.Join(netDir, "10-eth0.network"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Unexpected file: %v %v", info, err)
+	}
+	if out, err := helper("", "read", filepath.Join(netDir, "10-eth0.network")); err != nil || out != content {
+		t.Errorf("read returned %q, %v", out, err)
+	}
+	if out, err := helper("other", "write", filepath.Join(netDir, "20-eth1.network"), "0644", "-", hex.EncodeToString(sum[:])); err == nil || !strings.Contains(out, "checksum mismatch") {
+		t.Errorf("Expected checksum mismatch, got %v %s", err, out)
+	}
+	if entries, _ := os.ReadDir(netDir); len(entries) != 1 {
+		t.Errorf("Temporary file left behind: %v", entries)
+	}
+
+	for _, path := range []string{
+		filepath.Join(tmpDir, "secret"),
+		filepath.Join(netDir, "..", "secret"),
+		netDir + "-evil/x.network",
+	} {
+		if out, err := helper("", "read", path); err == nil || !strings.Contains(out, "refusing path") {
+			t.Errorf("Expected %s to be refused, got %v %s", path, err, out)
+		}
+	}
+	// Import sources are readable but not writable, and only known
+	// directories can be listed
+	for _, args := range [][]string{
+		{"write", "/etc/netplan/01-netcfg.yaml", "0644", "-", "-"},
+		{"read", "/etc/netplan/../shadow"},
+		{"glob", "/etc", "shadow"},
+		{"glob", "/etc/netplan/..", "*"},
+	} {
+		if out, err := helper("", args...); err == nil || !strings.Contains(out, "refusing") {
+			t.Errorf("Expected %v to be refused, got %v %s", args, err, out)
+		}
+	}
+	if out, err := helper("", "glob", "/etc/netplan", "$(id).yaml"); err == nil || !strings.Contains(out, "invalid pattern") {
+		t.Errorf("Expected invalid pattern to be refused, got %v %s", err, out)
+	}
+	if _, err := os.Stat("/etc/NetworkManager/system-connections"); os.IsNotExist(err) {
+		if out, err := helper("", "glob", "/etc/NetworkManager/system-connections", "*.nmconnection"); err != nil || out != "" {
+			t.Errorf("Expected no matches for a missing directory, got %v %q", err, out)
+		}
+	}
+	for _, mode := range []string{"4755", "2644", "1644", "0755", "0700", "644; id", "10644"} {
+		if out, err := helper("", "write", filepath.Join(netDir, "x.network"), mode, "-", "-"); err == nil || !strings.Contains(out, "invalid mode") {
+			t.Errorf("Expected mode %s to be refused, got %v %s", mode, err, out)
+		}
+	}
+	if out, err := helper("", "write", filepath.Join(netDir, "x.network"), "0644", "root; id", "-"); err == nil || !strings.Contains(out, "invalid owner") {
+		t.Errorf("Expected invalid owner to be refused, got %v %s", err, out)
+	}
+
+	if out, err := helper("", "remove", filepath.Join(netDir, "10-eth0.network")); err != nil {
+		t.Errorf("remove failed: %v %s", err, out)
+	}
+
+	// Only drop-in directories are created, readable by networkd
+	for _, dir := range []string{filepath.Join(netDir, "10-eth0.network.d"), filepath.Join(tmpDir, "networkd.conf.d")} {
+		if out, err := helper("", "mkdir", dir); err != nil {
+			t.Errorf("mkdir %s failed: %v %s", dir, err, out)
+		}
+		if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("Unexpected directory %s: %v %v", dir, info, err)
+		}
+	}
+	for _, dir := range []string{filepath.Join(netDir, "keys"), filepath.Join(tmpDir, "evil.d")} {
+		if out, err := helper("", "mkdir", dir); err == nil || !strings.Contains(out, "refusing") {
+			t.Errorf("Expected mkdir %s to be refused, got %v %s", dir, err, out)
+		}
+	}
+}
+
+// fakeHost stands in for a remote host reached through sudo: helper
+// operations work on an in-memory file map, every other command fails.
+type fakeHost struct {
+	files         map[string]string
+	sshConnection string
+	// fail maps "op path" of helper calls to the error they report
+	fail     map[string]string
+	commands []string
+}
+
+type fakeExit int
+
+func (e fakeExit) Error() string   { return fmt.Sprintf("exit status %d", int(e)) }
+func (e fakeExit) ExitStatus() int { return int(e) }
+
+func newFakeSSHConnector(t *testing.T, files map[string]string) (*SSHConnector, *fakeHost) {
+	host := &fakeHost{files: files}
+	c := NewSSHConnector(HostConfig{Host: "192.0.2.10", Port: 22, User: "networkd-api"}, t.TempDir())
+	c.runner = host
+	return c, host
+}
+
+func (f *fakeHost) Run(cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	f.commands = append(f.commands, cmd)
+	if cmd == `printf '%s' "$SSH_CONNECTION"` {
+		io.WriteString(stdout, f.sshConnection)
+		return nil
+	}
+	rest, ok := strings.CutPrefix(cmd, "sudo "+RemoteHelperPath+" ")
+	if !ok {
+		fmt.Fprintln(stderr, "sudo: a terminal is required")
+		return fakeExit(1)
+	}
+	args := splitShellQuoted(rest)
+	if msg, ok := f.fail[args[0]+" "+args[1]]; ok {
+		fmt.Fprintf(stderr, "networkd-api-helper: %s\n", msg)
+		return fakeExit(1)
+	}
+	switch args[0] {
+	case "read":
+		content, ok := f.files[args[1]]
+		if !ok {
+			return fakeExit(2)
+		}
+		io.WriteString(stdout, content)
+	case "write":
+		content, _ := io.ReadAll(stdin)
+		f.files[args[1]] = string(content)
+	case "glob":
+		var matches []string
+		for path := range f.files {
+			if ok, _ := filepath.Match(filepath.Join(args[1], args[2]), path); ok {
+				matches = append(matches, path)
+			}
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			fmt.Fprintln(stdout, m)
+		}
+	case "mkdir":
+	case "remove":
+		if _, ok := f.files[args[1]]; !ok && len(args) == 2 {
+			return fakeExit(2)
+		}
+		delete(f.files, args[1])
+	default:
+		fmt.Fprintf(stderr, "networkd-api-helper: unknown operation: %s\n", args[0])
+		return fakeExit(1)
+	}
+	return nil
+}
+
+// splitShellQuoted reverses shellQuote for a space-separated argument list.
+func splitShellQuoted(s string) []string {
+	var args []string
+	var cur strings.Builder
+	quoted, inArg := false, false
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '\'':
+			quoted, inArg = !quoted, true
+		case ch == '\\' && !quoted && i+1 < len(s):
+			i++
+			cur.WriteByte(s[i])
+		case ch == ' ' && !quoted:
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteByte(ch)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+func TestSSHConnectorHelperCommands(t *testing.T) {
+	c, host := newFakeSSHConnector(t, map[string]string{"/etc/systemd/network/10-it's.network": "[Match]\n"})
+	if content, err := c.ReadConfigFile("10-it's.network"); err != nil || string(content) != "[Match]\n" {
+		t.Errorf("ReadConfigFile returned %q, %v", content, err)
+	}
+	if _, err := c.ReadConfigFile("20-missing.network"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+	if err := c.WriteConfigFile("20-eth1.network", []byte("[Match]\nName=eth1\n"), WriteOptions{Mode: 0640}); err != nil {
+		t.Fatal(err)
+	}
+	last := host.commands[len(host.commands)-1]
+	if !strings.Contains(last, "'write' '/etc/systemd/network/20-eth1.network' '0640' '-'") {
+		t.Errorf("Unexpected write command: %s", last)
+	}
+	if err := c.WriteConfigFile("20-eth1.network.d/mtu.conf", []byte("[Link]\nMTUBytes=9000\n"), WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if mkdir := host.commands[len(host.commands)-2]; !strings.HasSuffix(mkdir, "'mkdir' '/etc/systemd/network/20-eth1.network.d'") {
+		t.Errorf("Drop-in directory not created first: %s", mkdir)
+	}
+	if err := c.DeleteConfigFile("30-missing.network"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+	// A missing networkd.conf reads like on the local host
+	if content, err := c.GetGlobalConfig(); err != nil || content != "# /etc/systemd/networkd.conf\n# No configuration file found.\n" {
+		t.Errorf("GetGlobalConfig returned %q, %v", content, err)
+	}
+	host.fail = map[string]string{"read /etc/systemd/networkd.conf": "permission denied"}
+	if _, err := c.GetGlobalConfig(); err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected helper failure, got %v", err)
+	}
+}
+
+func TestSSHConnectorImportSources(t *testing.T) {
+	c, host := newFakeSSHConnector(t, map[string]string{
+		"/etc/netplan/01-netcfg.yaml": "network:\n  ethernets:\n    eth0:\n      dhcp4: true\n",
+		"/etc/netplan/README":         "not yaml",
+	})
+	paths, err := c.GlobHostFiles(NetplanPattern)
+	if err != nil || len(paths) != 1 || paths[0] != "/etc/netplan/01-netcfg.yaml" {
+		t.Fatalf("GlobHostFiles returned %v, %v", paths, err)
+	}
+	if content, err := c.ReadHostFile(paths[0]); err != nil || !strings.Contains(string(content), "eth0") {
+		t.Errorf("ReadHostFile returned %q, %v", content, err)
+	}
+	if _, err := c.ReadHostFile(IfupdownPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+	// Only the helper runs under sudo
+	for _, cmd := range host.commands {
+		if !strings.HasPrefix(cmd, "sudo "+RemoteHelperPath+" ") {
+			t.Errorf("Unexpected command: %s", cmd)
+		}
+	}
+}
+
+func TestSSHConnectorSessionAddrs(t *testing.T) {
+	c, host := newFakeSSHConnector(t, map[string]string{})
+	host.sshConnection = "198.51.100.7 50522 10.0.0.5 22"
+	if addrs, err := c.GetSessionAddrs(); err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.5" {
+		t.Errorf("GetSessionAddrs returned %v, %v", addrs, err)
+	}
+	host.sshConnection = "fe80::1%eth0 50522 fe80::2%eth0 22"
+	if addrs, err := c.GetSessionAddrs(); err != nil || len(addrs) != 1 || addrs[0] != "fe80::2" {
+		t.Errorf("GetSessionAddrs returned %v, %v", addrs, err)
+	}
+	// Without the address the management link cannot be protected
+	host.sshConnection = ""
+	if _, err := c.GetSessionAddrs(); err == nil {
+		t.Error("Expected an error without SSH_CONNECTION")
+	}
+}
+
+// startSFTPServer runs an SSH server on localhost that serves SFTP and
+// counts accepted connections. Returns its port and a client key file.
+func startSFTPServer(t *testing.T) (int, string, *atomic.Int32) {
+	_, hostKey, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, _ := ssh.NewSignerFromKey(hostKey)
+	_, clientKey, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) { return nil, nil },
+	}
+	config.AddHostKey(hostSigner)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var conns atomic.Int32
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(nc, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nch := range chans {
+					ch, chReqs, err := nch.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range chReqs {
+							ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+							req.Reply(ok, nil)
+							if ok {
+								server, _ := sftp.NewServer(ch)
+								go func() {
+									server.Serve()
+									ch.Close()
+								}()
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, keyFile, &conns
+}
+
+func TestSSHConnectorConnectsOnce(t *testing.T) {
+	port, keyFile, conns := startSFTPServer(t)
+	c := NewSSHConnector(HostConfig{Host: "127.0.0.1", Port: port, User: "root", KeyFile: keyFile}, "")
+	defer c.Close()
+
+	// The stats sampler and request handlers share connectors
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.ensureConnected()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("Expected a single connection, got %d", n)
+	}
+}
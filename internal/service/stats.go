@@ -0,0 +1,426 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InterfaceStats holds the kernel counters of one interface at a point in time.
+type InterfaceStats struct {
+	Name       string `json:"name"`
+	RxBytes    uint64 `json:"rx_bytes"`
+	TxBytes    uint64 `json:"tx_bytes"`
+	RxPackets  uint64 `json:"rx_packets"`
+	TxPackets  uint64 `json:"tx_packets"`
+	RxErrors   uint64 `json:"rx_errors"`
+	TxErrors   uint64 `json:"tx_errors"`
+	RxDropped  uint64 `json:"rx_dropped"`
+	TxDropped  uint64 `json:"tx_dropped"`
+	Multicast  uint64 `json:"multicast"`
+	Collisions uint64 `json:"collisions"`
+}
+
+// ipLinkStats is the subset of `ip -j -s link` output used for counters.
+type ipLinkStats struct {
+	IfName  string `json:"ifname"`
+	Stats64 struct {
+		Rx struct {
+			Bytes     uint64 `json:"bytes"`
+			Packets   uint64 `json:"packets"`
+			Errors    uint64 `json:"errors"`
+			Dropped   uint64 `json:"dropped"`
+			Multicast uint64 `json:"multicast"`
+		} `json:"rx"`
+		Tx struct {
+			Bytes      uint64 `json:"bytes"`
+			Packets    uint64 `json:"packets"`
+			Errors     uint64 `json:"errors"`
+			Dropped    uint64 `json:"dropped"`
+			Collisions uint64 `json:"collisions"`
+		} `json:"tx"`
+	} `json:"stats64"`
+}
+
+// parseIPLinkStats converts `ip -j -s link` output into InterfaceStats.
+func parseIPLinkStats(out []byte) ([]InterfaceStats, error) {
+	var links []ipLinkStats
+	if err := json.Unmarshal(out, &links); err != nil {
+		return nil, fmt.Errorf("failed to parse ip link statistics: %w", err)
+	}
+	stats := make([]InterfaceStats, 0, len(links))
+	for _, l := range links {
+		rx, tx := l.Stats64.Rx, l.Stats64.Tx
+		stats = append(stats, InterfaceStats{
+			Name:       l.IfName,
+			RxBytes:    rx.Bytes,
+			TxBytes:    tx.Bytes,
+			RxPackets:  rx.Packets,
+			TxPackets:  tx.Packets,
+			RxErrors:   rx.Errors,
+			TxErrors:   tx.Errors,
+			RxDropped:  rx.Dropped,
+			TxDropped:  tx.Dropped,
+			Multicast:  rx.Multicast,
+			Collisions: tx.Collisions,
+		})
+	}
+	return stats, nil
+}
+
+// readSysfsStats reads counters from <sysDir>/<iface>/statistics.
+func readSysfsStats(sysDir string) ([]InterfaceStats, error) {
+	entries, err := os.ReadDir(sysDir)
+	if err != nil {
+		return nil, err
+	}
+	read := func(iface, counter string) uint64 {
+		b, err := os.ReadFile(filepath.Join(sysDir, iface, "statistics", counter))
+		if err != nil {
+			return 0
+		}
+		v, _ := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+		return v
+	}
+
+	stats := make([]InterfaceStats, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		stats = append(stats, InterfaceStats{
+			Name:       name,
+			RxBytes:    read(name, "rx_bytes"),
+			TxBytes:    read(name, "tx_bytes"),
+			RxPackets:  read(name, "rx_packets"),
+			TxPackets:  read(name, "tx_packets"),
+			RxErrors:   read(name, "rx_errors"),
+			TxErrors:   read(name, "tx_errors"),
+			RxDropped:  read(name, "rx_dropped"),
+			TxDropped:  read(name, "tx_dropped"),
+			Multicast:  read(name, "multicast"),
+			Collisions: read(name, "collisions"),
+		})
+	}
+	return stats, nil
+}
+
+func (s *NetworkdService) GetInterfaceStats(host string) ([]InterfaceStats, error) {
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetInterfaceStats()
+}
+
+// StatsRates are per-second rates derived from two counter samples.
+type StatsRates struct {
+	RxBytesPerSec   float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec   float64 `json:"tx_bytes_per_sec"`
+	RxPacketsPerSec float64 `json:"rx_packets_per_sec"`
+	TxPacketsPerSec float64 `json:"tx_packets_per_sec"`
+	RxErrorsPerSec  float64 `json:"rx_errors_per_sec"`
+	TxErrorsPerSec  float64 `json:"tx_errors_per_sec"`
+	RxDroppedPerSec float64 `json:"rx_dropped_per_sec"`
+	TxDroppedPerSec float64 `json:"tx_dropped_per_sec"`
+}
+
+type StatsPoint struct {
+	Time time.Time `json:"time"`
+	StatsRates
+}
+
+// InterfaceRates summarises one interface over the requested window.
+type InterfaceRates struct {
+	Name    string         `json:"name"`
+	Current InterfaceStats `json:"current"`
+	Average StatsRates     `json:"average"`
+	Peak    StatsRates     `json:"peak"`
+	Series  []StatsPoint   `json:"series"`
+}
+
+type HostStats struct {
+	Host       string           `json:"host"`
+	Interval   string           `json:"interval"`
+	Since      time.Time        `json:"since"`
+	Samples    int              `json:"samples"`
+	LastError  string           `json:"last_error,omitempty"`
+	Interfaces []InterfaceRates `json:"interfaces"`
+}
+
+type statsSample struct {
+	time  time.Time
+	stats map[string]InterfaceStats
+}
+
+// StatsSampler periodically collects interface counters from the local host
+// and every registered host, keeping a rolling window per host in memory.
+type StatsSampler struct {
+	Service  *NetworkdService
+	Interval time.Duration
+	Window   time.Duration
+
+	mu      sync.Mutex
+	samples map[string][]statsSample
+	busy    map[string]bool
+	lastErr map[string]string
+	stop    chan struct{}
+}
+
+func NewStatsSampler(s *NetworkdService, interval, window time.Duration) *StatsSampler {
+	return &StatsSampler{
+		Service:  s,
+		Interval: interval,
+		Window:   window,
+		samples:  make(map[string][]statsSample),
+		busy:     make(map[string]bool),
+		lastErr:  make(map[string]string),
+	}
+}
+
+// Start launches the background sampling loop.
+func (ss *StatsSampler) Start() {
+	ss.mu.Lock()
+	if ss.stop != nil {
+		ss.mu.Unlock()
+		return
+	}
+	ss.stop = make(chan struct{})
+	stop := ss.stop
+	ss.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(ss.Interval)
+		defer ticker.Stop()
+		ss.sampleAll()
+		for {
+			select {
+			case <-ticker.C:
+				ss.sampleAll()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (ss *StatsSampler) Stop() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.stop != nil {
+		close(ss.stop)
+		ss.stop = nil
+	}
+}
+
+func (ss *StatsSampler) hosts() []string {
+	hosts := []string{"local"}
+	if ss.Service.HostManager != nil {
+		for _, h := range ss.Service.HostManager.ListHosts() {
+			hosts = append(hosts, h.Name)
+		}
+	}
+	return hosts
+}
+
+// sampleAll samples each host in its own goroutine so a slow or unreachable
+// host does not delay the others. A host still busy with its previous sample
+// is skipped for this tick. Samples of hosts that were removed are dropped.
+func (ss *StatsSampler) sampleAll() {
+	hosts := ss.hosts()
+	ss.prune(hosts)
+	for _, host := range hosts {
+		ss.mu.Lock()
+		if ss.busy[host] {
+			ss.mu.Unlock()
+			continue
+		}
+		ss.busy[host] = true
+		ss.mu.Unlock()
+
+		go func(host string) {
+			ss.sample(host)
+			ss.mu.Lock()
+			ss.busy[host] = false
+			ss.mu.Unlock()
+		}(host)
+	}
+}
+
+// prune forgets every host not in hosts.
+func (ss *StatsSampler) prune(hosts []string) {
+	keep := make(map[string]bool, len(hosts))
+	for _, h := range hosts {
+		keep[h] = true
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for h := range ss.samples {
+		if !keep[h] {
+			delete(ss.samples, h)
+		}
+	}
+	for h := range ss.lastErr {
+		if !keep[h] {
+			delete(ss.lastErr, h)
+		}
+	}
+	for h, busy := range ss.busy {
+		if !keep[h] && !busy {
+			delete(ss.busy, h)
+		}
+	}
+}
+
+func (ss *StatsSampler) sample(host string) {
+	stats, err := ss.Service.GetInterfaceStats(host)
+	now := time.Now()
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if err != nil {
+		ss.lastErr[host] = err.Error()
+		return
+	}
+	delete(ss.lastErr, host)
+
+	byName := make(map[string]InterfaceStats, len(stats))
+	for _, st := range stats {
+		byName[st.Name] = st
+	}
+	samples := append(ss.samples[host], statsSample{time: now, stats: byName})
+	cutoff := now.Add(-ss.Window)
+	for len(samples) > 0 && samples[0].time.Before(cutoff) {
+		samples = samples[1:]
+	}
+	ss.samples[host] = samples
+}
+
+// counterDelta returns the increase of a counter, treating a decrease
+// (interface recreated, counters reset) as no traffic.
+func counterDelta(prev, cur uint64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur - prev)
+}
+
+func ratesBetween(prev, cur InterfaceStats, seconds float64) StatsRates {
+	return StatsRates{
+		RxBytesPerSec:   counterDelta(prev.RxBytes, cur.RxBytes) / seconds,
+		TxBytesPerSec:   counterDelta(prev.TxBytes, cur.TxBytes) / seconds,
+		RxPacketsPerSec: counterDelta(prev.RxPackets, cur.RxPackets) / seconds,
+		TxPacketsPerSec: counterDelta(prev.TxPackets, cur.TxPackets) / seconds,
+		RxErrorsPerSec:  counterDelta(prev.RxErrors, cur.RxErrors) / seconds,
+		TxErrorsPerSec:  counterDelta(prev.TxErrors, cur.TxErrors) / seconds,
+		RxDroppedPerSec: counterDelta(prev.RxDropped, cur.RxDropped) / seconds,
+		TxDroppedPerSec: counterDelta(prev.TxDropped, cur.TxDropped) / seconds,
+	}
+}
+
+func maxRates(a, b StatsRates) StatsRates {
+	pick := func(x, y float64) float64 {
+		if y > x {
+			return y
+		}
+		return x
+	}
+	return StatsRates{
+		RxBytesPerSec:   pick(a.RxBytesPerSec, b.RxBytesPerSec),
+		TxBytesPerSec:   pick(a.TxBytesPerSec, b.TxBytesPerSec),
+		RxPacketsPerSec: pick(a.RxPacketsPerSec, b.RxPacketsPerSec),
+		TxPacketsPerSec: pick(a.TxPacketsPerSec, b.TxPacketsPerSec),
+		RxErrorsPerSec:  pick(a.RxErrorsPerSec, b.RxErrorsPerSec),
+		TxErrorsPerSec:  pick(a.TxErrorsPerSec, b.TxErrorsPerSec),
+		RxDroppedPerSec: pick(a.RxDroppedPerSec, b.RxDroppedPerSec),
+		TxDroppedPerSec: pick(a.TxDroppedPerSec, b.TxDroppedPerSec),
+	}
+}
+
+// Rates computes per-interface rates for a host over the last `since`
+// duration. If nothing was sampled for the host yet, one sample is taken
+// now so that at least current counters are returned.
+func (ss *StatsSampler) Rates(host string, since time.Duration) (*HostStats, error) {
+	host = normalizeHost(host)
+	if host != "local" {
+		if _, ok := ss.Service.HostManager.GetHost(host); !ok {
+			return nil, fmt.Errorf("unknown host: %s", host)
+		}
+	}
+	if since <= 0 || since > ss.Window {
+		since = ss.Window
+	}
+
+	ss.mu.Lock()
+	empty := len(ss.samples[host]) == 0
+	ss.mu.Unlock()
+	if empty {
+		ss.sample(host)
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	cutoff := time.Now().Add(-since)
+	var window []statsSample
+	for _, smp := range ss.samples[host] {
+		if !smp.time.Before(cutoff) {
+			window = append(window, smp)
+		}
+	}
+
+	result := &HostStats{
+		Host:       host,
+		Interval:   ss.Interval.String(),
+		Since:      cutoff,
+		Samples:    len(window),
+		LastError:  ss.lastErr[host],
+		Interfaces: []InterfaceRates{},
+	}
+	if len(window) == 0 {
+		if result.LastError != "" {
+			return nil, fmt.Errorf("no samples for %s: %s", host, result.LastError)
+		}
+		return result, nil
+	}
+
+	latest := window[len(window)-1]
+	names := make([]string, 0, len(latest.stats))
+	for name := range latest.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		ir := InterfaceRates{Name: name, Current: latest.stats[name], Series: []StatsPoint{}}
+		var first *statsSample
+		for i := 1; i < len(window); i++ {
+			prev, okPrev := window[i-1].stats[name]
+			cur, okCur := window[i].stats[name]
+			if !okPrev || !okCur {
+				continue
+			}
+			if first == nil {
+				first = &window[i-1]
+			}
+			seconds := window[i].time.Sub(window[i-1].time).Seconds()
+			if seconds <= 0 {
+				continue
+			}
+			rates := ratesBetween(prev, cur, seconds)
+			ir.Series = append(ir.Series, StatsPoint{Time: window[i].time, StatsRates: rates})
+			ir.Peak = maxRates(ir.Peak, rates)
+		}
+		if first != nil {
+			if seconds := latest.time.Sub(first.time).Seconds(); seconds > 0 {
+				ir.Average = ratesBetween(first.stats[name], latest.stats[name], seconds)
+			}
+		}
+		result.Interfaces = append(result.Interfaces, ir)
+	}
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatsRates(t *testing.T) {
+	tmpDir := t.TempDir()
+	hm, _ := NewHostManager(tmpDir)
+	ss := NewStatsSampler(&NetworkdService{HostManager: hm}, 10*time.Second, time.Hour)
+
+	now := time.Now()
+	ss.samples["local"] = []statsSample{
+		{time: now.Add(-20 * time.Second), stats: map[string]InterfaceStats{"eth0": {Name: "eth0", RxBytes: 1000, RxErrors: 0}}},
+		{time: now.Add(-10 * time.Second), stats: map[string]InterfaceStats{"eth0": {Name: "eth0", RxBytes: 11000, RxErrors: 5}}},
+		// Counter reset (interface recreated) must not produce a negative rate
+		{time: now, stats: map[string]InterfaceStats{"eth0": {Name: "eth0", RxBytes: 500, RxErrors: 0}}},
+	}
+
+	hs, err := ss.Rates("", 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hs.Samples != 3 || len(hs.Interfaces) != 1 {
+		t.Fatalf("Unexpected result: %+v", hs)
+	}
+	eth0 := hs.Interfaces[0]
+	if len(eth0.Series) != 2 || eth0.Series[0].RxBytesPerSec != 1000 || eth0.Series[1].RxBytesPerSec != 0 {
+		t.Errorf("Unexpected series: %+v", eth0.Series)
+	}
+	if eth0.Peak.RxErrorsPerSec != 0.5 || eth0.Current.RxBytes != 500 {
+		t.Errorf("Unexpected peak/current: %+v %+v", eth0.Peak, eth0.Current)
+	}
+}
+
+func TestReadSysfsStats(t *testing.T) {
+	sysDir := t.TempDir()
+	statsDir := filepath.Join(sysDir, "eth0", "statistics")
+	os.MkdirAll(statsDir, 0755)
+	os.WriteFile(filepath.Join(statsDir, "rx_bytes"), []byte("1234\n"), 0644)
+	os.WriteFile(filepath.Join(statsDir, "tx_dropped"), []byte("7\n"), 0644)
+
+	stats, err := readSysfsStats(sysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].RxBytes != 1234 || stats[0].TxDropped != 7 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestStatsPrunesRemovedHosts(t *testing.T) {
+	tmpDir := t.TempDir()
+	hm, _ := NewHostManager(tmpDir)
+	hm.AddHost(HostConfig{Name: "edge", Host: "192.0.2.10"})
+	ss := NewStatsSampler(&NetworkdService{HostManager: hm}, 10*time.Second, time.Hour)
+	for _, host := range []string{"local", "edge", "gone"} {
+		ss.samples[host] = []statsSample{{time: time.Now()}}
+		ss.lastErr[host] = "unreachable"
+	}
+
+	ss.prune(ss.hosts())
+	if _, ok := ss.samples["gone"]; ok {
+		t.Error("Samples of removed host kept")
+	}
+	if _, ok := ss.lastErr["gone"]; ok {
+		t.Error("Error of removed host kept")
+	}
+	if len(ss.samples["local"]) != 1 || len(ss.samples["edge"]) != 1 {
+		t.Errorf("Samples of registered hosts dropped: %v", ss.samples)
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wireGuardHandshakeTimeout is how long a peer counts as up after its last
+// handshake; WireGuard rekeys every 2 minutes and gives up after 3.
+const wireGuardHandshakeTimeout = 180 * time.Second
+
+// tunnelKinds are the link kinds reported by GetTunnels.
+var tunnelKinds = map[string]bool{
+	"wireguard": true,
+	"gre":       true,
+	"gretap":    true,
+	"ip6gre":    true,
+	"ip6gretap": true,
+	"erspan":    true,
+	"ip6erspan": true,
+	"ipip":      true,
+	"sit":       true,
+	"ip6tnl":    true,
+	"vti":       true,
+	"vti6":      true,
+	"vxlan":     true,
+	"geneve":    true,
+}
+
+type WireGuardPeerStatus struct {
+	PublicKey           string     `json:"public_key"`
+	Endpoint            string     `json:"endpoint,omitempty"`
+	AllowedIPs          []string   `json:"allowed_ips"`
+	LatestHandshake     *time.Time `json:"latest_handshake,omitempty"`
+	RxBytes             int64      `json:"rx_bytes"`
+	TxBytes             int64      `json:"tx_bytes"`
+	PersistentKeepalive int        `json:"persistent_keepalive,omitempty"`
+	// Up is true when the last handshake is recent enough for the session to be valid
+	Up bool `json:"up"`
+}
+
+type WireGuardStatus struct {
+	PublicKey  string                `json:"public_key"`
+	ListenPort int                   `json:"listen_port,omitempty"`
+	FwMark     string                `json:"fwmark,omitempty"`
+	Peers      []WireGuardPeerStatus `json:"peers"`
+}
+
+// TunnelStatus is the runtime state of a tunnel interface.
+type TunnelStatus struct {
+	Name             string           `json:"name"`
+	Kind             string           `json:"kind"`
+	OperationalState string           `json:"operational_state"`
+	Local            string           `json:"local,omitempty"`
+	Remote           string           `json:"remote,omitempty"`
+	InputKey         string           `json:"input_key,omitempty"`
+	OutputKey        string           `json:"output_key,omitempty"`
+	VNI              int              `json:"vni,omitempty"` // VXLAN/Geneve network identifier
+	Port             int              `json:"port,omitempty"`
+	TTL              int              `json:"ttl,omitempty"`
+	WireGuard        *WireGuardStatus `json:"wireguard,omitempty"`
+}
+
+// ipLinkDetail is the subset of `ip -d -j link show` used for tunnels.
+type ipLinkDetail struct {
+	IfName    string `json:"ifname"`
+	OperState string `json:"operstate"`
+	LinkInfo  *struct {
+		InfoKind string                 `json:"info_kind"`
+		InfoData map[string]interface{} `json:"info_data"`
+	} `json:"linkinfo"`
+}
+
+// parseTunnelLinks extracts tunnel interfaces from `ip -d -j link show` output.
+func parseTunnelLinks(out []byte) ([]TunnelStatus, error) {
+	var links []ipLinkDetail
+	if err := json.Unmarshal(out, &links); err != nil {
+		return nil, fmt.Errorf("failed to parse ip link output: %w", err)
+	}
+
+	tunnels := []TunnelStatus{}
+	for _, l := range links {
+		if l.LinkInfo == nil || !tunnelKinds[l.LinkInfo.InfoKind] {
+			continue
+		}
+		t := TunnelStatus{
+			Name:             l.IfName,
+			Kind:             l.LinkInfo.InfoKind,
+			OperationalState: strings.ToLower(l.OperState),
+		}
+		data := l.LinkInfo.InfoData
+		str := func(key string) string {
+			if v, ok := data[key]; ok && v != nil {
+				return fmt.Sprintf("%v", v)
+			}
+			return ""
+		}
+		num := func(key string) int {
+			if v, ok := data[key].(float64); ok {
+				return int(v)
+			}
+			return 0
+		}
+		t.Local = str("local")
+		t.Remote = str("remote")
+		if t.Remote == "" {
+			// VXLAN uses a multicast group instead of a single remote
+			t.Remote = str("group")
+		}
+		t.InputKey = str("ikey")
+		t.OutputKey = str("okey")
+		t.VNI = num("id")
+		t.Port = num("port")
+		t.TTL = num("ttl")
+		tunnels = append(tunnels, t)
+	}
+	return tunnels, nil
+}
+
+// parseWireGuardDump parses `wg show all dump`. Interface lines carry the
+// private key, which is deliberately dropped, as are preshared keys.
+func parseWireGuardDump(out string, now time.Time) map[string]*WireGuardStatus {
+	result := make(map[string]*WireGuardStatus)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		switch len(fields) {
+		case 5:
+			// iface, private-key, public-key, listen-port, fwmark
+			port, _ := strconv.Atoi(fields[3])
+			st := &WireGuardStatus{PublicKey: fields[2], ListenPort: port, Peers: []WireGuardPeerStatus{}}
+			if fields[4] != "off" {
+				st.FwMark = fields[4]
+			}
+			result[fields[0]] = st
+		case 9:
+			// iface, public-key, preshared-key, endpoint, allowed-ips,
+			// latest-handshake, transfer-rx, transfer-tx, persistent-keepalive
+			st, ok := result[fields[0]]
+			if !ok {
+				continue
+			}
+			peer := WireGuardPeerStatus{PublicKey: fields[1], AllowedIPs: []string{}}
+			if fields[3] != "(none)" {
+				peer.Endpoint = fields[3]
+			}
+			if fields[4] != "(none)" {
+				peer.AllowedIPs = strings.Split(fields[4], ",")
+			}
+			if ts, err := strconv.ParseInt(fields[5], 10, 64); err == nil && ts > 0 {
+				hs := time.Unix(ts, 0).UTC()
+				peer.LatestHandshake = &hs
+				peer.Up = now.Sub(hs) < wireGuardHandshakeTimeout
+			}
+			peer.RxBytes, _ = strconv.ParseInt(fields[6], 10, 64)
+			peer.TxBytes, _ = strconv.ParseInt(fields[7], 10, 64)
+			if fields[8] != "off" {
+				peer.PersistentKeepalive, _ = strconv.Atoi(fields[8])
+			}
+			st.Peers = append(st.Peers, peer)
+		}
+	}
+	return result
+}
+
+// mergeTunnelStatus attaches WireGuard details to the matching tunnels.
+func mergeTunnelStatus(tunnels []TunnelStatus, wg map[string]*WireGuardStatus) []TunnelStatus {
+	for i := range tunnels {
+		if st, ok := wg[tunnels[i].Name]; ok {
+			tunnels[i].WireGuard = st
+		}
+	}
+	return tunnels
+}
+
+func (s *NetworkdService) GetTunnels(host string) ([]TunnelStatus, error) {
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetTunnels()
+}
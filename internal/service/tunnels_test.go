@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTunnelLinks(t *testing.T) {
+	out := []byte(`[
+		{"ifname":"eth0","operstate":"UP"},
+		{"ifname":"gre1","operstate":"UNKNOWN","linkinfo":{"info_kind":"gre","info_data":{"local":"192.0.2.1","remote":"198.51.100.7","ikey":"0.0.0.42","okey":"0.0.0.42","ttl":64}}},
+		{"ifname":"vx100","operstate":"UP","linkinfo":{"info_kind":"vxlan","info_data":{"id":100,"group":"239.1.1.1","local":"192.0.2.1","port":4789}}},
+		{"ifname":"br0","operstate":"UP","linkinfo":{"info_kind":"bridge","info_data":{}}}
+	]`)
+
+	tunnels, err := parseTunnelLinks(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tunnels) != 2 {
+		t.Fatalf("Expected 2 tunnels, got %d: %+v", len(tunnels), tunnels)
+	}
+	gre := tunnels[0]
+	if gre.Kind != "gre" || gre.Remote != "198.51.100.7" || gre.InputKey != "0.0.0.42" || gre.TTL != 64 || gre.OperationalState != "unknown" {
+		t.Errorf("Unexpected GRE status: %+v", gre)
+	}
+	vx := tunnels[1]
+	if vx.VNI != 100 || vx.Remote != "239.1.1.1" || vx.Port != 4789 {
+		t.Errorf("Unexpected VXLAN status: %+v", vx)
+	}
+}
+
+func TestParseWireGuardDump(t *testing.T) {
+	now := time.Unix(1700000100, 0)
+	dump := "wg0\tPRIVATE\tpubA=\t51820\toff\n" +
+		"wg0\tpeer1=\tPSK\t198.51.100.7:51820\t10.99.0.2/32,fd00::2/128\t1700000000\t1024\t2048\t25\n" +
+		"wg0\tpeer2=\t(none)\t(none)\t(none)\t0\t0\t0\toff\n"
+
+	st := parseWireGuardDump(dump, now)["wg0"]
+	if st == nil || st.PublicKey != "pubA=" || st.ListenPort != 51820 || len(st.Peers) != 2 {
+		t.Fatalf("Unexpected interface status: %+v", st)
+	}
+	p1 := st.Peers[0]
+	if !p1.Up || p1.Endpoint != "198.51.100.7:51820" || len(p1.AllowedIPs) != 2 || p1.TxBytes != 2048 || p1.PersistentKeepalive != 25 {
+		t.Errorf("Unexpected peer1 status: %+v", p1)
+	}
+	p2 := st.Peers[1]
+	if p2.Up || p2.LatestHandshake != nil || p2.Endpoint != "" || len(p2.AllowedIPs) != 0 {
+		t.Errorf("Unexpected peer2 status: %+v", p2)
+	}
+}
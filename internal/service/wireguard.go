@@ -0,0 +1,373 @@
+package service
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"strings"
+)
+
+// GenerateWireGuardKeyPair creates a Curve25519 key pair in the same format
+// as `wg genkey | wg pubkey` (base64, clamped private key).
+func GenerateWireGuardKeyPair() (privateKey, publicKey string, err error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", "", err
+	}
+	key[0] &= 248
+	key[31] = (key[31] & 127) | 64
+
+	privateKey = base64.StdEncoding.EncodeToString(key)
+	publicKey, err = WireGuardPublicKey(privateKey)
+	return privateKey, publicKey, err
+}
+
+// WireGuardPublicKey derives the public key from a base64 private key.
+func WireGuardPublicKey(privateKey string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(privateKey))
+	if err != nil || len(raw) != 32 {
+		return "", fmt.Errorf("invalid WireGuard private key")
+	}
+	priv, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid WireGuard private key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(priv.PublicKey().Bytes()), nil
+}
+
+// validWireGuardKey checks that a key is 32 bytes of base64.
+func validWireGuardKey(key string) bool {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(raw) == 32
+}
+
+// keyFileName names a key file after the config it belongs to, e.g.
+// "50-wg0.WireGuard0.PrivateKey.key".
+func keyFileName(configFilename, id, key string) string {
+	base := strings.TrimSuffix(configFilename, filepath.Ext(configFilename))
+	return fmt.Sprintf("%s.%s.%s.key", base, id, key)
+}
+
+// GenerateWireGuardKeyOnHost generates a private key on the host itself and
+// stores it in keyFile inside the host's config directory, so the private key
+// never passes through the API. Returns the public key and the absolute path
+// of the key file for use in PrivateKeyFile=.
+func (s *NetworkdService) GenerateWireGuardKeyOnHost(host, keyFile string) (publicKey, keyPath string, err error) {
+	if err := validateKeyFilename(keyFile); err != nil {
+		return "", "", err
+	}
+	c, err := s.GetConnector(host)
+	if err != nil {
+		return "", "", err
+	}
+	publicKey, err = c.GenerateWireGuardKey(keyFile, s.KeyFileWriteOptions)
+	if err != nil {
+		return "", "", err
+	}
+	return publicKey, filepath.Join(c.GetConfigDir(), keyFile), nil
+}
+
+// readWireGuardNetDev loads and parses a .netdev file, checking that it is a WireGuard device.
+func (s *NetworkdService) readWireGuardNetDev(host, filename string) (map[string]interface{}, error) {
+	if !strings.HasSuffix(filename, ".netdev") {
+		return nil, fmt.Errorf("not a .netdev file: %s", filename)
+	}
+	content, err := s.ReadNetworkFile(host, filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := INIToMap(content, s.Schema, "netdev")
+	if err != nil {
+		return nil, err
+	}
+	netdev, _ := cfg["NetDev"].(map[string]interface{})
+	if netdev == nil || !strings.EqualFold(fmt.Sprintf("%v", netdev["Kind"]), "wireguard") {
+		return nil, fmt.Errorf("%s is not a WireGuard netdev", filename)
+	}
+	return cfg, nil
+}
+
+func (s *NetworkdService) writeNetDev(host, filename string, cfg map[string]interface{}) error {
+	if err := s.Schema.Validate("netdev", cfg); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	content, err := MapToINI(cfg, s.Schema, "netdev")
+	if err != nil {
+		return err
+	}
+	return s.WriteNetworkFile(host, filename, content)
+}
+
+// AddWireGuardPeer appends a [WireGuardPeer] section to a WireGuard .netdev.
+// A peer with the same PublicKey is replaced rather than duplicated.
+func (s *NetworkdService) AddWireGuardPeer(host, filename string, peer map[string]interface{}) error {
+	publicKey, _ := peer["PublicKey"].(string)
+	if !validWireGuardKey(publicKey) {
+		return fmt.Errorf("peer requires a valid PublicKey")
+	}
+	cfg, err := s.readWireGuardNetDev(host, filename)
+	if err != nil {
+		return err
+	}
+
+	peers := []interface{}{}
+	for _, p := range sectionItems(cfg["WireGuardPeer"]) {
+		if p["PublicKey"] != publicKey {
+			peers = append(peers, p)
+		}
+	}
+	cfg["WireGuardPeer"] = append(peers, peer)
+	return s.writeNetDev(host, filename, cfg)
+}
+
+// RemoveWireGuardPeer drops the [WireGuardPeer] section with the given PublicKey.
+func (s *NetworkdService) RemoveWireGuardPeer(host, filename, publicKey string) error {
+	cfg, err := s.readWireGuardNetDev(host, filename)
+	if err != nil {
+		return err
+	}
+
+	peers := []interface{}{}
+	for _, p := range sectionItems(cfg["WireGuardPeer"]) {
+		if p["PublicKey"] != publicKey {
+			peers = append(peers, p)
+		}
+	}
+	if len(peers) == len(sectionItems(cfg["WireGuardPeer"])) {
+		return fmt.Errorf("peer not found: %s", publicKey)
+	}
+	if len(peers) == 0 {
+		delete(cfg, "WireGuardPeer")
+	} else {
+		cfg["WireGuardPeer"] = peers
+	}
+	return s.writeNetDev(host, filename, cfg)
+}
+
+// WireGuardSite describes one end of a point-to-point WireGuard link.
+type WireGuardSite struct {
+	Host       string `json:"host"`        // Managed host name ("" or "local" for this machine)
+	Endpoint   string `json:"endpoint"`    // Address peers use to reach this site, optionally with :port
+	ListenPort int    `json:"listen_port"` // Defaults to 51820
+}
+
+// WireGuardLinkRequest asks for a tunnel between two managed hosts.
+type WireGuardLinkRequest struct {
+	Interface           string        `json:"interface"`   // Defaults to "wg0"
+	FilePrefix          string        `json:"file_prefix"` // Defaults to "50"
+	Subnet              string        `json:"subnet"`      // Tunnel subnet, e.g. "10.99.0.0/30"
+	PersistentKeepalive int           `json:"persistent_keepalive,omitempty"`
+	A                   WireGuardSite `json:"a"`
+	B                   WireGuardSite `json:"b"`
+}
+
+// WireGuardLinkSide reports what was created on one host.
+type WireGuardLinkSide struct {
+	Host      string   `json:"host"`
+	Address   string   `json:"address"`
+	PublicKey string   `json:"public_key"`
+	Files     []string `json:"files"`
+}
+
+type WireGuardLinkResult struct {
+	Interface string            `json:"interface"`
+	A         WireGuardLinkSide `json:"a"`
+	B         WireGuardLinkSide `json:"b"`
+}
+
+// allocateTunnelAddresses returns the first two usable addresses of a subnet
+// with the subnet's prefix length. /31 and /127 use both addresses.
+func allocateTunnelAddresses(subnet string) (netip.Prefix, netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(subnet)
+	if err != nil {
+		return netip.Prefix{}, netip.Prefix{}, fmt.Errorf("invalid subnet: %w", err)
+	}
+	prefix = prefix.Masked()
+	bits := prefix.Addr().BitLen() - prefix.Bits()
+	if bits < 1 {
+		return netip.Prefix{}, netip.Prefix{}, fmt.Errorf("subnet %s is too small for two addresses", subnet)
+	}
+
+	first := prefix.Addr()
+	if bits > 1 {
+		// Skip the network address
+		first = first.Next()
+	}
+	second := first.Next()
+	last := second
+	if bits > 1 && prefix.Addr().Is4() {
+		// The broadcast address must stay free
+		last = second.Next()
+	}
+	if !prefix.Contains(last) {
+		return netip.Prefix{}, netip.Prefix{}, fmt.Errorf("subnet %s is too small for two addresses", subnet)
+	}
+	return netip.PrefixFrom(first, prefix.Bits()), netip.PrefixFrom(second, prefix.Bits()), nil
+}
+
+func wireGuardEndpoint(site WireGuardSite) string {
+	if site.Endpoint == "" {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(site.Endpoint); err == nil {
+		return site.Endpoint
+	}
+	if strings.Contains(site.Endpoint, ":") {
+		// Bare IPv6 address
+		return fmt.Sprintf("[%s]:%d", site.Endpoint, site.ListenPort)
+	}
+	return fmt.Sprintf("%s:%d", site.Endpoint, site.ListenPort)
+}
+
+// LinkWireGuardHosts creates a WireGuard tunnel between two managed hosts:
+// a key pair is generated on each host, and each gets a .netdev with the
+// other as its peer plus a .network assigning its tunnel address. Neither
+// file may exist yet on either host. If a step fails, every file touched so
+// far, key files included, is restored to its previous state.
+func (s *NetworkdService) LinkWireGuardHosts(req WireGuardLinkRequest) (*WireGuardLinkResult, error) {
+	if req.Interface == "" {
+		req.Interface = "wg0"
+	}
+	if req.FilePrefix == "" {
+		req.FilePrefix = "50"
+	}
+	if req.A.ListenPort == 0 {
+		req.A.ListenPort = 51820
+	}
+	if req.B.ListenPort == 0 {
+		req.B.ListenPort = 51820
+	}
+	if req.A.Endpoint == "" && req.B.Endpoint == "" {
+		return nil, fmt.Errorf("at least one side needs an endpoint")
+	}
+	if normalizeHost(req.A.Host) == normalizeHost(req.B.Host) {
+		return nil, fmt.Errorf("both sides refer to the same host")
+	}
+	addrA, addrB, err := allocateTunnelAddresses(req.Subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	base := fmt.Sprintf("%s-%s", req.FilePrefix, req.Interface)
+	netdevFile := base + ".netdev"
+	networkFile := base + ".network"
+	keyFile := keyFileName(netdevFile, "WireGuard0", "PrivateKey")
+
+	type hostSnapshot struct {
+		host string
+		snap configSnapshot
+	}
+	var snapshots []hostSnapshot
+	for _, site := range []WireGuardSite{req.A, req.B} {
+		for _, name := range []string{netdevFile, networkFile, keyFile} {
+			snap, err := s.snapshotConfigPath(site.Host, name)
+			if err != nil {
+				return nil, fmt.Errorf("host %s: %w", normalizeHost(site.Host), err)
+			}
+			if snap.existed && name != keyFile {
+				return nil, fmt.Errorf("%s already exists on host %s", name, normalizeHost(site.Host))
+			}
+			snapshots = append(snapshots, hostSnapshot{site.Host, snap})
+		}
+	}
+	// fail restores every snapshot and reports rollback errors with err
+	fail := func(err error) (*WireGuardLinkResult, error) {
+		errs := []error{err}
+		for i := len(snapshots) - 1; i >= 0; i-- {
+			hs := snapshots[i]
+			if rerr := s.restoreConfigPath(hs.host, hs.snap); rerr != nil {
+				errs = append(errs, fmt.Errorf("rollback of %s on %s failed: %w", hs.snap.path, normalizeHost(hs.host), rerr))
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	pubA, keyPathA, err := s.GenerateWireGuardKeyOnHost(req.A.Host, keyFile)
+	if err != nil {
+		return fail(fmt.Errorf("key generation on %s failed: %w", normalizeHost(req.A.Host), err))
+	}
+	pubB, keyPathB, err := s.GenerateWireGuardKeyOnHost(req.B.Host, keyFile)
+	if err != nil {
+		return fail(fmt.Errorf("key generation on %s failed: %w", normalizeHost(req.B.Host), err))
+	}
+
+	build := func(self, peer WireGuardSite, keyPath, peerPub string, selfAddr, peerAddr netip.Prefix) (map[string]interface{}, map[string]interface{}) {
+		peerSection := map[string]interface{}{
+			"PublicKey":  peerPub,
+			"AllowedIPs": []string{netip.PrefixFrom(peerAddr.Addr(), peerAddr.Addr().BitLen()).String()},
+		}
+		if ep := wireGuardEndpoint(peer); ep != "" {
+			peerSection["Endpoint"] = ep
+		}
+		if req.PersistentKeepalive > 0 {
+			peerSection["PersistentKeepalive"] = req.PersistentKeepalive
+		}
+		netdev := map[string]interface{}{
+			"NetDev": map[string]interface{}{"Name": req.Interface, "Kind": "wireguard"},
+			"WireGuard": map[string]interface{}{
+				"PrivateKeyFile": keyPath,
+				"ListenPort":     self.ListenPort,
+			},
+			"WireGuardPeer": []interface{}{peerSection},
+		}
+		network := map[string]interface{}{
+			"Match":   map[string]interface{}{"Name": req.Interface},
+			"Network": map[string]interface{}{"Address": []string{selfAddr.String()}},
+		}
+		return netdev, network
+	}
+
+	netdevA, networkA := build(req.A, req.B, keyPathA, pubB, addrA, addrB)
+	netdevB, networkB := build(req.B, req.A, keyPathB, pubA, addrB, addrA)
+
+	// Validate everything before touching either host
+	for _, cfg := range []map[string]interface{}{netdevA, netdevB} {
+		if err := s.Schema.Validate("netdev", cfg); err != nil {
+			return fail(fmt.Errorf("validation failed: %w", err))
+		}
+	}
+	for _, cfg := range []map[string]interface{}{networkA, networkB} {
+		if err := s.Schema.Validate("network", cfg); err != nil {
+			return fail(fmt.Errorf("validation failed: %w", err))
+		}
+	}
+
+	type pending struct {
+		host, filename, configType string
+		cfg                        map[string]interface{}
+	}
+	steps := []pending{
+		{req.A.Host, netdevFile, "netdev", netdevA},
+		{req.A.Host, networkFile, "network", networkA},
+		{req.B.Host, netdevFile, "netdev", netdevB},
+		{req.B.Host, networkFile, "network", networkB},
+	}
+	for _, st := range steps {
+		content, err := MapToINI(st.cfg, s.Schema, st.configType)
+		if err == nil {
+			err = s.WriteNetworkFile(st.host, st.filename, content)
+		}
+		if err != nil {
+			return fail(fmt.Errorf("writing %s on %s failed: %w", st.filename, normalizeHost(st.host), err))
+		}
+	}
+
+	files := []string{netdevFile, networkFile, keyFile}
+	return &WireGuardLinkResult{
+		Interface: req.Interface,
+		A:         WireGuardLinkSide{Host: normalizeHost(req.A.Host), Address: addrA.String(), PublicKey: pubA, Files: files},
+		B:         WireGuardLinkSide{Host: normalizeHost(req.B.Host), Address: addrB.String(), PublicKey: pubB, Files: files},
+	}, nil
+}
+
+func normalizeHost(host string) string {
+	if host == "" {
+		return "local"
+	}
+	return host
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestService returns a service managing tmpDir locally, with permissive
+// network/netdev schemas.
+func newTestService(t *testing.T) (*NetworkdService, string) {
+	tmpDir := t.TempDir()
+	hm, _ := NewHostManager(tmpDir)
+	schema := &SchemaService{
+		Schemas:            map[string]map[string]interface{}{"network": {}, "netdev": {}},
+		TypeCache:          make(map[string]map[string]map[string]TypeInfo),
+		RepeatableSections: make(map[string]map[string]bool),
+	}
+	return &NetworkdService{
+		ConfigDir:        tmpDir,
+		DataDir:          tmpDir,
+		Schema:           schema,
+		LocalConnector:   NewLocalConnector(tmpDir, filepath.Join(tmpDir, "networkd.conf"), nil),
+		HostManager:      hm,
+		RemoteConnectors: make(map[string]*SSHConnector),
+	}, tmpDir
+}
+
+func TestLinkWireGuardHostsRollsBack(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	svc.HostManager.AddHost(HostConfig{Name: "edge", Host: "192.0.2.10", User: "networkd-api", Port: 22})
+	edge, fake := newFakeSSHConnector(t, map[string]string{})
+	svc.RemoteConnectors["edge"] = edge
+
+	keyFile := filepath.Join(tmpDir, "50-wg0.WireGuard0.PrivateKey.key")
+	os.WriteFile(keyFile, []byte("previous key\n"), 0600)
+	req := WireGuardLinkRequest{
+		Subnet: "10.99.0.0/30",
+		A:      WireGuardSite{Host: "local", Endpoint: "198.51.100.1"},
+		B:      WireGuardSite{Host: "edge"},
+	}
+
+	// The fake host cannot run wg genkey, so the second host fails after
+	// the first one already has a new key
+	if _, err := svc.LinkWireGuardHosts(req); err == nil || !strings.Contains(err.Error(), "key generation on edge failed") {
+		t.Fatalf("Expected key generation on edge to fail, got %v", err)
+	}
+	if content, _ := os.ReadFile(keyFile); string(content) != "previous key\n" {
+		t.Errorf("Key file not restored: %q", content)
+	}
+	for _, name := range []string{"50-wg0.netdev", "50-wg0.network"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s left behind", name)
+		}
+	}
+	if len(fake.files) != 0 {
+		t.Errorf("Files left on edge: %v", fake.files)
+	}
+
+	// An existing .network on the remote side is not overwritten
+	fake.files["/etc/systemd/network/50-wg0.network"] = "[Match]\nName=wg0\n"
+	if _, err := svc.LinkWireGuardHosts(req); err == nil || !strings.Contains(err.Error(), "50-wg0.network already exists on host edge") {
+		t.Fatalf("Expected existing .network to be refused, got %v", err)
+	}
+	if fake.files["/etc/systemd/network/50-wg0.network"] != "[Match]\nName=wg0\n" {
+		t.Errorf("Existing .network changed: %v", fake.files)
+	}
+}
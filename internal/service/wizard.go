@@ -0,0 +1,398 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// GeneratedFile is one config file produced by a topology wizard.
+type GeneratedFile struct {
+	Filename string                 `json:"filename"`
+	Type     string                 `json:"type"`
+	Action   string                 `json:"action"` // "create" or "update" (existing parent .network)
+	Config   map[string]interface{} `json:"config"`
+}
+
+// L3Spec is the addressing of a generated interface.
+type L3Spec struct {
+	Addresses []string `json:"addresses,omitempty"`
+	Gateway   string   `json:"gateway,omitempty"`
+	DHCP      string   `json:"dhcp,omitempty"` // yes, no, ipv4, ipv6
+	DNS       []string `json:"dns,omitempty"`
+}
+
+func (l *L3Spec) section() map[string]interface{} {
+	sec := map[string]interface{}{}
+	if l == nil {
+		return sec
+	}
+	if len(l.Addresses) > 0 {
+		sec["Address"] = l.Addresses
+	}
+	if l.Gateway != "" {
+		sec["Gateway"] = l.Gateway
+	}
+	if l.DHCP != "" {
+		sec["DHCP"] = l.DHCP
+	}
+	if len(l.DNS) > 0 {
+		sec["DNS"] = l.DNS
+	}
+	return sec
+}
+
+type BondSpec struct {
+	Name               string   `json:"name"`
+	Mode               string   `json:"mode,omitempty"` // Default 802.3ad
+	Members            []string `json:"members"`
+	TransmitHashPolicy string   `json:"transmit_hash_policy,omitempty"`
+	MIIMonitorSec      string   `json:"mii_monitor_sec,omitempty"` // Default 100ms
+	Network            *L3Spec  `json:"network,omitempty"`
+}
+
+type BridgeSpec struct {
+	Name          string   `json:"name"`
+	Ports         []string `json:"ports"`
+	STP           bool     `json:"stp,omitempty"`
+	VLANFiltering bool     `json:"vlan_filtering,omitempty"`
+	Network       *L3Spec  `json:"network,omitempty"`
+}
+
+// VLANEntry is one VLAN on a parent. With Bridge set, the VLAN is enslaved to
+// a new bridge of that name, which then carries Network.
+type VLANEntry struct {
+	ID      int     `json:"id"`
+	Name    string  `json:"name,omitempty"` // Default <parent>.<id>
+	Bridge  string  `json:"bridge,omitempty"`
+	Network *L3Spec `json:"network,omitempty"`
+}
+
+type VLANSpec struct {
+	Parent string      `json:"parent"`
+	VLANs  []VLANEntry `json:"vlans"`
+}
+
+type VRFSpec struct {
+	Name    string   `json:"name"`
+	Table   int      `json:"table"`
+	Members []string `json:"members,omitempty"`
+}
+
+type MACVLANSpec struct {
+	Name    string  `json:"name"`
+	Parent  string  `json:"parent"`
+	Mode    string  `json:"mode,omitempty"` // Default bridge
+	Network *L3Spec `json:"network,omitempty"`
+}
+
+type VXLANSpec struct {
+	Name            string  `json:"name"`
+	VNI             int     `json:"vni"`
+	Parent          string  `json:"parent,omitempty"` // Underlay link, gets VXLAN=
+	Remote          string  `json:"remote,omitempty"`
+	Group           string  `json:"group,omitempty"`
+	Local           string  `json:"local,omitempty"`
+	DestinationPort int     `json:"destination_port,omitempty"` // Default 4789
+	Network         *L3Spec `json:"network,omitempty"`
+}
+
+// wizardPlan collects the files of one wizard run on top of the importers'
+// builder. Parents that already have a managed .network file get it updated
+// instead of a new one.
+type wizardPlan struct {
+	*importBuilder
+	s    *NetworkdService
+	host string
+}
+
+func (s *NetworkdService) newWizardPlan(host, prefix string) *wizardPlan {
+	if prefix == "" {
+		prefix = "20"
+	}
+	return &wizardPlan{importBuilder: newImportBuilder(prefix), s: s, host: host}
+}
+
+// create checks that iface's file of configType is new, neither generated
+// earlier in this run nor present on the host. Only a missing file counts as
+// absent; other read errors are returned.
+func (p *wizardPlan) create(iface, configType string) error {
+	if err := ValidateInterfaceName(iface); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	filename := p.filename(iface, configType)
+	if err := validateFilename(filename); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if _, ok := p.files[filename]; ok {
+		return fmt.Errorf("%w: %s is generated twice", ErrInvalidConfig, filename)
+	}
+	_, err := p.s.ReadNetworkFile(p.host, filename)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %s already exists", ErrInvalidConfig, filename)
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("failed to check %s: %w", filename, err)
+	}
+	return nil
+}
+
+func (p *wizardPlan) netdev(name, kind string, extra map[string]interface{}) error {
+	if err := p.create(name, "netdev"); err != nil {
+		return err
+	}
+	cfg := p.importBuilder.netdev(name, kind)
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	return nil
+}
+
+func (p *wizardPlan) networkFor(iface string, network map[string]interface{}) error {
+	if err := p.create(iface, "network"); err != nil {
+		return err
+	}
+	p.networkFile(iface).Config["Network"] = network
+	return nil
+}
+
+// attach adds key=value (Bond=, VLAN=, VRF=, ...) to the [Network] section of
+// iface's .network, creating the file if the interface has none yet.
+func (p *wizardPlan) attach(iface, key, value string) error {
+	if err := ValidateInterfaceName(iface); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	f, ok := p.network[iface]
+	if !ok {
+		existing, err := p.s.ListNetworkConfigs(p.host, &MatchCriteria{Name: iface})
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			filename := existing[0].Filename
+			content, err := p.s.ReadNetworkFile(p.host, filename)
+			if err != nil {
+				return err
+			}
+			cfg, err := INIToMap(content, p.s.Schema, "network")
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", filename, err)
+			}
+			f = &GeneratedFile{Filename: filename, Type: "network", Action: "update", Config: cfg}
+			p.files[filename] = f
+			p.network[iface] = f
+		} else {
+			if err := p.networkFor(iface, map[string]interface{}{}); err != nil {
+				return err
+			}
+			f = p.network[iface]
+		}
+	}
+	addValue(section(f.Config, "Network"), key, value)
+	return nil
+}
+
+// result validates all files of the plan together and writes them unless
+// dryRun is set.
+func (p *wizardPlan) result(dryRun bool) ([]GeneratedFile, error) {
+	out := p.importBuilder.result().Files
+	if dryRun {
+		for _, f := range out {
+			if err := p.s.Schema.Validate(f.Type, f.Config); err != nil {
+				return nil, fmt.Errorf("%w: validation of %s failed: %v", ErrInvalidConfig, f.Filename, err)
+			}
+		}
+		return out, nil
+	}
+	// Written files go through the single-write pipeline, which also fills
+	// in "ipam:" addresses
+	var changes []fileChange
+	var allocs []IPAMAllocation
+	for _, f := range out {
+		fileChanges, fileAllocs, err := p.s.prepareConfig(p.host, f.Filename, f.Type, f.Config, false)
+		if err != nil {
+			p.s.ReleaseAllocations(allocs)
+			return nil, fmt.Errorf("%s: %w", f.Filename, err)
+		}
+		changes = append(changes, fileChanges...)
+		allocs = append(allocs, fileAllocs...)
+	}
+	if err := p.s.applyChanges(p.host, changes); err != nil {
+		p.s.ReleaseAllocations(allocs)
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateBond generates a bond netdev, its .network and a .network per member.
+func (s *NetworkdService) CreateBond(host, prefix string, spec BondSpec, dryRun bool) ([]GeneratedFile, error) {
+	if len(spec.Members) == 0 {
+		return nil, fmt.Errorf("%w: a bond needs at least one member", ErrInvalidConfig)
+	}
+	if spec.Mode == "" {
+		spec.Mode = "802.3ad"
+	}
+	if spec.MIIMonitorSec == "" {
+		spec.MIIMonitorSec = "100ms"
+	}
+	bond := map[string]interface{}{"Mode": spec.Mode, "MIIMonitorSec": spec.MIIMonitorSec}
+	if spec.TransmitHashPolicy != "" {
+		bond["TransmitHashPolicy"] = spec.TransmitHashPolicy
+	}
+
+	p := s.newWizardPlan(host, prefix)
+	if err := p.netdev(spec.Name, "bond", map[string]interface{}{"Bond": bond}); err != nil {
+		return nil, err
+	}
+	if err := p.networkFor(spec.Name, spec.Network.section()); err != nil {
+		return nil, err
+	}
+	for _, m := range spec.Members {
+		if err := p.attach(m, "Bond", spec.Name); err != nil {
+			return nil, err
+		}
+	}
+	return p.result(dryRun)
+}
+
+// CreateBridge generates a bridge netdev, its .network and enslaves the ports.
+func (s *NetworkdService) CreateBridge(host, prefix string, spec BridgeSpec, dryRun bool) ([]GeneratedFile, error) {
+	p := s.newWizardPlan(host, prefix)
+	if err := p.addBridge(spec); err != nil {
+		return nil, err
+	}
+	for _, port := range spec.Ports {
+		if err := p.attach(port, "Bridge", spec.Name); err != nil {
+			return nil, err
+		}
+	}
+	return p.result(dryRun)
+}
+
+func (p *wizardPlan) addBridge(spec BridgeSpec) error {
+	bridge := map[string]interface{}{"STP": boolString(spec.STP)}
+	if spec.VLANFiltering {
+		bridge["VLANFiltering"] = "yes"
+	}
+	if err := p.netdev(spec.Name, "bridge", map[string]interface{}{"Bridge": bridge}); err != nil {
+		return err
+	}
+	return p.networkFor(spec.Name, spec.Network.section())
+}
+
+func boolString(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// CreateVLANs generates VLAN netdevs on a parent, attaches them to the
+// parent's .network and optionally puts each VLAN into its own bridge.
+func (s *NetworkdService) CreateVLANs(host, prefix string, spec VLANSpec, dryRun bool) ([]GeneratedFile, error) {
+	if len(spec.VLANs) == 0 {
+		return nil, fmt.Errorf("%w: no VLANs given", ErrInvalidConfig)
+	}
+	p := s.newWizardPlan(host, prefix)
+	for _, v := range spec.VLANs {
+		if v.ID < 1 || v.ID > 4094 {
+			return nil, fmt.Errorf("%w: invalid VLAN id %d", ErrInvalidConfig, v.ID)
+		}
+		if v.Name == "" {
+			v.Name = fmt.Sprintf("%s.%d", spec.Parent, v.ID)
+		}
+		if err := p.netdev(v.Name, "vlan", map[string]interface{}{"VLAN": map[string]interface{}{"Id": v.ID}}); err != nil {
+			return nil, err
+		}
+		if v.Bridge != "" {
+			if err := p.addBridge(BridgeSpec{Name: v.Bridge, Network: v.Network}); err != nil {
+				return nil, err
+			}
+			if err := p.networkFor(v.Name, map[string]interface{}{"Bridge": v.Bridge}); err != nil {
+				return nil, err
+			}
+		} else if err := p.networkFor(v.Name, v.Network.section()); err != nil {
+			return nil, err
+		}
+		if err := p.attach(spec.Parent, "VLAN", v.Name); err != nil {
+			return nil, err
+		}
+	}
+	return p.result(dryRun)
+}
+
+// CreateVRF generates a VRF netdev bound to a routing table and enslaves the
+// member interfaces.
+func (s *NetworkdService) CreateVRF(host, prefix string, spec VRFSpec, dryRun bool) ([]GeneratedFile, error) {
+	if spec.Table <= 0 {
+		return nil, fmt.Errorf("%w: a VRF needs a routing table", ErrInvalidConfig)
+	}
+	p := s.newWizardPlan(host, prefix)
+	if err := p.netdev(spec.Name, "vrf", map[string]interface{}{"VRF": map[string]interface{}{"Table": spec.Table}}); err != nil {
+		return nil, err
+	}
+	if err := p.networkFor(spec.Name, map[string]interface{}{}); err != nil {
+		return nil, err
+	}
+	for _, m := range spec.Members {
+		if err := p.attach(m, "VRF", spec.Name); err != nil {
+			return nil, err
+		}
+	}
+	return p.result(dryRun)
+}
+
+// CreateMACVLAN generates a macvlan netdev on a parent.
+func (s *NetworkdService) CreateMACVLAN(host, prefix string, spec MACVLANSpec, dryRun bool) ([]GeneratedFile, error) {
+	if spec.Mode == "" {
+		spec.Mode = "bridge"
+	}
+	p := s.newWizardPlan(host, prefix)
+	if err := p.netdev(spec.Name, "macvlan", map[string]interface{}{"MACVLAN": map[string]interface{}{"Mode": spec.Mode}}); err != nil {
+		return nil, err
+	}
+	if err := p.networkFor(spec.Name, spec.Network.section()); err != nil {
+		return nil, err
+	}
+	if err := p.attach(spec.Parent, "MACVLAN", spec.Name); err != nil {
+		return nil, err
+	}
+	return p.result(dryRun)
+}
+
+// CreateVXLAN generates a VXLAN netdev, optionally bound to an underlay link.
+func (s *NetworkdService) CreateVXLAN(host, prefix string, spec VXLANSpec, dryRun bool) ([]GeneratedFile, error) {
+	if spec.VNI < 1 || spec.VNI > 16777215 {
+		return nil, fmt.Errorf("%w: invalid VNI %d", ErrInvalidConfig, spec.VNI)
+	}
+	if spec.Remote != "" && spec.Group != "" {
+		return nil, fmt.Errorf("%w: remote and group are mutually exclusive", ErrInvalidConfig)
+	}
+	if spec.DestinationPort == 0 {
+		spec.DestinationPort = 4789
+	}
+	vxlan := map[string]interface{}{"VNI": spec.VNI, "DestinationPort": spec.DestinationPort}
+	if spec.Remote != "" {
+		vxlan["Remote"] = spec.Remote
+	}
+	if spec.Group != "" {
+		vxlan["Group"] = spec.Group
+	}
+	if spec.Local != "" {
+		vxlan["Local"] = spec.Local
+	}
+
+	p := s.newWizardPlan(host, prefix)
+	if err := p.netdev(spec.Name, "vxlan", map[string]interface{}{"VXLAN": vxlan}); err != nil {
+		return nil, err
+	}
+	if err := p.networkFor(spec.Name, spec.Network.section()); err != nil {
+		return nil, err
+	}
+	if spec.Parent != "" {
+		if err := p.attach(spec.Parent, "VXLAN", spec.Name); err != nil {
+			return nil, err
+		}
+	}
+	return p.result(dryRun)
+}
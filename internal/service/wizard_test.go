@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWizardAttachDedupesExistingValues(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	svc.Schema.TypeCache["network"] = map[string]map[string]TypeInfo{"Network": {"VLAN": {IsArray: true}}}
+	parent := filepath.Join(tmpDir, "10-eth0.network")
+	os.WriteFile(parent, []byte("[Match]\nName=eth0\n\n[Network]\nVLAN=eth0.10\nVLAN=eth0.20\n"), 0644)
+
+	// eth0.20 is already listed on the parent, e.g. from an earlier run
+	files, err := svc.CreateVLANs("local", "", VLANSpec{Parent: "eth0", VLANs: []VLANEntry{{ID: 20}, {ID: 30}}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 5 {
+		t.Errorf("Expected 2 netdevs, 2 networks and the parent, got %+v", files)
+	}
+	content, _ := os.ReadFile(parent)
+	for _, vlan := range []string{"eth0.10", "eth0.20", "eth0.30"} {
+		if n := strings.Count(string(content), "= "+vlan+"\n"); n != 1 {
+			t.Errorf("Expected VLAN %s once, got %d times:\n%s", vlan, n, content)
+		}
+	}
+}
+
+func TestWizardCreateReadErrors(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+
+	// Only a missing file is absent; one that cannot be read aborts
+	os.Mkdir(filepath.Join(tmpDir, "20-br0.netdev"), 0755)
+	_, err := svc.CreateBridge("local", "", BridgeSpec{Name: "br0"}, false)
+	if err == nil || errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Expected a read error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "20-br0.network")); err == nil {
+		t.Error("Files written despite read failure")
+	}
+
+	if _, err := svc.CreateBridge("local", "", BridgeSpec{Name: "bad/name"}, true); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig, got %v", err)
+	}
+}
@@ -16,4 +16,4 @@ echo "Data Directory:   $NETWORKD_DATA_DIR"
 echo "Static Directory: $STATIC_DIR"
 
 # Run the server
-go run cmd/server/main.go
+go run ./cmd/server
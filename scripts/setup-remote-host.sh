@@ -21,6 +21,14 @@ if [ -z "$PUBKEY" ]; then
     exit 1
 fi
 
+echo "Fetching file helper from $API_URL/api/system/remote-helper..."
+HELPER=$(curl -s --fail "$API_URL/api/system/remote-helper")
+
+if [ -z "$HELPER" ]; then
+    echo "Error: Failed to fetch the file helper from $API_URL."
+    exit 1
+fi
+
 echo "Setting up 'networkd-api' user on $HOST..."
 
 # Remote script to execute
@@ -38,8 +46,20 @@ echo '$PUBKEY' > /home/networkd-api/.ssh/authorized_keys
 chmod 600 /home/networkd-api/.ssh/authorized_keys
 chown -R networkd-api:networkd-api /home/networkd-api/.ssh
 
+echo 'Installing file helper...'
+# All config file access goes through the root-owned helper, which refuses
+# paths outside the networkd configuration. Sudoers wildcards match spaces
+# and '..', so granting cat/dd/chmod/mv directly would allow any file.
+mkdir -p /usr/local/libexec
+base64 -d > /usr/local/libexec/networkd-api-helper <<'HELPER_EOF'
+$(printf '%s\n' "$HELPER" | base64)
+HELPER_EOF
+chown root:root /usr/local/libexec/networkd-api-helper
+chmod 755 /usr/local/libexec/networkd-api-helper
+
 echo 'Configuring sudo access...'
-echo 'networkd-api ALL=(ALL) NOPASSWD: /usr/bin/networkctl, /usr/bin/tee /etc/systemd/network/*, /usr/bin/rm /etc/systemd/network/*, /usr/bin/mkdir -p /etc/systemd/network, /usr/bin/cat /etc/systemd/network/*' > /etc/sudoers.d/networkd-api
+echo 'networkd-api ALL=(root) NOPASSWD: /usr/bin/networkctl, /usr/bin/wg show all dump, /usr/local/libexec/networkd-api-helper' > /etc/sudoers.d/networkd-api
+visudo -cf /etc/sudoers.d/networkd-api
 chmod 440 /etc/sudoers.d/networkd-api
 
 echo 'Done.'